@@ -0,0 +1,13 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package v1alpha1
+
+// v1alpha1 is the hub of the conversions: it's the storage version and the version the operator reads.
+// The other versions convert to and from it.
+
+// Hub marks SearchOperator as the conversion hub.
+func (*SearchOperator) Hub() {}
+
+// Hub marks SearchCustomization as the conversion hub.
+func (*SearchCustomization) Hub() {}
@@ -39,19 +39,38 @@ type SearchCustomizationSpec struct {
 	// If there is no storageClass specified, default storageClass is used to persist Redisgraph data.
 	// +optional
 	Persistence *bool `json:"persistence,omitempty"`
+
+	// Name of the SearchOperator instance in the same namespace this customization applies to.
+	// If not set, the search.open-cluster-management.io/searchoperator label is used, otherwise
+	// the customization applies to the instance named searchoperator.
+	// +optional
+	SearchOperatorRef string `json:"searchOperatorRef,omitempty"`
 }
 
 // SearchCustomizationStatus defines the observed state of SearchCustomization.
 type SearchCustomizationStatus struct {
+	// Generation of the SearchCustomization the status was last written for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
 	StorageClass string `json:"storageClass"`
 
 	StorageSize string `json:"storageSize"`
 
 	Persistence bool `json:"persistence"`
+
+	// Name of the PVC redisgraph saves its data to, with the default resolved
+	// +optional
+	PVCName string `json:"pvcName,omitempty"`
+
+	// Conditions report whether the customization matches a SearchOperator instance.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:storageversion
 
 // SearchCustomization is the schema for the search customizations API.
 type SearchCustomization struct {
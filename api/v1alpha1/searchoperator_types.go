@@ -38,18 +38,409 @@ type SearchOperatorSpec struct {
 	// NodeSelector causes all components to be scheduled on nodes with matching labels.
 	// +optional
 	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Probes overrides the timings of the redisgraph container probes.
+	// +optional
+	Probes *RedisgraphProbes `json:"probes,omitempty"`
+
+	// Paused stops the operator from changing any resource, status is still reported.
+	// The search.open-cluster-management.io/paused: "true" annotation has the same effect.
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+
+	// Maintenance scales the redisgraph StatefulSet to zero replicas, keeping the PVC.
+	// +optional
+	Maintenance bool `json:"maintenance,omitempty"`
+
+	// Database configures the redisgraph database used by search.
+	// +optional
+	Database *DatabaseSpec `json:"database,omitempty"`
+
+	// Dependents select the Deployments of the search components that connect to Redisgraph.
+	// They are restarted with a rollout when the connection changes. Defaults to search-collector and search-api.
+	// +optional
+	Dependents []DependentSelector `json:"dependents,omitempty"`
+
+	// ImagePolicy pins the redisgraph image to a digest and restricts where it comes from.
+	// +optional
+	ImagePolicy *ImagePolicy `json:"imagePolicy,omitempty"`
+
+	// PodSecurity hardens the security context of the redisgraph pod.
+	// +optional
+	PodSecurity *PodSecurity `json:"podSecurity,omitempty"`
+
+	// NetworkPolicy restricts the pods that can connect to redisgraph.
+	// +optional
+	NetworkPolicy *NetworkPolicySpec `json:"networkPolicy,omitempty"`
+
+	// PasswordPolicy configures the generated passwords and the Redis ACL user of the search components.
+	// +optional
+	PasswordPolicy *PasswordPolicy `json:"passwordPolicy,omitempty"`
+}
+
+// PasswordPolicy configures the passwords the operator generates for redisgraph
+type PasswordPolicy struct {
+	// Length of the generated passwords, 16 by default.
+	// +kubebuilder:validation:Minimum=12
+	// +kubebuilder:validation:Maximum=128
+	// +optional
+	Length int `json:"length,omitempty"`
+	// Charset of the generated passwords. Alphanumeric is the default, AlphanumericSymbols adds symbols that
+	// don't need quoting in a URL or a shell, Hex only uses hexadecimal digits.
+	// +kubebuilder:validation:Enum=Alphanumeric;AlphanumericSymbols;Hex
+	// +optional
+	Charset string `json:"charset,omitempty"`
+	// ACLUser creates a Redis ACL user for the search components, published in the connection Secret instead of
+	// the password of the default user.
+	// +optional
+	ACLUser *ACLUserSpec `json:"aclUser,omitempty"`
+	// ComponentUsers creates a Redis ACL user with its own Secret for search-api, search-collector,
+	// search-aggregator and the health probe of the operator, each only allowed the commands it needs. The users
+	// are written to an ACL file in the redisgraph user secret, which redisgraph loads on start.
+	// +optional
+	ComponentUsers bool `json:"componentUsers,omitempty"`
+}
+
+// ACLUserSpec is a Redis ACL user restricted to command categories
+type ACLUserSpec struct {
+	// Name of the user, search by default.
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_.-]+$`
+	// +optional
+	Name string `json:"name,omitempty"`
+	// Categories of the commands the user can run, without the @. Defaults to read, write and connection.
+	// The dangerous category is always denied.
+	// +optional
+	Categories []string `json:"categories,omitempty"`
+	// Commands the user can run in addition to the categories. Defaults to graph.query and graph.ro_query.
+	// +optional
+	Commands []string `json:"commands,omitempty"`
+}
+
+// NetworkPolicySpec restricts the pods that can connect to redisgraph
+type NetworkPolicySpec struct {
+	// Enabled creates a NetworkPolicy only admitting the clients and the operator to redisgraph.
+	Enabled bool `json:"enabled"`
+	// Clients are the pods admitted to redisgraph. Defaults to search-api, search-collector and search-aggregator.
+	// +optional
+	Clients []NetworkPolicyClient `json:"clients,omitempty"`
+}
+
+// NetworkPolicyClient selects pods admitted to redisgraph
+type NetworkPolicyClient struct {
+	// Name of the client
+	Name string `json:"name"`
+	// MatchLabels selects the pods of the client in the namespace of the SearchOperator.
+	MatchLabels map[string]string `json:"matchLabels"`
+}
+
+// PodSecurity configures the security context of the redisgraph pod
+type PodSecurity struct {
+	// Profile of the security context. Default runs as user 10001 with privilege escalation disabled.
+	// Restricted complies with the restricted Pod Security Standard: non-root user, RuntimeDefault seccomp
+	// profile and all capabilities dropped. OpenShift is Restricted without fixed user and group IDs, so the
+	// restricted SCC assigns them.
+	// +kubebuilder:validation:Enum=Default;Restricted;OpenShift
+	// +optional
+	Profile string `json:"profile,omitempty"`
+	// ReadOnlyRootFilesystem mounts the root filesystem of the container read-only, with emptyDir volumes for
+	// the paths redisgraph writes to. Defaults to true for the Restricted and OpenShift profiles.
+	// +optional
+	ReadOnlyRootFilesystem *bool `json:"readOnlyRootFilesystem,omitempty"`
+}
+
+// ImagePolicy controls how the redisgraph image is resolved and verified before it's rolled out
+type ImagePolicy struct {
+	// PinDigest resolves the tag of the image to the digest of its manifest in the registry and runs the
+	// StatefulSet with the digest, so restarting the pod can't change the image.
+	// +optional
+	PinDigest bool `json:"pinDigest,omitempty"`
+
+	// AllowedRegistries the image can come from, a registry host like quay.io or a repository prefix like
+	// quay.io/stolostron. Any registry is allowed if empty.
+	// +optional
+	AllowedRegistries []string `json:"allowedRegistries,omitempty"`
+
+	// VerifySignature runs the image verification hook of the operator before rolling out the image.
+	// +optional
+	VerifySignature bool `json:"verifySignature,omitempty"`
+}
+
+// DependentSelector selects the Deployments of a search component by the labels of their pods
+type DependentSelector struct {
+	// Name of the component, used in the status.
+	Name string `json:"name"`
+
+	// MatchLabels selects the Deployments whose pod template has these labels.
+	MatchLabels map[string]string `json:"matchLabels"`
+}
+
+// DatabaseSpec configures the redisgraph database
+type DatabaseSpec struct {
+	// Enabled deploys redisgraph. If not set, the DEPLOY_REDISGRAPH environment variable
+	// of the operator is used, and redisgraph is deployed if that isn't set either.
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// External uses an existing Redisgraph endpoint instead of deploying redisgraph.
+	// +optional
+	External *ExternalDatabase `json:"external,omitempty"`
+}
+
+// ExternalDatabase is an existing Redisgraph endpoint used by search
+type ExternalDatabase struct {
+	// Host name or IP address of the Redisgraph endpoint.
+	Host string `json:"host"`
+
+	// Port of the Redisgraph endpoint. Defaults to 6380.
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	// TLS is used to connect to the endpoint unless set to false.
+	// +optional
+	TLS *bool `json:"tls,omitempty"`
+
+	// Name of a Secret in the same namespace with the CA certificate in the ca.crt key.
+	// The system CAs are used if not set.
+	// +optional
+	CASecretName string `json:"caSecretName,omitempty"`
+
+	// Secret in the same namespace with the password of the endpoint.
+	// +optional
+	PasswordSecret *SecretKeySelector `json:"passwordSecret,omitempty"`
+}
+
+// SecretKeySelector selects a key of a Secret in the same namespace
+type SecretKeySelector struct {
+	// Name of the Secret.
+	Name string `json:"name"`
+
+	// Key in the Secret. Defaults to password.
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// RedisgraphProbes configures the probes of the redisgraph container
+type RedisgraphProbes struct {
+	// Startup probe, runs PING until Redisgraph has loaded the RDB file.
+	// Increase periodSeconds or failureThreshold for large databases.
+	// +optional
+	Startup *ProbeTimings `json:"startup,omitempty"`
+	// Readiness probe, runs PING through the TLS port.
+	// +optional
+	Readiness *ProbeTimings `json:"readiness,omitempty"`
+	// Liveness probe, runs PING through the TLS port and also passes while the RDB file is loading.
+	// +optional
+	Liveness *ProbeTimings `json:"liveness,omitempty"`
+}
+
+// ProbeTimings overrides the timings of a probe. Unset values use the operator defaults.
+type ProbeTimings struct {
+	// +optional
+	InitialDelaySeconds int32 `json:"initialDelaySeconds,omitempty"`
+	// +optional
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+	// +optional
+	PeriodSeconds int32 `json:"periodSeconds,omitempty"`
+	// +optional
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
 }
 
 // SearchOperatorStatus defines the observed state of SearchOperator
 type SearchOperatorStatus struct {
+	// Generation of the SearchOperator the status was last written for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Effective is the configuration in use, with the defaults resolved
+	// +optional
+	Effective *EffectiveConfig `json:"effective,omitempty"`
+	// Images running in the redisgraph pod, with their digests
+	// +optional
+	Images []ImageStatus `json:"images,omitempty"`
+	// Revisions of the redisgraph StatefulSet
+	// +optional
+	StatefulSet *StatefulSetRevision `json:"statefulSet,omitempty"`
 	// Reflects the current status of the RedisGraph pod using a Persistence mode (PVC/EmptyDir/Degraded)
 	PersistenceStatus string `json:"persistence"`
-	// Reflects if Redisgraph deploy ENV is set to true
+	// Reflects if Redisgraph is deployed. After enabling the database it is set to true
+	// once the search components depending on Redisgraph have been restarted.
 	DeployRedisgraph *bool `json:"deployredisgraph,omitempty"`
+	// Reason the RedisGraph pod is not running, when it can be determined
+	// (ImagePullBackOff, OOMKilled, CrashLoopBackOff, PVCPending, FailedMount, InsufficientResources, Unschedulable)
+	// +optional
+	PodFailureReason string `json:"podFailureReason,omitempty"`
+	// Result of the last active health check of Redisgraph
+	// +optional
+	RedisHealth *RedisHealthStatus `json:"redisHealth,omitempty"`
+	// The redisgraph image the StatefulSet runs, pinned to its digest by the image policy
+	// +optional
+	PinnedImage string `json:"pinnedImage,omitempty"`
+	// History of the upgrades of the redisgraph image, the most recent last
+	// +optional
+	Upgrades []UpgradeRecord `json:"upgrades,omitempty"`
+	// Conditions of the SearchOperator. Available is true when Redisgraph is running and passes health checks.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Binding is the Secret with the Redisgraph connection details, following the Service Binding specification
+	// +optional
+	Binding *BindingReference `json:"binding,omitempty"`
+	// Rollout status of the Deployments of the search components depending on Redisgraph
+	// +optional
+	Dependents []DependentStatus `json:"dependents,omitempty"`
+	// Plan lists the changes the operator would make, written while the dry-run annotation is set
+	// +optional
+	Plan *ReconcilePlan `json:"plan,omitempty"`
+}
+
+// EffectiveConfig is the configuration the operator applies, with the defaults resolved
+type EffectiveConfig struct {
+	// Persistence is true when redisgraph saves its data to a PVC
+	Persistence bool `json:"persistence"`
+	// AllowDegrade is true when redisgraph falls back to an emptyDir if it can't use the PVC
+	AllowDegrade bool `json:"allowDegrade"`
+	// +optional
+	StorageClass string `json:"storageClass,omitempty"`
+	// +optional
+	StorageSize string `json:"storageSize,omitempty"`
+	// Name of the PVC redisgraph saves its data to
+	// +optional
+	PVCName string `json:"pvcName,omitempty"`
+	// Name of the redisgraph StatefulSet
+	// +optional
+	StatefulSetName string `json:"statefulSetName,omitempty"`
+	// SearchCustomization the storage settings come from, empty when the defaults are used
+	// +optional
+	SearchCustomization string `json:"searchCustomization,omitempty"`
+}
+
+// ImageStatus is an image running in a container
+type ImageStatus struct {
+	// Name of the container
+	Container string `json:"container"`
+	// Image of the container spec
+	Image string `json:"image"`
+	// ImageID reported by the container runtime, with the digest of the image
+	// +optional
+	ImageID string `json:"imageID,omitempty"`
+}
+
+// StatefulSetRevision is the rollout state of a StatefulSet
+type StatefulSetRevision struct {
+	// Name of the StatefulSet
+	Name string `json:"name"`
+	// Revision of the running pods
+	// +optional
+	CurrentRevision string `json:"currentRevision,omitempty"`
+	// Revision of the pod template being rolled out, equal to the current revision once the rollout completes
+	// +optional
+	UpdateRevision string `json:"updateRevision,omitempty"`
+}
+
+// UpgradeRecord is an upgrade of the redisgraph image, saved before rolling the pod and verified after
+type UpgradeRecord struct {
+	// Image running before the upgrade
+	FromImage string `json:"fromImage"`
+	// Image the upgrade rolled out
+	ToImage string `json:"toImage"`
+	// Saving, Rolling or RollingBack while the upgrade is in progress. Succeeded, RolledBack when the upgraded pod
+	// failed the verification and the previous image was restored, RollbackFailed when the previous image didn't
+	// start again, or Failed when the data couldn't be saved and the previous image was kept
+	Phase string `json:"phase"`
+	// Reason of the rollback or failure
+	// +optional
+	Message string `json:"message,omitempty"`
+	// Number of nodes of the graph saved before rolling the pod
+	// +optional
+	NodesBefore int64 `json:"nodesBefore,omitempty"`
+	// Number of nodes of the graph loaded by the upgraded pod
+	// +optional
+	NodesAfter int64 `json:"nodesAfter,omitempty"`
+	// Generation of the SearchOperator the upgrade was made for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Time the upgrade started
+	StartTime metav1.Time `json:"startTime"`
+	// Time the upgrade entered its phase
+	// +optional
+	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Time the upgrade completed, was rolled back or failed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// ReconcilePlan is what a reconcile would change, computed without applying anything
+type ReconcilePlan struct {
+	// Time the plan was computed
+	GeneratedAt metav1.Time `json:"generatedAt"`
+	// Generation of the SearchOperator the plan was computed for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Destructive is true if a change loses the data stored by Redisgraph
+	Destructive bool `json:"destructive"`
+	// Human readable summary of the changes, one per line
+	// +optional
+	Summary string `json:"summary,omitempty"`
+	// +optional
+	Changes []PlannedChange `json:"changes,omitempty"`
+}
+
+// PlannedChange is a change to a resource managed by the operator
+type PlannedChange struct {
+	// Action is create, update or delete
+	Action string `json:"action"`
+	// Kind of the resource
+	Kind string `json:"kind"`
+	// Name of the resource
+	Name string `json:"name"`
+	// Destructive is true if the change loses the data stored by Redisgraph
+	// +optional
+	Destructive bool `json:"destructive,omitempty"`
+	// Reason for the change
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// DependentStatus is the rollout status of a Deployment of a search component
+type DependentStatus struct {
+	// Name of the component
+	Name string `json:"name"`
+	// Name of the Deployment
+	Deployment string `json:"deployment"`
+	// Checksum of the Redisgraph connection the Deployment was last rolled out with
+	// +optional
+	ConfigChecksum string `json:"configChecksum,omitempty"`
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+	// +optional
+	UpdatedReplicas int32 `json:"updatedReplicas,omitempty"`
+	// +optional
+	AvailableReplicas int32 `json:"availableReplicas,omitempty"`
+	// RolloutComplete is true when all replicas are updated and available
+	RolloutComplete bool `json:"rolloutComplete"`
+}
+
+// BindingReference is the name of a binding Secret in the same namespace
+type BindingReference struct {
+	Name string `json:"name"`
+}
+
+// RedisHealthStatus is the result of connecting to Redisgraph and running a query
+type RedisHealthStatus struct {
+	// Time of the last health check
+	LastProbeTime metav1.Time `json:"lastProbeTime"`
+	// Time taken by PING and a trivial graph query
+	// +optional
+	LatencyMilliseconds int64 `json:"latencyMilliseconds,omitempty"`
+	// Version of the graph module loaded in Redis
+	// +optional
+	GraphModuleVersion string `json:"graphModuleVersion,omitempty"`
+	// Error returned by the last health check, empty if it passed
+	// +optional
+	Error string `json:"error,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:storageversion
 
 // SearchOperator is the Schema for the searchoperators API
 type SearchOperator struct {
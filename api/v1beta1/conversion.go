@@ -0,0 +1,398 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package v1beta1
+
+import (
+	"github.com/stolostron/search-operator/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// The conversions are lossless in both directions, every v1alpha1 field has a v1beta1 field and the other way
+// around. The types that didn't change are converted with Go type conversions, so they fail to compile if the
+// versions drift apart.
+
+// ConvertTo converts the SearchOperator to the v1alpha1 hub.
+func (src *SearchOperator) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1alpha1.SearchOperator)
+	dst.ObjectMeta = src.ObjectMeta
+
+	redisgraph := src.Spec.Redisgraph
+	dst.Spec = v1alpha1.SearchOperatorSpec{
+		SearchImageOverrides: v1alpha1.ImageOverrides{
+			Redisgraph_TLS:    redisgraph.Image,
+			Search_Aggregator: src.Spec.Aggregator.Image,
+			Search_API:        src.Spec.API.Image,
+			Search_Collector:  src.Spec.Collector.Image,
+		},
+		Redisgraph_Resource: v1alpha1.PodResource{
+			RequestMemory: redisgraph.Resources.Requests.Memory,
+			RequestCPU:    redisgraph.Resources.Requests.CPU,
+			LimitMemory:   redisgraph.Resources.Limits.Memory,
+			LimitCPU:      redisgraph.Resources.Limits.CPU,
+		},
+		PullPolicy:     src.Spec.ImagePullPolicy,
+		PullSecret:     src.Spec.ImagePullSecret,
+		NodeSelector:   src.Spec.NodeSelector,
+		Probes:         probesToHub(redisgraph.Probes),
+		Paused:         src.Spec.Paused,
+		Maintenance:    redisgraph.Maintenance,
+		Dependents:     dependentSelectorsToHub(src.Spec.Dependents),
+		ImagePolicy:    (*v1alpha1.ImagePolicy)(redisgraph.ImagePolicy),
+		PodSecurity:    (*v1alpha1.PodSecurity)(redisgraph.PodSecurity),
+		NetworkPolicy:  networkPolicyToHub(redisgraph.NetworkPolicy),
+		PasswordPolicy: passwordPolicyToHub(redisgraph.PasswordPolicy),
+	}
+	if redisgraph.Enabled != nil || redisgraph.External != nil {
+		dst.Spec.Database = &v1alpha1.DatabaseSpec{
+			Enabled:  redisgraph.Enabled,
+			External: externalToHub(redisgraph.External),
+		}
+	}
+
+	dst.Status = v1alpha1.SearchOperatorStatus{
+		ObservedGeneration: src.Status.ObservedGeneration,
+		Effective:          (*v1alpha1.EffectiveConfig)(src.Status.Redisgraph.Effective),
+		Images:             imagesToHub(src.Status.Redisgraph.Images),
+		StatefulSet:        (*v1alpha1.StatefulSetRevision)(src.Status.Redisgraph.StatefulSet),
+		PersistenceStatus:  src.Status.Redisgraph.Persistence,
+		DeployRedisgraph:   src.Status.Redisgraph.Deployed,
+		PodFailureReason:   src.Status.Redisgraph.PodFailureReason,
+		RedisHealth:        (*v1alpha1.RedisHealthStatus)(src.Status.Redisgraph.Health),
+		PinnedImage:        src.Status.Redisgraph.PinnedImage,
+		Upgrades:           upgradesToHub(src.Status.Redisgraph.Upgrades),
+		Conditions:         src.Status.Conditions,
+		Binding:            (*v1alpha1.BindingReference)(src.Status.Binding),
+		Dependents:         dependentStatusesToHub(src.Status.Dependents),
+		Plan:               planToHub(src.Status.Plan),
+	}
+	return nil
+}
+
+// ConvertFrom converts the v1alpha1 hub to the SearchOperator.
+func (dst *SearchOperator) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1alpha1.SearchOperator)
+	dst.ObjectMeta = src.ObjectMeta
+
+	images := src.Spec.SearchImageOverrides
+	resources := src.Spec.Redisgraph_Resource
+	dst.Spec = SearchOperatorSpec{
+		Redisgraph: RedisgraphSpec{
+			Image: images.Redisgraph_TLS,
+			Resources: RedisgraphResources{
+				Requests: ResourceValues{CPU: resources.RequestCPU, Memory: resources.RequestMemory},
+				Limits:   ResourceValues{CPU: resources.LimitCPU, Memory: resources.LimitMemory},
+			},
+			Probes:         probesFromHub(src.Spec.Probes),
+			Maintenance:    src.Spec.Maintenance,
+			ImagePolicy:    (*ImagePolicy)(src.Spec.ImagePolicy),
+			PodSecurity:    (*PodSecurity)(src.Spec.PodSecurity),
+			NetworkPolicy:  networkPolicyFromHub(src.Spec.NetworkPolicy),
+			PasswordPolicy: passwordPolicyFromHub(src.Spec.PasswordPolicy),
+		},
+		API:             ComponentSpec{Image: images.Search_API},
+		Collector:       ComponentSpec{Image: images.Search_Collector},
+		Aggregator:      ComponentSpec{Image: images.Search_Aggregator},
+		ImagePullPolicy: src.Spec.PullPolicy,
+		ImagePullSecret: src.Spec.PullSecret,
+		NodeSelector:    src.Spec.NodeSelector,
+		Paused:          src.Spec.Paused,
+		Dependents:      dependentSelectorsFromHub(src.Spec.Dependents),
+	}
+	if src.Spec.Database != nil {
+		dst.Spec.Redisgraph.Enabled = src.Spec.Database.Enabled
+		dst.Spec.Redisgraph.External = externalFromHub(src.Spec.Database.External)
+	}
+
+	dst.Status = SearchOperatorStatus{
+		ObservedGeneration: src.Status.ObservedGeneration,
+		Conditions:         src.Status.Conditions,
+		Redisgraph: RedisgraphStatus{
+			Persistence:      src.Status.PersistenceStatus,
+			Deployed:         src.Status.DeployRedisgraph,
+			PodFailureReason: src.Status.PodFailureReason,
+			Health:           (*RedisHealthStatus)(src.Status.RedisHealth),
+			PinnedImage:      src.Status.PinnedImage,
+			Upgrades:         upgradesFromHub(src.Status.Upgrades),
+			Effective:        (*EffectiveConfig)(src.Status.Effective),
+			Images:           imagesFromHub(src.Status.Images),
+			StatefulSet:      (*StatefulSetRevision)(src.Status.StatefulSet),
+		},
+		Binding:    (*BindingReference)(src.Status.Binding),
+		Dependents: dependentStatusesFromHub(src.Status.Dependents),
+		Plan:       planFromHub(src.Status.Plan),
+	}
+	return nil
+}
+
+// ConvertTo converts the SearchCustomization to the v1alpha1 hub.
+func (src *SearchCustomization) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1alpha1.SearchCustomization)
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = v1alpha1.SearchCustomizationSpec{
+		StorageClass:      src.Spec.Storage.StorageClass,
+		StorageSize:       src.Spec.Storage.Size,
+		Persistence:       src.Spec.Storage.Persistence,
+		SearchOperatorRef: src.Spec.SearchOperatorRef,
+	}
+	dst.Status = v1alpha1.SearchCustomizationStatus{
+		ObservedGeneration: src.Status.ObservedGeneration,
+		PVCName:            src.Status.Storage.PVCName,
+		StorageClass:       src.Status.Storage.StorageClass,
+		StorageSize:        src.Status.Storage.Size,
+		Persistence:        src.Status.Storage.Persistence,
+		Conditions:         src.Status.Conditions,
+	}
+	return nil
+}
+
+// ConvertFrom converts the v1alpha1 hub to the SearchCustomization.
+func (dst *SearchCustomization) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1alpha1.SearchCustomization)
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = SearchCustomizationSpec{
+		SearchOperatorRef: src.Spec.SearchOperatorRef,
+		Storage: StorageSpec{
+			Persistence:  src.Spec.Persistence,
+			StorageClass: src.Spec.StorageClass,
+			Size:         src.Spec.StorageSize,
+		},
+	}
+	dst.Status = SearchCustomizationStatus{
+		ObservedGeneration: src.Status.ObservedGeneration,
+		Conditions:         src.Status.Conditions,
+		Storage: AppliedStorage{
+			Persistence:  src.Status.Persistence,
+			StorageClass: src.Status.StorageClass,
+			Size:         src.Status.StorageSize,
+			PVCName:      src.Status.PVCName,
+		},
+	}
+	return nil
+}
+
+func probesToHub(in *RedisgraphProbes) *v1alpha1.RedisgraphProbes {
+	if in == nil {
+		return nil
+	}
+	return &v1alpha1.RedisgraphProbes{
+		Startup:   (*v1alpha1.ProbeTimings)(in.Startup),
+		Readiness: (*v1alpha1.ProbeTimings)(in.Readiness),
+		Liveness:  (*v1alpha1.ProbeTimings)(in.Liveness),
+	}
+}
+
+func probesFromHub(in *v1alpha1.RedisgraphProbes) *RedisgraphProbes {
+	if in == nil {
+		return nil
+	}
+	return &RedisgraphProbes{
+		Startup:   (*ProbeTimings)(in.Startup),
+		Readiness: (*ProbeTimings)(in.Readiness),
+		Liveness:  (*ProbeTimings)(in.Liveness),
+	}
+}
+
+func externalToHub(in *ExternalDatabase) *v1alpha1.ExternalDatabase {
+	if in == nil {
+		return nil
+	}
+	return &v1alpha1.ExternalDatabase{
+		Host:           in.Host,
+		Port:           in.Port,
+		TLS:            in.TLS,
+		CASecretName:   in.CASecretName,
+		PasswordSecret: (*v1alpha1.SecretKeySelector)(in.PasswordSecret),
+	}
+}
+
+func externalFromHub(in *v1alpha1.ExternalDatabase) *ExternalDatabase {
+	if in == nil {
+		return nil
+	}
+	return &ExternalDatabase{
+		Host:           in.Host,
+		Port:           in.Port,
+		TLS:            in.TLS,
+		CASecretName:   in.CASecretName,
+		PasswordSecret: (*SecretKeySelector)(in.PasswordSecret),
+	}
+}
+
+func dependentSelectorsToHub(in []DependentSelector) []v1alpha1.DependentSelector {
+	if in == nil {
+		return nil
+	}
+	out := make([]v1alpha1.DependentSelector, len(in))
+	for i := range in {
+		out[i] = v1alpha1.DependentSelector(in[i])
+	}
+	return out
+}
+
+func dependentSelectorsFromHub(in []v1alpha1.DependentSelector) []DependentSelector {
+	if in == nil {
+		return nil
+	}
+	out := make([]DependentSelector, len(in))
+	for i := range in {
+		out[i] = DependentSelector(in[i])
+	}
+	return out
+}
+
+func networkPolicyToHub(in *NetworkPolicySpec) *v1alpha1.NetworkPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := &v1alpha1.NetworkPolicySpec{Enabled: in.Enabled}
+	if in.Clients != nil {
+		out.Clients = make([]v1alpha1.NetworkPolicyClient, len(in.Clients))
+		for i := range in.Clients {
+			out.Clients[i] = v1alpha1.NetworkPolicyClient(in.Clients[i])
+		}
+	}
+	return out
+}
+
+func networkPolicyFromHub(in *v1alpha1.NetworkPolicySpec) *NetworkPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := &NetworkPolicySpec{Enabled: in.Enabled}
+	if in.Clients != nil {
+		out.Clients = make([]NetworkPolicyClient, len(in.Clients))
+		for i := range in.Clients {
+			out.Clients[i] = NetworkPolicyClient(in.Clients[i])
+		}
+	}
+	return out
+}
+
+func passwordPolicyToHub(in *PasswordPolicy) *v1alpha1.PasswordPolicy {
+	if in == nil {
+		return nil
+	}
+	return &v1alpha1.PasswordPolicy{
+		Length:         in.Length,
+		Charset:        in.Charset,
+		ACLUser:        (*v1alpha1.ACLUserSpec)(in.ACLUser),
+		ComponentUsers: in.ComponentUsers,
+	}
+}
+
+func passwordPolicyFromHub(in *v1alpha1.PasswordPolicy) *PasswordPolicy {
+	if in == nil {
+		return nil
+	}
+	return &PasswordPolicy{
+		Length:         in.Length,
+		Charset:        in.Charset,
+		ACLUser:        (*ACLUserSpec)(in.ACLUser),
+		ComponentUsers: in.ComponentUsers,
+	}
+}
+
+func dependentStatusesToHub(in []DependentStatus) []v1alpha1.DependentStatus {
+	if in == nil {
+		return nil
+	}
+	out := make([]v1alpha1.DependentStatus, len(in))
+	for i := range in {
+		out[i] = v1alpha1.DependentStatus(in[i])
+	}
+	return out
+}
+
+func dependentStatusesFromHub(in []v1alpha1.DependentStatus) []DependentStatus {
+	if in == nil {
+		return nil
+	}
+	out := make([]DependentStatus, len(in))
+	for i := range in {
+		out[i] = DependentStatus(in[i])
+	}
+	return out
+}
+
+func imagesToHub(in []ImageStatus) []v1alpha1.ImageStatus {
+	if in == nil {
+		return nil
+	}
+	out := make([]v1alpha1.ImageStatus, len(in))
+	for i := range in {
+		out[i] = v1alpha1.ImageStatus(in[i])
+	}
+	return out
+}
+
+func imagesFromHub(in []v1alpha1.ImageStatus) []ImageStatus {
+	if in == nil {
+		return nil
+	}
+	out := make([]ImageStatus, len(in))
+	for i := range in {
+		out[i] = ImageStatus(in[i])
+	}
+	return out
+}
+
+func upgradesToHub(in []UpgradeRecord) []v1alpha1.UpgradeRecord {
+	if in == nil {
+		return nil
+	}
+	out := make([]v1alpha1.UpgradeRecord, len(in))
+	for i := range in {
+		out[i] = v1alpha1.UpgradeRecord(in[i])
+	}
+	return out
+}
+
+func upgradesFromHub(in []v1alpha1.UpgradeRecord) []UpgradeRecord {
+	if in == nil {
+		return nil
+	}
+	out := make([]UpgradeRecord, len(in))
+	for i := range in {
+		out[i] = UpgradeRecord(in[i])
+	}
+	return out
+}
+
+func planToHub(in *ReconcilePlan) *v1alpha1.ReconcilePlan {
+	if in == nil {
+		return nil
+	}
+	out := &v1alpha1.ReconcilePlan{
+		GeneratedAt:        in.GeneratedAt,
+		ObservedGeneration: in.ObservedGeneration,
+		Destructive:        in.Destructive,
+		Summary:            in.Summary,
+	}
+	if in.Changes != nil {
+		out.Changes = make([]v1alpha1.PlannedChange, len(in.Changes))
+		for i := range in.Changes {
+			out.Changes[i] = v1alpha1.PlannedChange(in.Changes[i])
+		}
+	}
+	return out
+}
+
+func planFromHub(in *v1alpha1.ReconcilePlan) *ReconcilePlan {
+	if in == nil {
+		return nil
+	}
+	out := &ReconcilePlan{
+		GeneratedAt:        in.GeneratedAt,
+		ObservedGeneration: in.ObservedGeneration,
+		Destructive:        in.Destructive,
+		Summary:            in.Summary,
+	}
+	if in.Changes != nil {
+		out.Changes = make([]PlannedChange, len(in.Changes))
+		for i := range in.Changes {
+			out.Changes[i] = PlannedChange(in.Changes[i])
+		}
+	}
+	return out
+}
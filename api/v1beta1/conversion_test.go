@@ -0,0 +1,118 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package v1beta1
+
+import (
+	"math/rand"
+	"testing"
+
+	fuzz "github.com/google/gofuzz"
+	"github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const fuzzIterations = 500
+
+// newFuzzer returns a fuzzer of the objects of both versions. The type meta is left empty, the conversion
+// webhook sets it. An empty v1alpha1 database section has no v1beta1 equivalent and means the same as none.
+func newFuzzer(t *testing.T) *fuzz.Fuzzer {
+	seed := rand.Int63() // #nosec G404
+	t.Logf("fuzzer seed %d", seed)
+	return fuzz.NewWithSeed(seed).NilChance(0.3).Funcs(
+		func(meta *metav1.TypeMeta, c fuzz.Continue) {},
+		func(spec *v1alpha1.SearchOperatorSpec, c fuzz.Continue) {
+			c.FuzzNoCustom(spec)
+			if spec.Database != nil && spec.Database.Enabled == nil && spec.Database.External == nil {
+				spec.Database = nil
+			}
+		},
+	)
+}
+
+func TestSearchOperatorHubRoundTrip(t *testing.T) {
+	fuzzer := newFuzzer(t)
+	for i := 0; i < fuzzIterations; i++ {
+		hub := &v1alpha1.SearchOperator{}
+		fuzzer.Fuzz(hub)
+		spoke := &SearchOperator{}
+		assert.Nil(t, spoke.ConvertFrom(hub))
+		restored := &v1alpha1.SearchOperator{}
+		assert.Nil(t, spoke.ConvertTo(restored))
+		if !assert.Equal(t, hub, restored, "Expected v1alpha1 SearchOperator to round trip through v1beta1.") {
+			return
+		}
+	}
+}
+
+func TestSearchOperatorSpokeRoundTrip(t *testing.T) {
+	fuzzer := newFuzzer(t)
+	for i := 0; i < fuzzIterations; i++ {
+		spoke := &SearchOperator{}
+		fuzzer.Fuzz(spoke)
+		hub := &v1alpha1.SearchOperator{}
+		assert.Nil(t, spoke.ConvertTo(hub))
+		restored := &SearchOperator{}
+		assert.Nil(t, restored.ConvertFrom(hub))
+		if !assert.Equal(t, spoke, restored, "Expected v1beta1 SearchOperator to round trip through v1alpha1.") {
+			return
+		}
+	}
+}
+
+func TestSearchCustomizationHubRoundTrip(t *testing.T) {
+	fuzzer := newFuzzer(t)
+	for i := 0; i < fuzzIterations; i++ {
+		hub := &v1alpha1.SearchCustomization{}
+		fuzzer.Fuzz(hub)
+		spoke := &SearchCustomization{}
+		assert.Nil(t, spoke.ConvertFrom(hub))
+		restored := &v1alpha1.SearchCustomization{}
+		assert.Nil(t, spoke.ConvertTo(restored))
+		if !assert.Equal(t, hub, restored, "Expected v1alpha1 SearchCustomization to round trip through v1beta1.") {
+			return
+		}
+	}
+}
+
+func TestSearchCustomizationSpokeRoundTrip(t *testing.T) {
+	fuzzer := newFuzzer(t)
+	for i := 0; i < fuzzIterations; i++ {
+		spoke := &SearchCustomization{}
+		fuzzer.Fuzz(spoke)
+		hub := &v1alpha1.SearchCustomization{}
+		assert.Nil(t, spoke.ConvertTo(hub))
+		restored := &SearchCustomization{}
+		assert.Nil(t, restored.ConvertFrom(hub))
+		if !assert.Equal(t, spoke, restored, "Expected v1beta1 SearchCustomization to round trip through v1alpha1.") {
+			return
+		}
+	}
+}
+
+func TestConvertSearchOperator(t *testing.T) {
+	enabled := false
+	hub := &v1alpha1.SearchOperator{
+		ObjectMeta: metav1.ObjectMeta{Name: "searchoperator", Namespace: "open-cluster-management"},
+		Spec: v1alpha1.SearchOperatorSpec{
+			SearchImageOverrides: v1alpha1.ImageOverrides{Redisgraph_TLS: "quay.io/stolostron/redisgraph-tls:2.5.0"},
+			Redisgraph_Resource: v1alpha1.PodResource{RequestMemory: "64Mi", RequestCPU: "25m",
+				LimitMemory: "1Gi"},
+			PullPolicy: "Always",
+			PullSecret: "multiclusterhub-operator-pull-secret",
+			Database:   &v1alpha1.DatabaseSpec{Enabled: &enabled},
+		},
+		Status: v1alpha1.SearchOperatorStatus{PersistenceStatus: "Redisgraph is using PersistenceVolumeClaim"},
+	}
+	spoke := &SearchOperator{}
+	assert.Nil(t, spoke.ConvertFrom(hub))
+
+	assert.Equal(t, "searchoperator", spoke.Name)
+	assert.Equal(t, "quay.io/stolostron/redisgraph-tls:2.5.0", spoke.Spec.Redisgraph.Image)
+	assert.Equal(t, ResourceValues{CPU: "25m", Memory: "64Mi"}, spoke.Spec.Redisgraph.Resources.Requests)
+	assert.Equal(t, ResourceValues{Memory: "1Gi"}, spoke.Spec.Redisgraph.Resources.Limits)
+	assert.Equal(t, "multiclusterhub-operator-pull-secret", spoke.Spec.ImagePullSecret)
+	assert.Equal(t, &enabled, spoke.Spec.Redisgraph.Enabled)
+	assert.Equal(t, "Redisgraph is using PersistenceVolumeClaim", spoke.Status.Redisgraph.Persistence)
+}
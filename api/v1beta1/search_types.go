@@ -0,0 +1,78 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SearchSpec defines the desired state of Search, the install-time settings of the SearchOperator
+// and the storage of the SearchCustomization in a single object.
+type SearchSpec struct {
+	SearchOperatorSpec `json:",inline"`
+
+	// Storage of the redisgraph database. The operator defaults are used if not set.
+	// +optional
+	Storage StorageSpec `json:"storage,omitempty"`
+}
+
+// SearchStatus defines the observed state of Search
+type SearchStatus struct {
+	// Generation of the Search last projected and applied by the SearchOperator
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions of the SearchOperator, and Projected which is true when the SearchOperator and
+	// SearchCustomization match the Search.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Redisgraph is the observed state of the redisgraph database
+	// +optional
+	Redisgraph RedisgraphStatus `json:"redisgraph,omitempty"`
+	// Binding is the Secret with the Redisgraph connection details, following the Service Binding specification
+	// +optional
+	Binding *BindingReference `json:"binding,omitempty"`
+	// Rollout status of the Deployments of the search components depending on Redisgraph
+	// +optional
+	Dependents []DependentStatus `json:"dependents,omitempty"`
+	// Storage in use by the redisgraph database
+	// +optional
+	Storage AppliedStorage `json:"storage,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// Search is the Schema for the searches API. It configures a search instance in one object: the operator
+// projects it onto the SearchOperator and SearchCustomization of the same name, which become read-only.
+type Search struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SearchSpec   `json:"spec,omitempty"`
+	Status SearchStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// SearchList contains a list of Search
+type SearchList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Search `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Search{}, &SearchList{})
+}
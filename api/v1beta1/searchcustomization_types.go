@@ -0,0 +1,103 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SearchCustomizationSpec defines the desired state of SearchCustomization properties.
+type SearchCustomizationSpec struct {
+	// Name of the SearchOperator instance in the same namespace this customization applies to.
+	// If not set, the search.open-cluster-management.io/searchoperator label is used, otherwise
+	// the customization applies to the instance named searchoperator.
+	// +optional
+	SearchOperatorRef string `json:"searchOperatorRef,omitempty"`
+
+	// Storage of the redisgraph database.
+	// +optional
+	Storage StorageSpec `json:"storage,omitempty"`
+}
+
+// StorageSpec configures where redisgraph saves its data
+type StorageSpec struct {
+	// If set to true, then a PVC is created on the storageClass that is provided.
+	// If there is no storageClass specified, default storageClass is used to persist Redisgraph data.
+	// +optional
+	Persistence *bool `json:"persistence,omitempty"`
+
+	// If specified this storageClass is used, otherwise the default storageClass
+	// is used by Kubernetes. If storageClass is specified, persistence must be set to true.
+	// +optional
+	StorageClass string `json:"storageClass,omitempty"`
+
+	// Size of the PVC which is used by search-redisgraph pod.
+	// +optional
+	Size string `json:"size,omitempty"`
+}
+
+// SearchCustomizationStatus defines the observed state of SearchCustomization.
+type SearchCustomizationStatus struct {
+	// Generation of the SearchCustomization the status was last written for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions report whether the customization matches a SearchOperator instance.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Storage in use by the redisgraph database.
+	// +optional
+	Storage AppliedStorage `json:"storage,omitempty"`
+}
+
+// AppliedStorage is the storage in use by the redisgraph database
+type AppliedStorage struct {
+	// +optional
+	Persistence bool `json:"persistence,omitempty"`
+	// +optional
+	StorageClass string `json:"storageClass,omitempty"`
+	// +optional
+	Size string `json:"size,omitempty"`
+	// Name of the PVC redisgraph saves its data to, with the default resolved
+	// +optional
+	PVCName string `json:"pvcName,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:unservedversion
+
+// SearchCustomization is the schema for the search customizations API.
+type SearchCustomization struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SearchCustomizationSpec   `json:"spec,omitempty"`
+	Status SearchCustomizationStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// SearchCustomizationList contains a list of SearchCustomization.
+type SearchCustomizationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SearchCustomization `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SearchCustomization{}, &SearchCustomizationList{})
+}
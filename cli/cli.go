@@ -0,0 +1,199 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package cli contains the support subcommands of the operator binary. They run against the cluster of a
+// kubeconfig, or offline against the objects of a YAML file, for example the output of kubectl get -o yaml.
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+)
+
+const defaultNamespace = "open-cluster-management"
+
+// commands are the subcommands run instead of the manager
+var commands = map[string]func(ctx context.Context, env *env, args []string) error{
+	"status":      status,
+	"must-gather": mustGather,
+	"render":      renderManifests,
+}
+
+// env holds what the subcommands share: where they read from and write to.
+type env struct {
+	scheme *runtime.Scheme
+	out    io.Writer
+	// config is nil when reading from a file
+	config    *rest.Config
+	client    client.Client
+	namespace string
+	name      string
+
+	// Flags of must-gather
+	gatherOutput      string
+	operatorNamespace string
+}
+
+// IsCommand returns true if the argument is a support subcommand.
+func IsCommand(arg string) bool {
+	_, ok := commands[arg]
+	return ok
+}
+
+// Run runs the subcommand with its arguments and returns the exit code.
+func Run(ctx context.Context, scheme *runtime.Scheme, command string, args []string, out, errOut io.Writer) int {
+	run, ok := commands[command]
+	if !ok {
+		fmt.Fprintf(errOut, "unknown command %q\n", command)
+		return 2
+	}
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	flags.SetOutput(errOut)
+	kubeconfig := flags.String("kubeconfig", "", "Path to the kubeconfig, the in-cluster config or "+
+		"KUBECONFIG is used if not set.")
+	file := flags.String("file", "", "Read the objects from a YAML file instead of the cluster.")
+	e := &env{scheme: scheme, out: out}
+	flags.StringVar(&e.namespace, "namespace", defaultNamespace, "Namespace of the SearchOperator.")
+	flags.StringVar(&e.name, "name", "searchoperator", "Name of the SearchOperator.")
+	commandFlags(command, flags, e)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	var err error
+	if *file != "" {
+		e.client, err = fileClient(scheme, *file, e.namespace)
+	} else {
+		e.config, err = restConfig(*kubeconfig)
+		if err == nil {
+			e.client, err = client.New(e.config, client.Options{Scheme: scheme})
+		}
+	}
+	if err == nil {
+		err = run(ctx, e, flags.Args())
+	}
+	if err != nil {
+		fmt.Fprintf(errOut, "%s: %v\n", command, err)
+		return 1
+	}
+	return 0
+}
+
+// commandFlags binds the flags specific to a subcommand to the env.
+func commandFlags(command string, flags *flag.FlagSet, e *env) {
+	if command == "must-gather" {
+		flags.StringVar(&e.gatherOutput, "output", "", "Path of the tarball, search-must-gather-<time>.tar.gz "+
+			"if not set.")
+		flags.StringVar(&e.operatorNamespace, "operator-namespace", "", "Namespace of the operator pods, "+
+			"the namespace of the SearchOperator if not set.")
+	}
+}
+
+func restConfig(kubeconfig string) (*rest.Config, error) {
+	if kubeconfig != "" {
+		return clientcmd.BuildConfigFromFlags("", kubeconfig)
+	}
+	return ctrl.GetConfig()
+}
+
+// fileClient returns a client serving the objects of the YAML file. Objects without a namespace are
+// put in the namespace of the SearchOperator, kinds unknown to the scheme are skipped.
+func fileClient(scheme *runtime.Scheme, path, namespace string) (client.Client, error) {
+	data, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, err
+	}
+	objects, err := decodeObjects(scheme, data)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range objects {
+		if obj.GetNamespace() == "" {
+			obj.SetNamespace(namespace)
+		}
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(), nil
+}
+
+// decodeObjects decodes the documents of a YAML stream, expanding lists.
+func decodeObjects(scheme *runtime.Scheme, data []byte) ([]client.Object, error) {
+	decoder := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	objects := []client.Object{}
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return objects, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		decoded, err := decodeDocument(decoder, doc)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, decoded...)
+	}
+}
+
+func decodeDocument(decoder runtime.Decoder, doc []byte) ([]client.Object, error) {
+	obj, _, err := decoder.Decode(doc, nil, nil)
+	if runtime.IsNotRegisteredError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	list, ok := obj.(*corev1.List)
+	if !ok {
+		if cobj, ok := obj.(client.Object); ok {
+			return []client.Object{cobj}, nil
+		}
+		return nil, nil
+	}
+	objects := []client.Object{}
+	for _, item := range list.Items {
+		decoded, err := decodeDocument(decoder, item.Raw)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, decoded...)
+	}
+	return objects, nil
+}
+
+// searchOperator reads the SearchOperator the subcommand runs for.
+func (e *env) searchOperator(ctx context.Context) (*searchv1alpha1.SearchOperator, error) {
+	cr := &searchv1alpha1.SearchOperator{}
+	err := e.client.Get(ctx, types.NamespacedName{Name: e.name, Namespace: e.namespace}, cr)
+	return cr, err
+}
+
+// toYAML returns the object as YAML with its apiVersion and kind, which typed clients leave empty.
+func toYAML(scheme *runtime.Scheme, obj client.Object) ([]byte, error) {
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return nil, err
+	}
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	return yaml.Marshal(obj)
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	searchv1beta1 "github.com/stolostron/search-operator/api/v1beta1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+const testFile = "testdata/cluster.yaml"
+
+func testScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = searchv1alpha1.AddToScheme(scheme)
+	_ = searchv1beta1.AddToScheme(scheme)
+	return scheme
+}
+
+// runCommand runs the subcommand and returns its exit code, output and error output.
+func runCommand(command string, args ...string) (int, string, string) {
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	code := Run(context.TODO(), testScheme(), command, args, out, errOut)
+	return code, out.String(), errOut.String()
+}
+
+func TestIsCommand(t *testing.T) {
+	for _, command := range []string{"status", "must-gather", "render"} {
+		assert.True(t, IsCommand(command), "Expected %s to be a subcommand.", command)
+	}
+	assert.False(t, IsCommand("--metrics-addr"), "Expected manager flags to start the manager.")
+}
+
+func TestDecodeObjects(t *testing.T) {
+	data := []byte(`apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Secret
+  metadata:
+    name: test-secret
+- apiVersion: example.com/v1
+  kind: Unknown
+  metadata:
+    name: test-unknown
+---
+apiVersion: search.open-cluster-management.io/v1alpha1
+kind: SearchOperator
+metadata:
+  name: searchoperator
+`)
+	objects, err := decodeObjects(testScheme(), data)
+	assert.Nil(t, err, "Expected objects to be decoded. Got error: %v", err)
+	assert.Len(t, objects, 2, "Expected list items to be expanded and unknown kinds skipped.")
+	assert.Equal(t, "test-secret", objects[0].GetName())
+	assert.Equal(t, "searchoperator", objects[1].GetName())
+}
+
+func TestRunErrors(t *testing.T) {
+	code, _, errOut := runCommand("unknown")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, errOut, `unknown command "unknown"`)
+
+	code, _, errOut = runCommand("status", "--file", testFile, "--name", "missing")
+	assert.Equal(t, 1, code, "Expected a missing SearchOperator to fail.")
+	assert.Contains(t, errOut, "not found")
+
+	code, _, _ = runCommand("status", "--unknown-flag")
+	assert.Equal(t, 2, code)
+}
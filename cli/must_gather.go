@@ -0,0 +1,177 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package cli
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	searchv1beta1 "github.com/stolostron/search-operator/api/v1beta1"
+	"github.com/stolostron/search-operator/render"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// gatheredList is a resource dumped from the namespace of the SearchOperator
+type gatheredList struct {
+	resource string
+	list     client.ObjectList
+}
+
+func gatheredLists() []gatheredList {
+	return []gatheredList{
+		{"searches", &searchv1beta1.SearchList{}},
+		{"searchoperators", &searchv1alpha1.SearchOperatorList{}},
+		{"searchcustomizations", &searchv1alpha1.SearchCustomizationList{}},
+		{"statefulsets", &appv1.StatefulSetList{}},
+		{"deployments", &appv1.DeploymentList{}},
+		{"persistentvolumeclaims", &corev1.PersistentVolumeClaimList{}},
+		{"pods", &corev1.PodList{}},
+		{"events", &corev1.EventList{}},
+		{"secrets", &corev1.SecretList{}},
+	}
+}
+
+// mustGather writes the search resources, events and pod logs of the namespace to a tarball.
+// The values of secrets are left out.
+func mustGather(ctx context.Context, e *env, args []string) error {
+	output := e.gatherOutput
+	if output == "" {
+		output = fmt.Sprintf("search-must-gather-%s.tar.gz", time.Now().UTC().Format("20060102-150405"))
+	}
+	file, err := os.Create(output) // #nosec G304
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	zipped := gzip.NewWriter(file)
+	archive := tar.NewWriter(zipped)
+	if err = gather(ctx, e, archive); err != nil {
+		return err
+	}
+	if err = archive.Close(); err != nil {
+		return err
+	}
+	if err = zipped.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "Wrote %s\n", output)
+	return nil
+}
+
+func gather(ctx context.Context, e *env, archive *tar.Writer) error {
+	for _, gathered := range gatheredLists() {
+		resource := gathered.resource
+		if err := e.client.List(ctx, gathered.list, client.InNamespace(e.namespace)); err != nil {
+			// Keep gathering what can be read
+			if err = addFile(archive, path.Join(e.namespace, resource, "error.txt"), []byte(err.Error())); err != nil {
+				return err
+			}
+			continue
+		}
+		items, err := meta.ExtractList(gathered.list)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			obj, ok := item.(client.Object)
+			if !ok {
+				continue
+			}
+			if secret, ok := obj.(*corev1.Secret); ok {
+				redactSecret(secret)
+			}
+			data, err := toYAML(e.scheme, obj)
+			if err != nil {
+				return err
+			}
+			if err = addFile(archive, path.Join(e.namespace, resource, obj.GetName()+".yaml"), data); err != nil {
+				return err
+			}
+		}
+	}
+	return gatherLogs(ctx, e, archive)
+}
+
+// redactSecret keeps the keys of a secret and drops their values. The values of the annotations are dropped too,
+// kubectl apply keeps the whole secret in last-applied-configuration, and so are the managed fields.
+func redactSecret(secret *corev1.Secret) {
+	for key := range secret.Annotations {
+		secret.Annotations[key] = "<redacted>"
+	}
+	secret.ManagedFields = nil
+	for key := range secret.Data {
+		secret.Data[key] = []byte("<redacted>")
+	}
+	for key := range secret.StringData {
+		secret.StringData[key] = "<redacted>"
+	}
+}
+
+// gatherLogs adds the logs of the operator and redisgraph pods. Logs can only be read from a cluster.
+func gatherLogs(ctx context.Context, e *env, archive *tar.Writer) error {
+	if e.config == nil {
+		return addFile(archive, path.Join("logs", "README.txt"), []byte("Logs are not gathered from a file.\n"))
+	}
+	clientset, err := kubernetes.NewForConfig(e.config)
+	if err != nil {
+		return err
+	}
+	podsNamespace := e.operatorNamespace
+	if podsNamespace == "" {
+		podsNamespace = e.namespace
+	}
+	if err = gatherPodLogs(ctx, e, clientset, archive, podsNamespace,
+		labels.SelectorFromSet(render.OperatorPodLabels)); err != nil {
+		return err
+	}
+	cr, err := e.searchOperator(ctx)
+	if err != nil {
+		return addFile(archive, path.Join("logs", "error.txt"), []byte(err.Error()))
+	}
+	return gatherPodLogs(ctx, e, clientset, archive, e.namespace, render.NamesFor(cr.Name).PodListSelector())
+}
+
+func gatherPodLogs(ctx context.Context, e *env, clientset kubernetes.Interface, archive *tar.Writer,
+	namespace string, selector labels.Selector) error {
+	pods := &corev1.PodList{}
+	if err := e.client.List(ctx, pods, client.InNamespace(namespace),
+		client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return err
+	}
+	for _, pod := range pods.Items {
+		for _, container := range pod.Spec.Containers {
+			name := path.Join("logs", namespace, pod.Name+"_"+container.Name+".log")
+			logs, err := clientset.CoreV1().Pods(namespace).GetLogs(pod.Name,
+				&corev1.PodLogOptions{Container: container.Name}).Do(ctx).Raw()
+			if err != nil {
+				logs = []byte(err.Error())
+			}
+			if err = addFile(archive, name, logs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func addFile(archive *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{Name: path.Join("must-gather", name), Mode: 0600, Size: int64(len(data)),
+		ModTime: time.Now()}
+	if err := archive.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := archive.Write(data)
+	return err
+}
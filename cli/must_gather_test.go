@@ -0,0 +1,53 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package cli
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMustGather(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "must-gather.tar.gz")
+	code, out, errOut := runCommand("must-gather", "--file", testFile, "--output", output)
+	assert.Equal(t, 0, code, "Expected must-gather to succeed. Got: %s", errOut)
+	assert.Contains(t, out, output)
+
+	file, err := os.Open(output)
+	assert.Nil(t, err, "Expected the tarball to be written. Got error: %v", err)
+	defer file.Close()
+	zipped, err := gzip.NewReader(file)
+	assert.Nil(t, err, "Expected a gzip tarball. Got error: %v", err)
+	archive := tar.NewReader(zipped)
+	files := map[string]string{}
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err, "Expected a valid tarball. Got error: %v", err)
+		data, err := io.ReadAll(archive)
+		assert.Nil(t, err, "Expected to read %s. Got error: %v", header.Name, err)
+		files[header.Name] = string(data)
+	}
+
+	for _, name := range []string{"searchoperators/searchoperator.yaml",
+		"searchcustomizations/searchcustomization.yaml", "statefulsets/search-redisgraph.yaml",
+		"persistentvolumeclaims/gp2-search-redisgraph-0.yaml", "pods/search-redisgraph-0.yaml"} {
+		assert.Contains(t, files, "must-gather/open-cluster-management/"+name)
+	}
+	secret := files["must-gather/open-cluster-management/secrets/redisgraph-user-secret.yaml"]
+	assert.Contains(t, secret, "redispwd:", "Expected the keys of secrets to be kept.")
+	assert.NotContains(t, secret, "c2VjcmV0", "Expected the values of secrets to be redacted.")
+	assert.Contains(t, secret, "kubectl.kubernetes.io/last-applied-configuration: <redacted>",
+		"Expected the annotations of secrets to be redacted.")
+	assert.NotContains(t, secret, "managedFields", "Expected the managed fields of secrets to be dropped.")
+	assert.Contains(t, files, "must-gather/logs/README.txt", "Expected a note that logs aren't read from a file.")
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/stolostron/search-operator/controllers"
+	"github.com/stolostron/search-operator/render"
+)
+
+// renderManifests prints the redisgraph manifests of the SearchOperator, with the values of its SearchCustomization.
+// The generated passwords and the ACL file are left out of the Secrets.
+func renderManifests(ctx context.Context, e *env, args []string) error {
+	cr, err := e.searchOperator(ctx)
+	if err != nil {
+		return err
+	}
+	custom, err := controllers.FindCustomization(ctx, e.client, cr)
+	if err != nil {
+		return err
+	}
+	objects := render.Render(render.Options{
+		SearchOperator:      cr,
+		SearchCustomization: custom,
+		ReleaseName:         os.Getenv("RELEASE_NAME"),
+		OperatorNamespace:   os.Getenv("POD_NAMESPACE"),
+	})
+	for _, obj := range objects.List() {
+		data, err := toYAML(e.scheme, obj)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(e.out, "---\n%s", data)
+	}
+	return nil
+}
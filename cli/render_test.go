@@ -0,0 +1,32 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestRender(t *testing.T) {
+	code, out, errOut := runCommand("render", "--file", testFile)
+	assert.Equal(t, 0, code, "Expected render to succeed. Got: %s", errOut)
+
+	objects, err := decodeObjects(testScheme(), []byte(out))
+	assert.Nil(t, err, "Expected rendered manifests to be valid. Got error: %v", err)
+	kinds := []string{}
+	for _, obj := range objects {
+		kinds = append(kinds, obj.GetObjectKind().GroupVersionKind().Kind+"/"+obj.GetName())
+		if secret, ok := obj.(*corev1.Secret); ok {
+			assert.Empty(t, secret.Data, "Expected the password not to be rendered.")
+		}
+	}
+	assert.Equal(t, []string{"Secret/redisgraph-user-secret", "ServiceAccount/search-redisgraph",
+		"PersistentVolumeClaim/gp2-search-redisgraph-0",
+		"StatefulSet/search-redisgraph", "Service/search-redisgraph"}, kinds)
+	assert.Contains(t, out, "storage: 20Gi", "Expected the storage size of the SearchCustomization.")
+	assert.False(t, strings.Contains(out, "readyReplicas"), "Expected the status of the cluster not to be rendered.")
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stolostron/search-operator/controllers"
+	"github.com/stolostron/search-operator/render"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// status prints a summary of the SearchOperator, its redisgraph pods and the PVC they use.
+func status(ctx context.Context, e *env, args []string) error {
+	cr, err := e.searchOperator(ctx)
+	if err != nil {
+		return err
+	}
+	names := render.NamesFor(cr.Name)
+	printOperator(e, cr)
+	custom, err := controllers.FindCustomization(ctx, e.client, cr)
+	if err != nil {
+		return err
+	}
+	if custom != nil {
+		persistence := custom.Spec.Persistence == nil || *custom.Spec.Persistence
+		fmt.Fprintf(e.out, "SearchCustomization %s: persistence %t, storageClass %q, storageSize %q\n",
+			custom.Name, persistence, custom.Spec.StorageClass, custom.Spec.StorageSize)
+	} else {
+		fmt.Fprintln(e.out, "SearchCustomization: none, using the defaults")
+	}
+
+	sts := &appv1.StatefulSet{}
+	err = e.client.Get(ctx, types.NamespacedName{Name: names.StatefulSet, Namespace: cr.Namespace}, sts)
+	if errors.IsNotFound(err) {
+		fmt.Fprintf(e.out, "StatefulSet %s: not found\n", names.StatefulSet)
+	} else if err != nil {
+		return err
+	} else {
+		replicas := int32(1)
+		if sts.Spec.Replicas != nil {
+			replicas = *sts.Spec.Replicas
+		}
+		fmt.Fprintf(e.out, "StatefulSet %s: %d/%d ready\n", sts.Name, sts.Status.ReadyReplicas, replicas)
+	}
+
+	pods := &corev1.PodList{}
+	err = e.client.List(ctx, pods, client.InNamespace(cr.Namespace),
+		client.MatchingLabelsSelector{Selector: names.PodListSelector()})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "Redisgraph pods: %d\n", len(pods.Items))
+	for _, pod := range pods.Items {
+		ready, restarts := len(pod.Status.ContainerStatuses) > 0, int32(0)
+		for _, container := range pod.Status.ContainerStatuses {
+			ready = ready && container.Ready
+			restarts += container.RestartCount
+		}
+		fmt.Fprintf(e.out, "  %s: %s, ready %t, restarts %d\n", pod.Name, pod.Status.Phase, ready, restarts)
+	}
+
+	for _, volume := range sts.Spec.Template.Spec.Volumes {
+		if volume.Name != "persist" {
+			continue
+		}
+		if volume.PersistentVolumeClaim == nil {
+			fmt.Fprintln(e.out, "PVC: none, redisgraph saves to an emptyDir")
+			continue
+		}
+		return printPVC(ctx, e, cr.Namespace, volume.PersistentVolumeClaim.ClaimName)
+	}
+	return nil
+}
+
+func printOperator(e *env, cr *searchv1alpha1.SearchOperator) {
+	fmt.Fprintf(e.out, "SearchOperator %s/%s\n", cr.Namespace, cr.Name)
+	fmt.Fprintf(e.out, "  Persistence: %s\n", cr.Status.PersistenceStatus)
+	if cr.Status.DeployRedisgraph != nil {
+		fmt.Fprintf(e.out, "  Redisgraph deployed: %t\n", *cr.Status.DeployRedisgraph)
+	}
+	if cr.Status.PodFailureReason != "" {
+		fmt.Fprintf(e.out, "  Pod failure reason: %s\n", cr.Status.PodFailureReason)
+	}
+	for _, condition := range cr.Status.Conditions {
+		fmt.Fprintf(e.out, "  Condition %s: %s (%s) %s\n", condition.Type, condition.Status, condition.Reason,
+			condition.Message)
+	}
+	if health := cr.Status.RedisHealth; health != nil {
+		if health.Error != "" {
+			fmt.Fprintf(e.out, "  Redis health: failed at %s: %s\n", health.LastProbeTime, health.Error)
+		} else {
+			fmt.Fprintf(e.out, "  Redis health: passed at %s, latency %dms, graph module %s\n",
+				health.LastProbeTime, health.LatencyMilliseconds, health.GraphModuleVersion)
+		}
+	}
+	if cr.Status.Binding != nil {
+		fmt.Fprintf(e.out, "  Connection Secret: %s\n", cr.Status.Binding.Name)
+	}
+	for _, dependent := range cr.Status.Dependents {
+		fmt.Fprintf(e.out, "  Dependent %s: deployment %s, %d/%d updated, %d available, rollout complete %t\n",
+			dependent.Name, dependent.Deployment, dependent.UpdatedReplicas, dependent.Replicas,
+			dependent.AvailableReplicas, dependent.RolloutComplete)
+	}
+}
+
+func printPVC(ctx context.Context, e *env, namespace, name string) error {
+	pvc := &corev1.PersistentVolumeClaim{}
+	err := e.client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, pvc)
+	if errors.IsNotFound(err) {
+		fmt.Fprintf(e.out, "PVC %s: not found\n", name)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	storageClass := ""
+	if pvc.Spec.StorageClassName != nil {
+		storageClass = *pvc.Spec.StorageClassName
+	}
+	capacity := pvc.Status.Capacity[corev1.ResourceStorage]
+	requested := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	fmt.Fprintf(e.out, "PVC %s: %s, capacity %s, requested %s, storageClass %q\n", pvc.Name, pvc.Status.Phase,
+		capacity.String(), requested.String(), storageClass)
+	return nil
+}
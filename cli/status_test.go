@@ -0,0 +1,21 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatus(t *testing.T) {
+	code, out, errOut := runCommand("status", "--file", testFile)
+	assert.Equal(t, 0, code, "Expected status to succeed. Got: %s", errOut)
+	assert.Contains(t, out, "SearchOperator open-cluster-management/searchoperator")
+	assert.Contains(t, out, "Condition Available: True (RedisgraphRunning) Redisgraph is running")
+	assert.Contains(t, out, `SearchCustomization searchcustomization: persistence true, storageClass "gp2"`)
+	assert.Contains(t, out, "StatefulSet search-redisgraph: 1/1 ready")
+	assert.Contains(t, out, "search-redisgraph-0: Running, ready true, restarts 2")
+	assert.Contains(t, out, `PVC gp2-search-redisgraph-0: Bound, capacity 20Gi, requested 20Gi, storageClass "gp2"`)
+}
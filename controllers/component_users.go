@@ -0,0 +1,290 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stolostron/search-operator/render"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Keys of the secrets of the component users
+const (
+	secretKeyUsername = "username"
+	secretKeyUserPwd  = "password"
+)
+
+// componentUserCommands are what the ACL user of each search component can run. search-api, search-collector and
+// the probe of the operator only read the graph with GRAPH.RO_QUERY, search-aggregator is the only one writing it
+// with GRAPH.QUERY. None of them can delete keys, flush the database or run GRAPH.DELETE, but the GRAPH.QUERY of
+// search-aggregator can still delete every node of the graph.
+var componentUserCommands = map[string]searchv1alpha1.ACLUserSpec{
+	"search-api": {
+		Categories: []string{"read", "connection"},
+		Commands:   []string{"graph.ro_query", "graph.explain"},
+	},
+	"search-collector": {
+		Categories: []string{"read", "connection"},
+		Commands:   []string{"graph.ro_query"},
+	},
+	"search-aggregator": {
+		Categories: []string{"read", "connection"},
+		Commands:   []string{"graph.query", "graph.ro_query"},
+	},
+	render.ProbeUser: {Commands: []string{"ping", "info", "graph.ro_query"}},
+}
+
+// passwordRules are the first rules of ACL SETUSER for a user with the password and no permissions.
+func passwordRules(password []byte) []string {
+	hash := sha256.Sum256(password)
+	return []string{"reset", "on", "#" + hex.EncodeToString(hash[:]), "~*", "-@all"}
+}
+
+// componentUserRules returns the rules of ACL SETUSER for the user of the component. The commands are allowed
+// after the dangerous category is denied, INFO is part of it.
+func componentUserRules(component string, password []byte) []string {
+	spec := componentUserCommands[component]
+	rules := passwordRules(password)
+	for _, category := range spec.Categories {
+		rules = append(rules, "+@"+category)
+	}
+	rules = append(rules, "-@dangerous")
+	for _, command := range spec.Commands {
+		rules = append(rules, "+"+command)
+	}
+	return rules
+}
+
+// componentCredentials returns the password of the component user from its secret, nil if it's missing.
+func componentCredentials(secret *corev1.Secret, component string) []byte {
+	if string(secret.Data[secretKeyUsername]) != component {
+		return nil
+	}
+	return secret.Data[secretKeyUserPwd]
+}
+
+// syncComponentCredentials generates the credentials of the component user in its secret if they are missing.
+// It returns true if the secret changed.
+func syncComponentCredentials(cr *searchv1alpha1.SearchOperator, secret *corev1.Secret,
+	component string) (bool, error) {
+	if len(componentCredentials(secret, component)) > 0 {
+		return false, nil
+	}
+	password, err := generatePassword(cr)
+	if err != nil {
+		return false, err
+	}
+	secret.Data = map[string][]byte{secretKeyUsername: []byte(component), secretKeyUserPwd: password}
+	setGeneratedAt(secret)
+	return true, nil
+}
+
+// aclFile returns the ACL file of redisgraph with the default user, the ACL user of the password policy and the
+// component users. Redis creates a default user without a password if the file doesn't have one.
+func aclFile(cr *searchv1alpha1.SearchOperator, redisSecret *corev1.Secret, passwords map[string][]byte) []byte {
+	var file bytes.Buffer
+	hash := sha256.Sum256(redisSecret.Data[secretKeyPassword])
+	fmt.Fprintf(&file, "user %s on #%s ~* +@all\n", defaultRedisUser, hex.EncodeToString(hash[:]))
+	if spec := aclUserSpec(cr); spec != nil && len(redisSecret.Data[secretKeyACLPassword]) > 0 {
+		fmt.Fprintf(&file, "user %s %s\n", redisSecret.Data[secretKeyACLUser],
+			strings.Join(aclUserRules(spec, redisSecret.Data[secretKeyACLPassword]), " "))
+	}
+	for _, component := range render.ComponentUsers {
+		fmt.Fprintf(&file, "user %s %s\n", component,
+			strings.Join(componentUserRules(component, passwords[component]), " "))
+	}
+	return file.Bytes()
+}
+
+// reconcileComponentUsers creates the secret of each component user and writes the ACL file mounted in
+// redisgraph to the redisgraph user secret, or removes them once the component users are disabled. Existing
+// passwords are kept.
+func (r *reconcileRequest) reconcileComponentUsers(ctx context.Context,
+	cr *searchv1alpha1.SearchOperator) error {
+	if !render.ComponentUsersEnabled(cr) {
+		return r.deleteComponentUsers(ctx)
+	}
+	log := logf.FromContext(ctx)
+	passwords := map[string][]byte{}
+	for i, expected := range render.ComponentSecrets(cr, r.names) {
+		component := render.ComponentUsers[i]
+		found := &corev1.Secret{}
+		err := r.Client.Get(ctx, types.NamespacedName{Name: expected.Name, Namespace: r.namespace}, found)
+		if errors.IsNotFound(err) {
+			if _, err = syncComponentCredentials(cr, expected, component); err != nil {
+				return err
+			}
+			log.Info("Creating the Secret of the ACL user", "secret", expected.Name, "aclUser", component)
+			if err = r.Client.Create(ctx, expected); err != nil {
+				return err
+			}
+			passwords[component] = componentCredentials(expected, component)
+			continue
+		} else if err != nil {
+			return err
+		}
+		changed, err := syncComponentCredentials(cr, found, component)
+		if err != nil {
+			return err
+		}
+		if changed {
+			log.Info("Generating the credentials of the ACL user", "secret", found.Name, "aclUser", component)
+			if err = r.Client.Update(ctx, found); err != nil {
+				return err
+			}
+		}
+		passwords[component] = componentCredentials(found, component)
+	}
+
+	redisSecret := &corev1.Secret{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: r.names.RedisSecret, Namespace: r.namespace},
+		redisSecret); err != nil {
+		return err
+	}
+	file := aclFile(cr, redisSecret, passwords)
+	if bytes.Equal(redisSecret.Data[render.ACLFileKey], file) {
+		return nil
+	}
+	redisSecret.Data[render.ACLFileKey] = file
+	log.Info("Writing the ACL file", "secret", redisSecret.Name, "key", render.ACLFileKey)
+	return r.Client.Update(ctx, redisSecret)
+}
+
+// planComponentUsers plans the changes to the secrets of the component users and to the ACL file.
+func (r *reconcileRequest) planComponentUsers(ctx context.Context,
+	cr *searchv1alpha1.SearchOperator) ([]searchv1alpha1.PlannedChange, error) {
+	changes := []searchv1alpha1.PlannedChange{}
+	redisSecret := &corev1.Secret{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: r.names.RedisSecret, Namespace: r.namespace}, redisSecret)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	if !render.ComponentUsersEnabled(cr) {
+		for _, name := range r.componentUserSecrets() {
+			err := r.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: r.namespace}, &corev1.Secret{})
+			if err == nil {
+				changes = append(changes, searchv1alpha1.PlannedChange{Action: actionDelete, Kind: "Secret",
+					Name: name, Reason: "the component users are disabled"})
+			} else if !errors.IsNotFound(err) {
+				return nil, err
+			}
+		}
+		if _, ok := redisSecret.Data[render.ACLFileKey]; ok {
+			changes = append(changes, searchv1alpha1.PlannedChange{Action: actionUpdate, Kind: "Secret",
+				Name: r.names.RedisSecret, Reason: "remove the redisgraph ACL file"})
+		}
+		return changes, nil
+	}
+	passwords := map[string][]byte{}
+	for _, component := range render.ComponentUsers {
+		name := r.names.ComponentSecret(component)
+		found := &corev1.Secret{}
+		err := r.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: r.namespace}, found)
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		}
+		passwords[component] = componentCredentials(found, component)
+		reason := fmt.Sprintf("generate the credentials of the %s ACL user", component)
+		if err != nil {
+			changes = append(changes, searchv1alpha1.PlannedChange{Action: actionCreate, Kind: "Secret",
+				Name: name, Reason: reason})
+		} else if len(passwords[component]) == 0 {
+			changes = append(changes, searchv1alpha1.PlannedChange{Action: actionUpdate, Kind: "Secret",
+				Name: name, Reason: reason})
+		}
+	}
+	// New passwords change the file, and so does a password of the default user that isn't generated yet
+	if len(changes) > 0 || err != nil ||
+		!bytes.Equal(redisSecret.Data[render.ACLFileKey], aclFile(cr, redisSecret, passwords)) {
+		changes = append(changes, searchv1alpha1.PlannedChange{Action: actionUpdate, Kind: "Secret",
+			Name: r.names.RedisSecret, Reason: "write the redisgraph ACL file"})
+	}
+	return changes, nil
+}
+
+// componentUserSecrets are the names of the secrets of the component users.
+func (r *reconcileRequest) componentUserSecrets() []string {
+	secrets := []string{}
+	for _, component := range render.ComponentUsers {
+		secrets = append(secrets, r.names.ComponentSecret(component))
+	}
+	return secrets
+}
+
+// deleteComponentUsers deletes the secrets of the component users if they exist, and removes the ACL file from
+// the redisgraph user secret.
+func (r *reconcileRequest) deleteComponentUsers(ctx context.Context) error {
+	log := logf.FromContext(ctx)
+	for _, name := range r.componentUserSecrets() {
+		found := &corev1.Secret{}
+		err := r.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: r.namespace}, found)
+		if errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		log.Info("Deleting the Secret of the disabled component users", "secret", name)
+		if err = r.Client.Delete(ctx, found); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	redisSecret := &corev1.Secret{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: r.names.RedisSecret, Namespace: r.namespace}, redisSecret)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if _, ok := redisSecret.Data[render.ACLFileKey]; !ok {
+		return nil
+	}
+	delete(redisSecret.Data, render.ACLFileKey)
+	log.Info("Removing the ACL file of the disabled component users", "secret", redisSecret.Name)
+	return r.Client.Update(ctx, redisSecret)
+}
+
+// probeTarget returns the target of the health probe, authenticated as the probe user when the component users
+// are enabled.
+func (r *reconcileRequest) probeTarget(ctx context.Context, cr *searchv1alpha1.SearchOperator) (
+	RedisTarget, error) {
+	target, err := r.redisTarget(ctx, cr)
+	if err != nil || !render.ComponentUsersEnabled(cr) {
+		return target, err
+	}
+	name := r.names.ComponentSecret(render.ProbeUser)
+	password, err := r.readSecretKey(ctx, r.namespace, name, secretKeyUserPwd)
+	if err != nil {
+		return target, err
+	}
+	target.Username, target.Password = render.ProbeUser, string(password)
+	return target, nil
+}
+
+// componentACLUsers returns the component users with the passwords of their secrets.
+func (r *reconcileRequest) componentACLUsers(ctx context.Context) ([]RedisACLUser, error) {
+	users := []RedisACLUser{}
+	for _, component := range render.ComponentUsers {
+		name := r.names.ComponentSecret(component)
+		secret := &corev1.Secret{}
+		if err := r.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: r.namespace}, secret); err != nil {
+			return nil, err
+		}
+		password := componentCredentials(secret, component)
+		if len(password) == 0 {
+			return nil, fmt.Errorf("the credentials of the ACL user %s aren't in secret %s", component, name)
+		}
+		users = append(users, RedisACLUser{Name: component, Rules: componentUserRules(component, password)})
+	}
+	return users, nil
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stolostron/search-operator/render"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func componentUsersOperator() *searchv1alpha1.SearchOperator {
+	operator := commonSetup().srchOperator.DeepCopy()
+	operator.Spec.PasswordPolicy = &searchv1alpha1.PasswordPolicy{ComponentUsers: true}
+	return operator
+}
+
+func getSecret(t *testing.T, kclient client.Client, name string) *corev1.Secret {
+	secret := &corev1.Secret{}
+	err := kclient.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: testNamespace}, secret)
+	assert.Nil(t, err, "Expected secret %s. Got error: %v", name, err)
+	return secret
+}
+
+func TestComponentUserRules(t *testing.T) {
+	rules := componentUserRules(render.ProbeUser, []byte("secret"))
+	assert.Equal(t, []string{"-@all", "-@dangerous", "+ping", "+info", "+graph.ro_query"}, rules[4:],
+		"Expected the probe user to only run PING, INFO and read-only graph queries.")
+
+	rules = componentUserRules("search-api", []byte("secret"))
+	assert.NotContains(t, rules, "+graph.query", "Expected search-api not to write the graph.")
+	assert.NotContains(t, rules, "+@write")
+	rules = componentUserRules("search-collector", []byte("secret"))
+	assert.NotContains(t, rules, "+graph.query", "Expected search-collector not to write the graph.")
+	rules = componentUserRules("search-aggregator", []byte("secret"))
+	assert.Contains(t, rules, "+graph.query", "Expected search-aggregator to write the graph.")
+	for _, component := range render.ComponentUsers {
+		rules = componentUserRules(component, []byte("secret"))
+		for _, denied := range []string{"+@write", "+@all", "+flushall", "+flushdb", "+del", "+graph.delete"} {
+			assert.NotContains(t, rules, denied, "Expected %s not to be able to flush the graph.", component)
+		}
+	}
+}
+
+func TestReconcileComponentUsers(t *testing.T) {
+	testSetup := commonSetup()
+	ctx := context.TODO()
+	instance := componentUsersOperator()
+	kclient := fake.NewFakeClientWithScheme(testSetup.scheme, instance, testSetup.secret)
+	reconciler := SearchOperatorReconciler{Client: kclient, Log: log, Scheme: testSetup.scheme}
+	request := reconciler.newRequest(testSetup.request)
+
+	assert.Nil(t, request.reconcileComponentUsers(ctx, instance))
+	names := request.names
+	for _, component := range render.ComponentUsers {
+		secret := getSecret(t, kclient, names.ComponentSecret(component))
+		assert.Equal(t, component, string(secret.Data[secretKeyUsername]))
+		assert.Len(t, secret.Data[secretKeyUserPwd], defaultPasswordLength)
+		assert.NotEmpty(t, secret.Annotations[annotationGeneratedAt], "Expected the generation time on the secret.")
+		assert.Equal(t, instance.Name, secret.OwnerReferences[0].Name, "Expected the SearchOperator to own it.")
+	}
+	file := string(getSecret(t, kclient, testNames.RedisSecret).Data[render.ACLFileKey])
+	lines := strings.Split(strings.TrimSpace(file), "\n")
+	assert.Len(t, lines, 1+len(render.ComponentUsers), "Expected the default user and the component users.")
+	assert.True(t, strings.HasPrefix(lines[0], "user default on #"), "Expected the default user with a password.")
+	assert.True(t, strings.HasPrefix(lines[1], "user search-api reset on #"))
+
+	// The passwords are kept, a missing password is generated again and written to the ACL file
+	api := getSecret(t, kclient, names.ComponentSecret("search-api"))
+	collector := getSecret(t, kclient, names.ComponentSecret("search-collector"))
+	delete(api.Data, secretKeyUserPwd)
+	assert.Nil(t, kclient.Update(ctx, api))
+	plan, err := request.planComponentUsers(ctx, instance)
+	assert.Nil(t, err)
+	assert.Equal(t, []searchv1alpha1.PlannedChange{
+		{Action: actionUpdate, Kind: "Secret", Name: api.Name,
+			Reason: "generate the credentials of the search-api ACL user"},
+		{Action: actionUpdate, Kind: "Secret", Name: testNames.RedisSecret, Reason: "write the redisgraph ACL file"},
+	}, plan)
+	assert.Nil(t, request.reconcileComponentUsers(ctx, instance))
+	assert.NotEmpty(t, getSecret(t, kclient, api.Name).Data[secretKeyUserPwd])
+	assert.Equal(t, collector.Data, getSecret(t, kclient, collector.Name).Data, "Expected the password to be kept.")
+	assert.NotEqual(t, file, string(getSecret(t, kclient, testNames.RedisSecret).Data[render.ACLFileKey]),
+		"Expected the new password in the ACL file.")
+	plan, err = request.planComponentUsers(ctx, instance)
+	assert.Nil(t, err)
+	assert.Empty(t, plan, "Expected no changes once the component users are set up.")
+
+	// Disabling the component users deletes their secrets and the ACL file
+	instance.Spec.PasswordPolicy.ComponentUsers = false
+	plan, err = request.planComponentUsers(ctx, instance)
+	assert.Nil(t, err)
+	assert.Len(t, plan, len(render.ComponentUsers)+1, "Expected the secrets to be deleted.")
+	assert.Equal(t, searchv1alpha1.PlannedChange{Action: actionUpdate, Kind: "Secret", Name: testNames.RedisSecret,
+		Reason: "remove the redisgraph ACL file"}, plan[len(plan)-1])
+	assert.Nil(t, request.reconcileComponentUsers(ctx, instance))
+	for _, name := range request.componentUserSecrets() {
+		err := kclient.Get(ctx, types.NamespacedName{Name: name, Namespace: testNamespace}, &corev1.Secret{})
+		assert.True(t, errors.IsNotFound(err), "Expected secret %s to be deleted. Got %v", name, err)
+	}
+	redisSecret := getSecret(t, kclient, testNames.RedisSecret)
+	assert.NotContains(t, redisSecret.Data, render.ACLFileKey, "Expected the ACL file to be removed.")
+	assert.Equal(t, testSetup.secret.Data[secretKeyPassword], redisSecret.Data[secretKeyPassword],
+		"Expected the password to be kept.")
+}
+
+func TestComponentUsersConnection(t *testing.T) {
+	testSetup := commonSetup()
+	ctx := context.TODO()
+	instance := componentUsersOperator()
+	kclient := fake.NewFakeClientWithScheme(testSetup.scheme, instance, testSetup.secret, serviceCA())
+	acl := &fakeACL{}
+	reconciler := SearchOperatorReconciler{Client: kclient, Log: log, Scheme: testSetup.scheme, ACL: acl}
+	request := reconciler.newRequest(testSetup.request)
+	assert.Nil(t, request.reconcileComponentUsers(ctx, instance))
+
+	// The users are set at runtime as well, the ACL file is only read when redis starts
+	request.aclCondition, request.aclChecked = nil, false
+	request.configureACL(ctx, instance)
+	assert.Len(t, acl.users, 1)
+	users := []string{}
+	for _, user := range acl.users[0] {
+		users = append(users, user.Name)
+	}
+	assert.Equal(t, render.ComponentUsers, users, "Expected the component users to be set.")
+
+	// The probe authenticates as the probe user
+	target, err := request.probeTarget(ctx, instance)
+	assert.Nil(t, err)
+	assert.Equal(t, render.ProbeUser, target.Username)
+	target, err = request.redisTarget(ctx, instance)
+	assert.Nil(t, err)
+	assert.Equal(t, string(testSetup.secret.Data[secretKeyPassword]), target.Password,
+		"Expected the operator to configure redisgraph as the default user.")
+
+	// The password of the default user isn't published to the components
+	info, err := request.readConnection(ctx, instance)
+	assert.Nil(t, err)
+	assert.NotContains(t, info.connectionSecret(instance, testNames.ConnectionSecret).Data, "password")
+}
@@ -0,0 +1,266 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stolostron/search-operator/render"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// bindingSecretType is the Secret type of a Redis binding in the Service Binding specification
+	bindingSecretType = corev1.SecretType("servicebinding.io/redis")
+	bindingProvider   = "search-operator"
+)
+
+// errUnverifiedTLS is returned instead of sending the password to redisgraph when its certificate can't be verified
+var errUnverifiedTLS = fmt.Errorf("the certificate of redisgraph can't be verified without ca.crt in secret %s "+
+	"or %s in configmap %s, the password isn't sent", redisCertSecret, serviceCAKey, serviceCAConfigMap)
+
+// connectionInfo holds what search components need to connect to Redisgraph.
+type connectionInfo struct {
+	host     string
+	port     int32
+	tls      bool
+	password string
+	// username and userPassword are the ACL user the search components connect as, empty to use the password
+	username     string
+	userPassword string
+	caCert       []byte
+	// inCluster is the redisgraph deployed by the operator, its serving certificate is signed by the service CA
+	inCluster bool
+}
+
+// readConnection reads the connection details of the external endpoint, or of the redisgraph
+// deployed by the operator from the same names used by getStatefulSet.
+func (r *reconcileRequest) readConnection(ctx context.Context, cr *searchv1alpha1.SearchOperator) (
+	connectionInfo, error) {
+	if ext := externalDatabase(cr); ext != nil {
+		return r.readExternalEndpoint(ctx, cr, ext)
+	}
+	info := connectionInfo{
+		host:      fmt.Sprintf("%s.%s.svc", r.names.StatefulSet, cr.Namespace),
+		port:      redisPort,
+		tls:       true,
+		inCluster: true,
+	}
+	password, err := r.readSecretKey(ctx, cr.Namespace, r.names.RedisSecret, secretKeyPassword)
+	if err != nil {
+		return info, err
+	}
+	info.password = string(password)
+	if aclUserSpec(cr) != nil {
+		username, err := r.readSecretKey(ctx, cr.Namespace, r.names.RedisSecret, secretKeyACLUser)
+		if err != nil {
+			return info, err
+		}
+		userPassword, err := r.readSecretKey(ctx, cr.Namespace, r.names.RedisSecret, secretKeyACLPassword)
+		if err != nil {
+			return info, err
+		}
+		info.username, info.userPassword = string(username), string(userPassword)
+	}
+	// The certificates are created with redisgraph, the CA is published once it's available. Without a CA in
+	// the certificates secret, the serving certificate is the one signed by the service CA.
+	if caCert, err := r.readSecretKey(ctx, cr.Namespace, redisCertSecret, "ca.crt"); err == nil {
+		info.caCert = caCert
+	} else if caCert, err = r.readServiceCA(ctx, cr.Namespace); err == nil {
+		info.caCert = caCert
+	}
+	return info, nil
+}
+
+// connectionSecrets returns the names of the secrets the connection of the instance is read from that it doesn't
+// own: the password and CA secrets of the external endpoint, or the certificates of the deployed redisgraph.
+func connectionSecrets(cr *searchv1alpha1.SearchOperator) []string {
+	if !databaseEnabled(cr) {
+		return nil
+	} else if externalDatabase(cr) != nil {
+		return externalSecrets(cr)
+	}
+	return []string{redisCertSecret}
+}
+
+// connectionSecretRequests maps a secret to the instances in its namespace whose connection is read from it, so a
+// rotated password or certificate is published without waiting for another change of the instance.
+func (r *SearchOperatorReconciler) connectionSecretRequests(a client.Object) []reconcile.Request {
+	instances := &searchv1alpha1.SearchOperatorList{}
+	if err := r.Client.List(context.TODO(), instances, client.InNamespace(a.GetNamespace())); err != nil {
+		log.Error(err, "Error listing the SearchOperators of the secret", "secret", a.GetName())
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for i := range instances.Items {
+		for _, name := range connectionSecrets(&instances.Items[i]) {
+			if name == a.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+					Name: instances.Items[i].Name, Namespace: a.GetNamespace()}})
+				break
+			}
+		}
+	}
+	return requests
+}
+
+// readServiceCA reads the bundle of the service CA injected in the namespace.
+func (r *SearchOperatorReconciler) readServiceCA(ctx context.Context, namespace string) ([]byte, error) {
+	configMap := &corev1.ConfigMap{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: serviceCAConfigMap, Namespace: namespace}, configMap)
+	if err != nil {
+		return nil, err
+	}
+	bundle, ok := configMap.Data[serviceCAKey]
+	if !ok || bundle == "" {
+		return nil, fmt.Errorf("key %s not found in configmap %s", serviceCAKey, serviceCAConfigMap)
+	}
+	return []byte(bundle), nil
+}
+
+func (r *SearchOperatorReconciler) readSecretKey(ctx context.Context, namespace, name, key string) ([]byte, error) {
+	secret := &corev1.Secret{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, secret); err != nil {
+		return nil, err
+	}
+	value, ok := secret.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("key %s not found in secret %s", key, name)
+	}
+	return value, nil
+}
+
+// target returns the connection details used to health check the endpoint. The password is only sent to an
+// endpoint whose certificate can be verified: without a CA for the redisgraph of the operator an error is returned.
+func (c connectionInfo) target() (RedisTarget, error) {
+	target := RedisTarget{
+		Address:  net.JoinHostPort(c.host, strconv.Itoa(int(c.port))),
+		Password: c.password,
+	}
+	if !c.tls {
+		return target, nil
+	}
+	target.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12, ServerName: c.host}
+	if len(c.caCert) > 0 {
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(c.caCert)
+		target.TLSConfig.RootCAs = pool
+	} else if c.inCluster {
+		return RedisTarget{}, errUnverifiedTLS
+	}
+	return target, nil
+}
+
+// connectionSecret returns the binding secret search-api and search-collector mount to connect to Redisgraph.
+// The layout follows the Service Binding specification for Redis.
+func (c connectionInfo) connectionSecret(cr *searchv1alpha1.SearchOperator, name string) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: cr.Namespace,
+			Labels:    map[string]string{"app": appName},
+		},
+		Type: bindingSecretType,
+		Data: map[string][]byte{
+			"type":     []byte("redis"),
+			"provider": []byte(bindingProvider),
+			"host":     []byte(c.host),
+			"port":     []byte(strconv.Itoa(int(c.port))),
+			"tls":      []byte(strconv.FormatBool(c.tls)),
+			"password": []byte(c.password),
+		},
+	}
+	if c.username != "" {
+		secret.Data["username"] = []byte(c.username)
+		secret.Data["password"] = []byte(c.userPassword)
+	} else if render.ComponentUsersEnabled(cr) {
+		// The components read the credentials of their own user, the password of the default user isn't shared
+		delete(secret.Data, "password")
+	}
+	if len(c.caCert) > 0 {
+		secret.Data["ca.crt"] = c.caCert
+	}
+	return secret
+}
+
+// publishConnection writes the binding secret for the instance and keeps it in sync with the endpoint.
+func (r *reconcileRequest) publishConnection(ctx context.Context, cr *searchv1alpha1.SearchOperator) error {
+	info, err := r.readConnection(ctx, cr)
+	if err != nil {
+		return err
+	}
+	secret := info.connectionSecret(cr, r.names.ConnectionSecret)
+	if err = r.applyConnectionSecret(ctx, cr, secret); err != nil {
+		return err
+	}
+	r.bindingPublished = true
+	r.connectionChecksum = checksum(secret.Data)
+	return nil
+}
+
+// applyConnectionSecret creates the connection secret or updates it if the endpoint changed.
+func (r *SearchOperatorReconciler) applyConnectionSecret(ctx context.Context, cr *searchv1alpha1.SearchOperator,
+	secret *corev1.Secret) error {
+	log := logf.FromContext(ctx)
+	if err := ctrl.SetControllerReference(cr, secret, r.Scheme); err != nil {
+		log.Error(err, "Cannot set secret OwnerReference")
+	}
+	found := &corev1.Secret{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}, found)
+	if err != nil && errors.IsNotFound(err) {
+		log.Info("Creating connection Secret", "secret", secret.Name)
+		return r.Client.Create(ctx, secret)
+	} else if err != nil {
+		return err
+	}
+	if found.Type != secret.Type {
+		// The type of a Secret can't be changed
+		log.Info("Replacing connection Secret", "secret", secret.Name, "type", secret.Type)
+		if err = r.Client.Delete(ctx, found); err != nil {
+			return err
+		}
+		return r.Client.Create(ctx, secret)
+	}
+	if reflect.DeepEqual(found.Data, secret.Data) && reflect.DeepEqual(found.Labels, secret.Labels) {
+		return nil
+	}
+	found.Data = secret.Data
+	found.Labels = secret.Labels
+	found.OwnerReferences = secret.OwnerReferences
+	log.Info("Updating connection Secret", "secret", secret.Name)
+	return r.Client.Update(ctx, found)
+}
+
+// deleteConnectionSecret removes the connection secret when there is no Redisgraph to connect to.
+func (r *reconcileRequest) deleteConnectionSecret(ctx context.Context, kclient client.Client) error {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: r.names.ConnectionSecret, Namespace: r.namespace}}
+	if err := kclient.Delete(ctx, secret); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// secretDataChanged lets updates to secrets through the event filter, secrets don't have a generation.
+func secretDataChanged(old, new client.Object) bool {
+	oldSecret, ok := old.(*corev1.Secret)
+	if !ok {
+		return false
+	}
+	newSecret, ok := new.(*corev1.Secret)
+	return ok && !reflect.DeepEqual(oldSecret.Data, newSecret.Data)
+}
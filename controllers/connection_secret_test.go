@@ -0,0 +1,159 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// serviceCA is the bundle of the service CA injected in the namespace of the tests.
+func serviceCA() *corev1.ConfigMap {
+	return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: serviceCAConfigMap, Namespace: testNamespace},
+		Data: map[string]string{serviceCAKey: "service-ca"}}
+}
+
+func TestSecretDataChanged(t *testing.T) {
+	old := &corev1.Secret{Data: map[string][]byte{"redispwd": []byte("old")}}
+	updated := &corev1.Secret{Data: map[string][]byte{"redispwd": []byte("new")}}
+
+	assert.True(t, secretDataChanged(old, updated), "Expected password change to pass the event filter.")
+	assert.False(t, secretDataChanged(old, old.DeepCopy()))
+	assert.False(t, secretDataChanged(&searchv1alpha1.SearchOperator{}, &searchv1alpha1.SearchOperator{}))
+}
+
+func Test_ConnectionSecretForDeployedRedisgraph(t *testing.T) {
+	testSetup := commonSetup()
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, testSetup.srchOperator, testSetup.secret,
+		testSetup.pvc, testSetup.podWithPVC)
+	reconciler := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
+
+	_, err := reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected reconcile to complete successfully. Got error: %v", err)
+
+	connection := &corev1.Secret{}
+	err = client.Get(context.TODO(), types.NamespacedName{Name: testNames.ConnectionSecret, Namespace: testNamespace},
+		connection)
+	assert.Nil(t, err, "Expected connection secret to be created. Got error: %v", err)
+	assert.Equal(t, bindingSecretType, connection.Type)
+	assert.Equal(t, "redis", string(connection.Data["type"]))
+	assert.Equal(t, bindingProvider, string(connection.Data["provider"]))
+	assert.Equal(t, "search-redisgraph.test-cluster.svc", string(connection.Data["host"]))
+	assert.Equal(t, "6380", string(connection.Data["port"]))
+	assert.Equal(t, "true", string(connection.Data["tls"]))
+	assert.Equal(t, testSetup.secret.Data["redispwd"], connection.Data["password"])
+	assert.NotContains(t, connection.Data, "ca.crt", "Expected no CA before the certificates exist.")
+
+	instance := &searchv1alpha1.SearchOperator{}
+	err = client.Get(context.TODO(), testSetup.request.NamespacedName, instance)
+	assert.Nil(t, err, "Expected search Operator to be found. Got error: %v", err)
+	assert.Equal(t, &searchv1alpha1.BindingReference{Name: testNames.ConnectionSecret}, instance.Status.Binding)
+
+	// Without a CA in the certificates the service CA is published
+	assert.Nil(t, client.Create(context.TODO(), serviceCA()))
+	_, err = reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected reconcile to complete successfully. Got error: %v", err)
+	err = client.Get(context.TODO(), types.NamespacedName{Name: testNames.ConnectionSecret, Namespace: testNamespace},
+		connection)
+	assert.Nil(t, err, "Expected connection secret to be found. Got error: %v", err)
+	assert.Equal(t, "service-ca", string(connection.Data["ca.crt"]))
+
+	// The binding follows changes to the password and the certificates.
+	password := &corev1.Secret{}
+	err = client.Get(context.TODO(), types.NamespacedName{Name: testNames.RedisSecret, Namespace: testNamespace}, password)
+	assert.Nil(t, err, "Expected password secret to be found. Got error: %v", err)
+	password.Data["redispwd"] = []byte("rotated")
+	err = client.Update(context.TODO(), password)
+	assert.Nil(t, err, "Expected password secret to be updated. Got error: %v", err)
+	err = client.Create(context.TODO(), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: redisCertSecret, Namespace: testNamespace},
+		Data:       map[string][]byte{"ca.crt": []byte("test-ca")},
+	})
+	assert.Nil(t, err, "Expected certificates secret to be created. Got error: %v", err)
+
+	_, err = reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected reconcile to complete successfully. Got error: %v", err)
+	err = client.Get(context.TODO(), types.NamespacedName{Name: testNames.ConnectionSecret, Namespace: testNamespace},
+		connection)
+	assert.Nil(t, err, "Expected connection secret to be found. Got error: %v", err)
+	assert.Equal(t, "rotated", string(connection.Data["password"]))
+	assert.Equal(t, "test-ca", string(connection.Data["ca.crt"]))
+}
+
+func Test_ConnectionSecretRemovedWhenDisabled(t *testing.T) {
+	testSetup := commonSetup()
+	disabled := false
+	instance := testSetup.srchOperator.DeepCopy()
+	instance.Spec.Database = &searchv1alpha1.DatabaseSpec{Enabled: &disabled}
+	stale := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: testNames.ConnectionSecret, Namespace: testNamespace}}
+
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, instance, testSetup.secret, stale)
+	reconciler := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
+
+	_, err := reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected reconcile to complete successfully. Got error: %v", err)
+
+	err = client.Get(context.TODO(), types.NamespacedName{Name: testNames.ConnectionSecret, Namespace: testNamespace},
+		&corev1.Secret{})
+	assert.True(t, errors.IsNotFound(err), "Expected connection secret to be deleted.")
+	found := &searchv1alpha1.SearchOperator{}
+	err = client.Get(context.TODO(), testSetup.request.NamespacedName, found)
+	assert.Nil(t, err, "Expected search Operator to be found. Got error: %v", err)
+	assert.Nil(t, found.Status.Binding, "Expected no binding while the database is disabled.")
+}
+
+func TestConnectionTargetVerifiesTLS(t *testing.T) {
+	info := connectionInfo{host: "search-redisgraph.test-cluster.svc", port: redisPort, tls: true, password: "secret",
+		inCluster: true}
+	_, err := info.target()
+	assert.Equal(t, errUnverifiedTLS, err, "Expected the password not to be sent without a CA.")
+
+	info.caCert = []byte("service-ca")
+	target, err := info.target()
+	assert.Nil(t, err)
+	assert.False(t, target.TLSConfig.InsecureSkipVerify, "Expected the certificate to be verified.")
+	assert.NotNil(t, target.TLSConfig.RootCAs)
+
+	// External endpoints without a CA are verified with the system roots
+	external := connectionInfo{host: "redis.example.com", port: 6379, tls: true, password: "secret"}
+	target, err = external.target()
+	assert.Nil(t, err)
+	assert.Nil(t, target.TLSConfig.RootCAs)
+	assert.False(t, target.TLSConfig.InsecureSkipVerify)
+}
+
+func TestConnectionSecretRequests(t *testing.T) {
+	server := newFakeRedis(t, "external-secret")
+	testSetup := commonSetup()
+	external, secrets := externalSetup(t, testSetup, server)
+	deployed := testSetup.srchOperator.DeepCopy()
+	deployed.Name = "search-dev"
+	disabled := testSetup.srchOperator.DeepCopy()
+	disabled.Name = "search-off"
+	enabled := false
+	disabled.Spec.Database = &searchv1alpha1.DatabaseSpec{Enabled: &enabled}
+
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, external, deployed, disabled)
+	reconciler := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
+
+	for _, secret := range secrets {
+		assert.Equal(t, []reconcile.Request{testSetup.request}, reconciler.connectionSecretRequests(secret),
+			"Expected a change of secret %s to reconcile the instance using it.", secret.Name)
+	}
+	certs := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: redisCertSecret, Namespace: testNamespace}}
+	assert.Equal(t, []reconcile.Request{{NamespacedName: types.NamespacedName{Name: deployed.Name,
+		Namespace: testNamespace}}}, reconciler.connectionSecretRequests(certs),
+		"Expected a change of the certificates to reconcile the deployed instances.")
+	assert.Empty(t, reconciler.connectionSecretRequests(testSetup.secret),
+		"Expected owned secrets to be left to the owner watch.")
+}
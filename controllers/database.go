@@ -0,0 +1,50 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// databaseEnabled returns whether redisgraph is deployed for the instance. The spec takes precedence,
+// then the DEPLOY_REDISGRAPH environment variable. Redisgraph is deployed if neither is set.
+func databaseEnabled(cr *searchv1alpha1.SearchOperator) bool {
+	if cr.Spec.Database != nil && cr.Spec.Database.Enabled != nil {
+		return *cr.Spec.Database.Enabled
+	}
+	if deployVarPresent && deployVarErr == nil {
+		return deploy
+	}
+	return true
+}
+
+// setDatabaseState reads whether redisgraph is deployed and whether it was just enabled.
+// Components depending on redisgraph only need a restart if it was disabled before.
+func (r *reconcileRequest) setDatabaseState(cr *searchv1alpha1.SearchOperator) {
+	r.deployEnabled = databaseEnabled(cr)
+	r.deployStatus = cr.Status.DeployRedisgraph != nil && *cr.Status.DeployRedisgraph
+	r.restartPending = r.deployEnabled && cr.Status.DeployRedisgraph != nil && !*cr.Status.DeployRedisgraph
+}
+
+// completeDatabaseTransition restarts the search components once redisgraph is running after being enabled.
+// If redisgraph was disabled, the collector is in a 10 minute timeout loop.
+func (r *reconcileRequest) completeDatabaseTransition(ctx context.Context,
+	cr *searchv1alpha1.SearchOperator) {
+	if !r.restartPending {
+		return
+	}
+	logf.FromContext(ctx).Info("Redisgraph enabled, restarting search-collector and search-api")
+	r.restartSearchComponents(ctx, cr)
+	r.restartPending = false
+}
+
+// deployedStatus is the value written to Status.DeployRedisgraph. It stays false until the
+// search components have been restarted, so the restart is retried if redisgraph doesn't start.
+func (r *reconcileRequest) deployedStatus() *bool {
+	deployed := r.deployEnabled && !r.restartPending
+	return &deployed
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDatabaseEnabled(t *testing.T) {
+	envPresent, envValue, envErr := deployVarPresent, deploy, deployVarErr
+	t.Cleanup(func() { deployVarPresent, deploy, deployVarErr = envPresent, envValue, envErr })
+	enabled, disabled := true, false
+
+	deployVarPresent, deploy, deployVarErr = false, false, nil
+	cr := &searchv1alpha1.SearchOperator{}
+	assert.True(t, databaseEnabled(cr), "Expected redisgraph to be deployed by default.")
+
+	deployVarPresent = true
+	assert.False(t, databaseEnabled(cr), "Expected DEPLOY_REDISGRAPH=false to be used as fallback.")
+
+	cr.Spec.Database = &searchv1alpha1.DatabaseSpec{Enabled: &enabled}
+	assert.True(t, databaseEnabled(cr), "Expected spec to take precedence over DEPLOY_REDISGRAPH.")
+
+	deploy = true
+	cr.Spec.Database.Enabled = &disabled
+	assert.False(t, databaseEnabled(cr), "Expected spec to take precedence over DEPLOY_REDISGRAPH.")
+}
+
+func Test_EnablingDatabaseRestartsComponentsOnce(t *testing.T) {
+	testSetup := commonSetup()
+	enabled, deployed := true, false
+	instance := testSetup.srchOperator.DeepCopy()
+	instance.Spec.Database = &searchv1alpha1.DatabaseSpec{Enabled: &enabled}
+	instance.Status.DeployRedisgraph = &deployed
+
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, instance, testSetup.secret, testSetup.pvc,
+		testSetup.podWithPVC, collectorDeployment())
+	reconciler := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
+
+	_, err := reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected reconcile to complete successfully. Got error: %v", err)
+	collector := &appv1.Deployment{}
+	err = client.Get(context.TODO(), types.NamespacedName{Name: "search-collector", Namespace: testNamespace}, collector)
+	assert.Nil(t, err, "Expected collector deployment to be found. Got error: %v", err)
+	assert.Contains(t, collector.Spec.Template.Annotations, annotationRestartedAt,
+		"Expected collector to be restarted after enabling redisgraph.")
+
+	found := &searchv1alpha1.SearchOperator{}
+	err = client.Get(context.TODO(), testSetup.request.NamespacedName, found)
+	assert.Nil(t, err, "Expected search Operator to be found. Got error: %v", err)
+	assert.True(t, *found.Status.DeployRedisgraph, "Expected status to record redisgraph is deployed.")
+
+	// The next reconcile must not restart the collector again.
+	_, err = reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected reconcile to complete successfully. Got error: %v", err)
+	restarted := &appv1.Deployment{}
+	err = client.Get(context.TODO(), types.NamespacedName{Name: "search-collector", Namespace: testNamespace}, restarted)
+	assert.Nil(t, err, "Expected collector deployment to be found. Got error: %v", err)
+	assert.Equal(t, collector.ResourceVersion, restarted.ResourceVersion,
+		"Expected collector to be restarted only once.")
+}
+
+func Test_DisablingDatabaseDeletesStatefulSet(t *testing.T) {
+	testSetup := commonSetup()
+	disabled := false
+	instance := testSetup.srchOperator.DeepCopy()
+	instance.Spec.Database = &searchv1alpha1.DatabaseSpec{Enabled: &disabled}
+
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, instance, testSetup.secret, testSetup.pvc,
+		testSetup.podWithPVC)
+	reconciler := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
+	request := reconciler.newRequest(testSetup.request)
+	request.executeDeployment(context.TODO(), client, instance, true, true)
+
+	_, err := reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected reconcile to complete successfully. Got error: %v", err)
+
+	err = client.Get(context.TODO(), types.NamespacedName{Name: testNames.StatefulSet, Namespace: testNamespace},
+		&appv1.StatefulSet{})
+	assert.True(t, errors.IsNotFound(err), "Expected redisgraph statefulset to be deleted.")
+	err = client.Get(context.TODO(), types.NamespacedName{Name: testNames.DefaultPVC, Namespace: testNamespace},
+		&corev1.PersistentVolumeClaim{})
+	assert.Nil(t, err, "Expected PVC to be kept. Got error: %v", err)
+
+	found := &searchv1alpha1.SearchOperator{}
+	err = client.Get(context.TODO(), testSetup.request.NamespacedName, found)
+	assert.Nil(t, err, "Expected search Operator to be found. Got error: %v", err)
+	assert.Equal(t, redisNotRunning, found.Status.PersistenceStatus)
+	assert.False(t, *found.Status.DeployRedisgraph, "Expected status to record redisgraph isn't deployed.")
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"time"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	appv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	annotationRestartedAt    = "search.open-cluster-management.io/restartedAt"
+	annotationConfigChecksum = "search.open-cluster-management.io/config-checksum"
+	// annotationRolloutOnChange set to "false" on a Deployment keeps the operator from patching its pod template
+	annotationRolloutOnChange = "search.open-cluster-management.io/rollout-on-config-change"
+)
+
+// rolloutCheckInterval is how often the rollout of dependents is checked until it completes
+var rolloutCheckInterval = 10 * time.Second
+
+// defaultDependents are the search components connecting to Redisgraph
+var defaultDependents = []searchv1alpha1.DependentSelector{
+	{Name: "search-collector", MatchLabels: map[string]string{"app": "search-prod", "component": "search-collector"}},
+	{Name: "search-api", MatchLabels: map[string]string{"app": "search", "component": "search-api"}},
+}
+
+// checksum returns a stable checksum of secret data.
+func checksum(data map[string][]byte) string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	hash := sha256.New()
+	for _, key := range keys {
+		hash.Write([]byte(key))
+		hash.Write([]byte{0})
+		hash.Write(data[key])
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func dependentSelectors(cr *searchv1alpha1.SearchOperator) []searchv1alpha1.DependentSelector {
+	if len(cr.Spec.Dependents) > 0 {
+		return cr.Spec.Dependents
+	}
+	return defaultDependents
+}
+
+// dependentDeployments returns the Deployments in the namespace whose pods match the selector.
+func (r *reconcileRequest) dependentDeployments(ctx context.Context, kclient client.Client,
+	dependent searchv1alpha1.DependentSelector) ([]appv1.Deployment, error) {
+	list := &appv1.DeploymentList{}
+	if err := kclient.List(ctx, list, client.InNamespace(r.namespace)); err != nil {
+		return nil, err
+	}
+	selector := labels.SelectorFromSet(dependent.MatchLabels)
+	deployments := []appv1.Deployment{}
+	for _, deployment := range list.Items {
+		if selector.Matches(labels.Set(deployment.Spec.Template.Labels)) {
+			deployments = append(deployments, deployment)
+		}
+	}
+	return deployments, nil
+}
+
+// rolloutAllowed is false if the owner of the Deployment opted out of rolling restarts by the operator, for
+// example when a GitOps tool would revert the pod template.
+func rolloutAllowed(deployment *appv1.Deployment) bool {
+	return deployment.Annotations[annotationRolloutOnChange] != "false"
+}
+
+// appliedChecksum returns the checksum of the connection the Deployment runs with: the annotation of its template,
+// or the checksum recorded in the status. A Deployment seen for the first time adopts the current checksum,
+// so installing or upgrading the operator doesn't restart the search components.
+func appliedChecksum(cr *searchv1alpha1.SearchOperator, deployment *appv1.Deployment, current string) string {
+	if applied, ok := deployment.Spec.Template.Annotations[annotationConfigChecksum]; ok {
+		return applied
+	}
+	for _, status := range cr.Status.Dependents {
+		if status.Deployment == deployment.Name && status.ConfigChecksum != "" {
+			return status.ConfigChecksum
+		}
+	}
+	return current
+}
+
+// syncDependents rolls out the Deployments of the dependents that don't use the current connection yet, and
+// records their rollout status until it completes. With force, all of them are rolled out. Deployments that opted
+// out are only reported.
+func (r *reconcileRequest) syncDependents(ctx context.Context, cr *searchv1alpha1.SearchOperator,
+	force bool) {
+	log := logf.FromContext(ctx)
+	r.dependentsStatus = nil
+	for _, dependent := range dependentSelectors(cr) {
+		deployments, err := r.dependentDeployments(ctx, r.Client, dependent)
+		if err != nil {
+			log.Error(err, "Error listing deployments", "component", dependent.Name)
+			continue
+		}
+		if len(deployments) == 0 {
+			log.V(1).Info("Failed to find deployments", "component", dependent.Name)
+		}
+		for i := range deployments {
+			deployment := &deployments[i]
+			applied := appliedChecksum(cr, deployment, r.connectionChecksum)
+			// While redisgraph is being enabled the restart waits for it to run, see completeDatabaseTransition
+			outdated := !r.restartPending && r.connectionChecksum != "" && applied != r.connectionChecksum
+			switch {
+			case !force && !outdated:
+			case !rolloutAllowed(deployment):
+				log.Info("Not restarting deployment, it opted out of rollouts", "component", dependent.Name,
+					"deployment", deployment.Name, "annotation", annotationRolloutOnChange)
+			default:
+				if err = r.rolloutDeployment(ctx, deployment); err != nil {
+					log.Error(err, "Failed to restart deployment", "component", dependent.Name,
+						"deployment", deployment.Name)
+				} else {
+					log.Info("Restarting deployment", "component", dependent.Name, "deployment", deployment.Name)
+					applied = r.connectionChecksum
+				}
+			}
+			r.dependentsStatus = append(r.dependentsStatus, rolloutStatus(dependent.Name, deployment, applied))
+		}
+	}
+}
+
+// rolloutDeployment triggers a rolling restart like kubectl rollout restart, recording the connection checksum.
+func (r *reconcileRequest) rolloutDeployment(ctx context.Context, deployment *appv1.Deployment) error {
+	patch := client.MergeFrom(deployment.DeepCopy())
+	if deployment.Spec.Template.Annotations == nil {
+		deployment.Spec.Template.Annotations = map[string]string{}
+	}
+	deployment.Spec.Template.Annotations[annotationRestartedAt] = time.Now().UTC().Format(time.RFC3339)
+	if r.connectionChecksum != "" {
+		deployment.Spec.Template.Annotations[annotationConfigChecksum] = r.connectionChecksum
+	}
+	return r.Client.Patch(ctx, deployment, patch)
+}
+
+// rolloutStatus reports whether all replicas of the deployment run the latest template, and the checksum of the
+// connection they were started with.
+func rolloutStatus(name string, deployment *appv1.Deployment, applied string) searchv1alpha1.DependentStatus {
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	status := searchv1alpha1.DependentStatus{
+		Name:              name,
+		Deployment:        deployment.Name,
+		ConfigChecksum:    applied,
+		Replicas:          replicas,
+		UpdatedReplicas:   deployment.Status.UpdatedReplicas,
+		AvailableReplicas: deployment.Status.AvailableReplicas,
+	}
+	// A patched template bumps the generation, the rollout is only complete once the controller observed it.
+	status.RolloutComplete = deployment.Status.ObservedGeneration >= deployment.Generation &&
+		deployment.Status.UpdatedReplicas == replicas && deployment.Status.AvailableReplicas == replicas &&
+		deployment.Status.Replicas == replicas
+	return status
+}
+
+// rolloutInProgress is true while a dependent Deployment is rolling out.
+func (r *reconcileRequest) rolloutInProgress() bool {
+	for _, status := range r.dependentsStatus {
+		if !status.RolloutComplete {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func dependentDeployment(name string, labels map[string]string) *appv1.Deployment {
+	replicas := int32(1)
+	return &appv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+		Spec: appv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: labels}},
+		},
+	}
+}
+
+func collectorDeployment() *appv1.Deployment {
+	return dependentDeployment("search-collector", map[string]string{"app": "search-prod", "component": "search-collector"})
+}
+
+func TestChecksum(t *testing.T) {
+	data := map[string][]byte{"host": []byte("redis"), "password": []byte("secret")}
+	assert.Equal(t, checksum(data), checksum(map[string][]byte{"password": []byte("secret"), "host": []byte("redis")}),
+		"Expected checksum to be independent of the key order.")
+	assert.NotEqual(t, checksum(data), checksum(map[string][]byte{"host": []byte("redis"), "password": []byte("new")}))
+	assert.NotEqual(t, checksum(map[string][]byte{"ab": []byte("c")}), checksum(map[string][]byte{"a": []byte("bc")}))
+}
+
+func TestRolloutStatus(t *testing.T) {
+	commonSetup()
+	deployment := collectorDeployment()
+	deployment.Generation = 2
+	deployment.Status = appv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 1, UpdatedReplicas: 1,
+		AvailableReplicas: 1}
+	assert.False(t, rolloutStatus("search-collector", deployment, "").RolloutComplete,
+		"Expected rollout in progress until the new generation is observed.")
+
+	deployment.Status.ObservedGeneration = 2
+	deployment.Status.Replicas = 2
+	assert.False(t, rolloutStatus("search-collector", deployment, "").RolloutComplete,
+		"Expected rollout in progress while old pods are running.")
+
+	deployment.Status.Replicas = 1
+	status := rolloutStatus("search-collector", deployment, "test")
+	assert.True(t, status.RolloutComplete, "Expected rollout to be complete.")
+	assert.Equal(t, "search-collector", status.Deployment)
+	assert.Equal(t, int32(1), status.Replicas)
+	assert.Equal(t, "test", status.ConfigChecksum)
+}
+
+func Test_ConfigChangeRollsOutDependents(t *testing.T) {
+	testSetup := commonSetup()
+	collector := collectorDeployment()
+	api := dependentDeployment("search-api", map[string]string{"app": "search", "component": "search-api"})
+	api.Annotations = map[string]string{annotationRolloutOnChange: "false"}
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, testSetup.srchOperator, testSetup.secret,
+		testSetup.pvc, testSetup.podWithPVC, collector, api)
+	reconciler := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
+	request := reconciler.newRequest(testSetup.request)
+
+	result, err := reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected reconcile to complete successfully. Got error: %v", err)
+	assert.Equal(t, rolloutCheckInterval, result.RequeueAfter, "Expected rollout to be checked again.")
+
+	// The Deployments adopt the first connection without being restarted
+	connection := &corev1.Secret{}
+	err = client.Get(context.TODO(), types.NamespacedName{Name: testNames.ConnectionSecret, Namespace: testNamespace},
+		connection)
+	assert.Nil(t, err, "Expected connection secret to be created. Got error: %v", err)
+	for _, name := range []string{"search-collector", "search-api"} {
+		deployment := &appv1.Deployment{}
+		err = client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: testNamespace}, deployment)
+		assert.Nil(t, err, "Expected %s deployment to be found. Got error: %v", name, err)
+		assert.NotContains(t, deployment.Spec.Template.Annotations, annotationRestartedAt,
+			"Expected %s not to be restarted when the connection is first published.", name)
+	}
+	found := &searchv1alpha1.SearchOperator{}
+	err = client.Get(context.TODO(), testSetup.request.NamespacedName, found)
+	assert.Nil(t, err, "Expected search Operator to be found. Got error: %v", err)
+	assert.Len(t, found.Status.Dependents, 2)
+	for _, status := range found.Status.Dependents {
+		assert.Equal(t, checksum(connection.Data), status.ConfigChecksum, "Expected %s to adopt the checksum.",
+			status.Deployment)
+	}
+
+	// A password rotation changes the checksum and rolls out the Deployments that didn't opt out
+	password := &corev1.Secret{}
+	err = client.Get(context.TODO(), types.NamespacedName{Name: testNames.RedisSecret, Namespace: testNamespace}, password)
+	assert.Nil(t, err, "Expected password secret to be found. Got error: %v", err)
+	password.Data["redispwd"] = []byte("rotated")
+	err = client.Update(context.TODO(), password)
+	assert.Nil(t, err, "Expected password secret to be updated. Got error: %v", err)
+	plan, err := request.planChanges(context.TODO(), found)
+	assert.Nil(t, err)
+	assert.Contains(t, plan.Changes, searchv1alpha1.PlannedChange{Action: actionUpdate, Kind: "Deployment",
+		Name: "search-collector", Reason: "rolling restart of search-collector"})
+	assert.NotContains(t, plan.Summary, "search-api", "Expected no restart of search-api, it opted out.")
+	_, err = reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected reconcile to complete successfully. Got error: %v", err)
+	_ = client.Get(context.TODO(), types.NamespacedName{Name: testNames.ConnectionSecret, Namespace: testNamespace},
+		connection)
+	rotated := &appv1.Deployment{}
+	err = client.Get(context.TODO(), types.NamespacedName{Name: "search-collector", Namespace: testNamespace}, rotated)
+	assert.Nil(t, err, "Expected collector deployment to be found. Got error: %v", err)
+	assert.Equal(t, checksum(connection.Data), rotated.Spec.Template.Annotations[annotationConfigChecksum],
+		"Expected rollout after the password changed.")
+	unchanged := &appv1.Deployment{}
+	err = client.Get(context.TODO(), types.NamespacedName{Name: "search-api", Namespace: testNamespace}, unchanged)
+	assert.Nil(t, err, "Expected api deployment to be found. Got error: %v", err)
+	assert.Equal(t, api.Spec.Template.Annotations, unchanged.Spec.Template.Annotations,
+		"Expected the template of search-api to be left alone.")
+	_ = client.Get(context.TODO(), testSetup.request.NamespacedName, found)
+	assert.NotEqual(t, checksum(connection.Data), found.Status.Dependents[1].ConfigChecksum,
+		"Expected search-api to report the connection it still runs with.")
+
+	// Without changes nothing is rolled out again
+	_, err = reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected reconcile to complete successfully. Got error: %v", err)
+	again := &appv1.Deployment{}
+	_ = client.Get(context.TODO(), types.NamespacedName{Name: "search-collector", Namespace: testNamespace}, again)
+	assert.Equal(t, rotated.ResourceVersion, again.ResourceVersion, "Expected no rollout without changes.")
+}
+
+func Test_ForcedRestartRollsOutDependents(t *testing.T) {
+	testSetup := commonSetup()
+	instance := testSetup.srchOperator.DeepCopy()
+	collector := collectorDeployment()
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "search-collector-5d8f9", Namespace: testNamespace,
+		Labels: collector.Spec.Template.Labels}}
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, instance, collector, pod)
+	reconciler := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
+	request := reconciler.newRequest(testSetup.request)
+
+	request.connectionChecksum = "test"
+	request.restartSearchComponents(context.TODO(), instance)
+	err := client.Get(context.TODO(), types.NamespacedName{Name: pod.Name, Namespace: testNamespace}, &corev1.Pod{})
+	assert.Nil(t, err, "Expected the collector pod to be left to the rollout. Got error: %v", err)
+	found := &appv1.Deployment{}
+	_ = client.Get(context.TODO(), types.NamespacedName{Name: collector.Name, Namespace: testNamespace}, found)
+	assert.Equal(t, "test", found.Spec.Template.Annotations[annotationConfigChecksum],
+		"Expected the collector to be rolled out with the current connection.")
+	assert.Contains(t, found.Spec.Template.Annotations, annotationRestartedAt)
+	assert.Equal(t, "test", request.dependentsStatus[0].ConfigChecksum)
+	assert.False(t, request.dependentsStatus[0].RolloutComplete,
+		"Expected the rollout to be tracked until it completes.")
+}
+
+func Test_CustomDependentSelectors(t *testing.T) {
+	testSetup := commonSetup()
+	instance := testSetup.srchOperator.DeepCopy()
+	instance.Spec.Dependents = []searchv1alpha1.DependentSelector{
+		{Name: "custom-api", MatchLabels: map[string]string{"app": "custom-api"}},
+	}
+	custom := dependentDeployment("custom-api", map[string]string{"app": "custom-api"})
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, instance, custom, collectorDeployment())
+	reconciler := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
+	request := reconciler.newRequest(testSetup.request)
+
+	request.connectionChecksum = "test"
+	request.restartPending = false
+	request.syncDependents(context.TODO(), instance, false)
+	assert.Len(t, request.dependentsStatus, 1, "Expected only the configured dependents.")
+	assert.Equal(t, "custom-api", request.dependentsStatus[0].Name)
+	assert.Equal(t, "test", request.dependentsStatus[0].ConfigChecksum)
+
+	collector := &appv1.Deployment{}
+	err := client.Get(context.TODO(), types.NamespacedName{Name: "search-collector", Namespace: testNamespace}, collector)
+	assert.Nil(t, err, "Expected collector deployment to be found. Got error: %v", err)
+	assert.NotContains(t, collector.Spec.Template.Annotations, annotationRestartedAt,
+		"Expected the default dependents to be replaced by the spec.")
+}
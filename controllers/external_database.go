@@ -0,0 +1,101 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	defaultExternalPort  = 6380
+	defaultPasswordKey   = "password"
+	statusExternal       = "Using external Redisgraph endpoint"
+	statusFailedExternal = "Unable to use external Redisgraph endpoint"
+)
+
+// externalDatabase returns the external Redisgraph endpoint of the instance, nil if redisgraph is deployed.
+func externalDatabase(cr *searchv1alpha1.SearchOperator) *searchv1alpha1.ExternalDatabase {
+	if cr.Spec.Database == nil {
+		return nil
+	}
+	return cr.Spec.Database.External
+}
+
+// externalSecrets returns the names of the password and CA secrets of the external endpoint of the instance.
+func externalSecrets(cr *searchv1alpha1.SearchOperator) []string {
+	ext := externalDatabase(cr)
+	if ext == nil {
+		return nil
+	}
+	secrets := []string{}
+	if ext.PasswordSecret != nil {
+		secrets = append(secrets, ext.PasswordSecret.Name)
+	}
+	if ext.CASecretName != "" {
+		secrets = append(secrets, ext.CASecretName)
+	}
+	return secrets
+}
+
+// readExternalEndpoint reads the endpoint from the CR and the password and CA secrets it references.
+func (r *SearchOperatorReconciler) readExternalEndpoint(ctx context.Context, cr *searchv1alpha1.SearchOperator,
+	ext *searchv1alpha1.ExternalDatabase) (connectionInfo, error) {
+	endpoint := connectionInfo{host: ext.Host, port: ext.Port, tls: ext.TLS == nil || *ext.TLS}
+	if endpoint.port == 0 {
+		endpoint.port = defaultExternalPort
+	}
+	if ext.PasswordSecret != nil {
+		key := ext.PasswordSecret.Key
+		if key == "" {
+			key = defaultPasswordKey
+		}
+		password, err := r.readSecretKey(ctx, cr.Namespace, ext.PasswordSecret.Name, key)
+		if err != nil {
+			return endpoint, err
+		}
+		endpoint.password = string(password)
+	}
+	if ext.CASecretName != "" {
+		caCert, err := r.readSecretKey(ctx, cr.Namespace, ext.CASecretName, "ca.crt")
+		if err != nil {
+			return endpoint, err
+		}
+		endpoint.caCert = caCert
+	}
+	return endpoint, nil
+}
+
+// reconcileExternal uses the external endpoint instead of deploying redisgraph. The redisgraph
+// StatefulSet is deleted if it was deployed before, the PVC is kept.
+func (r *reconcileRequest) reconcileExternal(ctx context.Context, instance *searchv1alpha1.SearchOperator,
+	custom *searchv1alpha1.SearchCustomization, customValuesInuse bool) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+	ext := externalDatabase(instance)
+	if r.isStatefulSetAvailable(ctx, r.Client) {
+		log.Info("Using external Redisgraph endpoint, deleting redisgraph statefulset", "host", ext.Host)
+		if err := r.deleteRedisStatefulSet(ctx, r.Client); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	err := r.publishConnection(ctx, instance)
+	r.syncDependents(ctx, instance, false)
+	if err != nil {
+		log.Error(err, statusFailedExternal, "host", ext.Host)
+		if err := r.updateCRs(ctx, r.Client, instance, statusFailedExternal,
+			custom, false, "", "", customValuesInuse); err != nil {
+			log.Error(err, statusUpdateError)
+		}
+		return ctrl.Result{}, err
+	}
+	r.checkRedisHealth(ctx, instance)
+	r.completeDatabaseTransition(ctx, instance)
+	if err := r.updateCRs(ctx, r.Client, instance, statusExternal, custom, false, "", "", customValuesInuse); err != nil {
+		return ctrl.Result{}, err
+	}
+	return r.healthCheckResult(), nil
+}
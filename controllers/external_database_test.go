@@ -0,0 +1,108 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func externalSetup(t *testing.T, testSetup testSetup, server *fakeRedis) (
+	*searchv1alpha1.SearchOperator, []*corev1.Secret) {
+	host, port, err := net.SplitHostPort(server.listener.Addr().String())
+	assert.Nil(t, err)
+	portNumber, err := strconv.Atoi(port)
+	assert.Nil(t, err)
+	instance := testSetup.srchOperator.DeepCopy()
+	instance.Spec.Database = &searchv1alpha1.DatabaseSpec{External: &searchv1alpha1.ExternalDatabase{
+		Host:           host,
+		Port:           int32(portNumber),
+		CASecretName:   "external-redis-ca",
+		PasswordSecret: &searchv1alpha1.SecretKeySelector{Name: "external-redis", Key: "auth"},
+	}}
+	secrets := []*corev1.Secret{
+		{ObjectMeta: metav1.ObjectMeta{Name: "external-redis-ca", Namespace: testNamespace},
+			Data: map[string][]byte{"ca.crt": server.caPEM}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "external-redis", Namespace: testNamespace},
+			Data: map[string][]byte{"auth": []byte(server.password)}},
+	}
+	return instance, secrets
+}
+
+func Test_ExternalDatabase(t *testing.T) {
+	server := newFakeRedis(t, "external-secret")
+	testSetup := commonSetup()
+	instance, secrets := externalSetup(t, testSetup, server)
+
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, instance, secrets[0], secrets[1], testSetup.pvc)
+	reconciler := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme,
+		Prober: &TLSRedisProber{Timeout: 2 * time.Second}}
+	// A redisgraph deployed before switching to the external endpoint is removed.
+	request := reconciler.newRequest(testSetup.request)
+	request.executeDeployment(context.TODO(), client, instance, true, true)
+
+	result, err := reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected reconcile to complete successfully. Got error: %v", err)
+	assert.Equal(t, healthCheckInterval, result.RequeueAfter, "Expected external endpoint to be probed again.")
+
+	found := &searchv1alpha1.SearchOperator{}
+	err = client.Get(context.TODO(), testSetup.request.NamespacedName, found)
+	assert.Nil(t, err, "Expected search Operator to be found. Got error: %v", err)
+	assert.Equal(t, statusExternal, found.Status.PersistenceStatus)
+	assert.Equal(t, "", found.Status.RedisHealth.Error, "Expected external endpoint to pass the health check.")
+	assert.True(t, meta.IsStatusConditionTrue(found.Status.Conditions, conditionAvailable),
+		"Expected Available condition to be true.")
+
+	err = client.Get(context.TODO(), types.NamespacedName{Name: testNames.StatefulSet, Namespace: testNamespace},
+		&appv1.StatefulSet{})
+	assert.True(t, errors.IsNotFound(err), "Expected redisgraph statefulset to be deleted.")
+	err = client.Get(context.TODO(), types.NamespacedName{Name: testNames.DefaultPVC, Namespace: testNamespace},
+		&corev1.PersistentVolumeClaim{})
+	assert.Nil(t, err, "Expected PVC to be kept. Got error: %v", err)
+
+	connection := &corev1.Secret{}
+	err = client.Get(context.TODO(), types.NamespacedName{Name: testNames.ConnectionSecret, Namespace: testNamespace},
+		connection)
+	assert.Nil(t, err, "Expected connection secret to be created. Got error: %v", err)
+	ext := instance.Spec.Database.External
+	assert.Equal(t, bindingSecretType, connection.Type)
+	assert.Equal(t, ext.Host, string(connection.Data["host"]))
+	assert.Equal(t, strconv.Itoa(int(ext.Port)), string(connection.Data["port"]))
+	assert.Equal(t, "true", string(connection.Data["tls"]))
+	assert.Equal(t, server.password, string(connection.Data["password"]))
+	assert.Equal(t, server.caPEM, connection.Data["ca.crt"])
+	assert.Equal(t, instance.Name, connection.OwnerReferences[0].Name, "Expected secret owned by the instance.")
+}
+
+func Test_ExternalDatabaseMissingPassword(t *testing.T) {
+	server := newFakeRedis(t, "external-secret")
+	testSetup := commonSetup()
+	instance, secrets := externalSetup(t, testSetup, server)
+
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, instance, secrets[0])
+	reconciler := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
+
+	_, err := reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.NotNil(t, err, "Expected reconcile to fail without the password secret.")
+
+	found := &searchv1alpha1.SearchOperator{}
+	err = client.Get(context.TODO(), testSetup.request.NamespacedName, found)
+	assert.Nil(t, err, "Expected search Operator to be found. Got error: %v", err)
+	assert.Equal(t, statusFailedExternal, found.Status.PersistenceStatus)
+	assert.False(t, meta.IsStatusConditionTrue(found.Status.Conditions, conditionAvailable),
+		"Expected Available condition to be false.")
+}
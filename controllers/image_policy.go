@@ -0,0 +1,232 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	conditionImageVerified = "ImageVerified"
+	redisgraphContainer    = "redisgraph"
+)
+
+// imageCheckRetryInterval is how soon an image that couldn't be resolved or verified is checked again
+var imageCheckRetryInterval = time.Minute
+
+// imagePolicyError is an image rejected by the image policy. Retry is set when checking again may succeed,
+// for example when the registry can't be reached.
+type imagePolicyError struct {
+	reason string
+	err    error
+	retry  bool
+}
+
+func (e *imagePolicyError) Error() string { return e.err.Error() }
+
+// checkImage applies the image policy to the redisgraph image: the registry must be allowed, the tag is
+// resolved to a digest and the signature is verified. This is done once per generation of the SearchOperator,
+// status.pinnedImage is reused until the spec changes. The image isn't rolled out if an error is returned.
+func (r *reconcileRequest) checkImage(ctx context.Context, cr *searchv1alpha1.SearchOperator) error {
+	r.pinnedImage, r.imageCondition, r.imageChecked = "", nil, true
+	policy := cr.Spec.ImagePolicy
+	if policy == nil {
+		return nil
+	}
+	// The image was resolved and verified for this generation of the spec, the registry isn't called again
+	if verified := meta.FindStatusCondition(cr.Status.Conditions, conditionImageVerified); verified != nil &&
+		verified.Status == metav1.ConditionTrue && verified.ObservedGeneration == cr.Generation &&
+		(!policy.PinDigest || cr.Status.PinnedImage != "") {
+		condition := *verified
+		r.imageCondition = &condition
+		if policy.PinDigest {
+			r.pinnedImage = cr.Status.PinnedImage
+		}
+		return nil
+	}
+	condition := metav1.Condition{
+		Type:               conditionImageVerified,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Verified",
+		ObservedGeneration: cr.Generation,
+	}
+	image, err := r.applyImagePolicy(ctx, cr, policy)
+	if err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = err.reason
+		condition.Message = err.Error()
+		r.imageCondition = &condition
+		return err
+	}
+	condition.Message = "Rolling out " + image
+	r.imageCondition = &condition
+	if policy.PinDigest {
+		r.pinnedImage = image
+	}
+	return nil
+}
+
+func (r *reconcileRequest) applyImagePolicy(ctx context.Context, cr *searchv1alpha1.SearchOperator,
+	policy *searchv1alpha1.ImagePolicy) (string, *imagePolicyError) {
+	image := cr.Spec.SearchImageOverrides.Redisgraph_TLS
+	ref, err := ParseImageReference(image)
+	if err != nil {
+		return "", &imagePolicyError{reason: "InvalidImage", err: err}
+	}
+	if !registryAllowed(ref, policy.AllowedRegistries) {
+		return "", &imagePolicyError{reason: "RegistryNotAllowed",
+			err: fmt.Errorf("the registry of %s is not one of the allowed registries %s", image,
+				strings.Join(policy.AllowedRegistries, ", "))}
+	}
+	if policy.PinDigest && ref.Digest == "" {
+		if r.Registry == nil {
+			return "", &imagePolicyError{reason: "ResolveFailed",
+				err: fmt.Errorf("the operator has no registry client to resolve %s", image)}
+		}
+		creds, err := r.registryCredentials(ctx, cr, ref)
+		if err != nil {
+			return "", &imagePolicyError{reason: "ResolveFailed", err: err, retry: true}
+		}
+		digest, err := r.Registry.Digest(ctx, ref, creds)
+		if err != nil {
+			return "", &imagePolicyError{reason: "ResolveFailed",
+				err: fmt.Errorf("resolving the digest of %s: %w", image, err), retry: true}
+		}
+		image = ref.Pinned(digest)
+		logf.FromContext(ctx).V(1).Info("Resolved redisgraph image", "image", image)
+	}
+	if policy.VerifySignature {
+		if r.Verifier == nil {
+			return "", &imagePolicyError{reason: "VerificationFailed",
+				err: fmt.Errorf("the operator has no image verification hook to verify %s", image)}
+		}
+		if err = r.Verifier.Verify(ctx, image); err != nil {
+			return "", &imagePolicyError{reason: "VerificationFailed", err: err, retry: true}
+		}
+	}
+	return image, nil
+}
+
+// registryAllowed returns true if the image comes from one of the allowed registries or repository prefixes.
+func registryAllowed(ref ImageReference, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	repository := ref.Registry + "/" + ref.Repository
+	for _, entry := range allowed {
+		entry = strings.TrimSuffix(entry, "/")
+		if entry == ref.Registry || strings.HasPrefix(repository, entry+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// registryCredentials reads the credentials of the registry of the image from the pull secret of the instance.
+func (r *reconcileRequest) registryCredentials(ctx context.Context, cr *searchv1alpha1.SearchOperator,
+	ref ImageReference) (*RegistryCredentials, error) {
+	if cr.Spec.PullSecret == "" {
+		return nil, nil
+	}
+	secret := &corev1.Secret{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: cr.Spec.PullSecret, Namespace: r.namespace}, secret)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return pullSecretCredentials(secret, ref.Registry)
+}
+
+// holdRunningImage keeps the redisgraph StatefulSet on the image it runs while the image policy rejects the image
+// in the spec, so the rest of the StatefulSet is still reconciled. It returns false if there is no StatefulSet yet.
+func (r *reconcileRequest) holdRunningImage(ctx context.Context) (bool, error) {
+	sset := &appv1.StatefulSet{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: r.names.StatefulSet, Namespace: r.namespace}, sset)
+	if errors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	r.heldImage = containerImage(&sset.Spec.Template.Spec)
+	return r.heldImage != "", nil
+}
+
+// imageRetryResult requeues images that couldn't be resolved or verified, a rejected image waits for a change.
+func imageRetryResult(err error) time.Duration {
+	if policyErr, ok := err.(*imagePolicyError); ok && !policyErr.retry {
+		return 0
+	}
+	return imageCheckRetryInterval
+}
+
+// pinContainerImage runs the redisgraph container with the pinned image.
+func (r *reconcileRequest) pinContainerImage(podSpec *corev1.PodSpec) {
+	if r.pinnedImage != "" {
+		setContainerImage(podSpec, r.pinnedImage)
+	}
+}
+
+// containerImage returns the image of the redisgraph container of the pod spec.
+func containerImage(podSpec *corev1.PodSpec) string {
+	for _, container := range podSpec.Containers {
+		if container.Name == redisgraphContainer {
+			return container.Image
+		}
+	}
+	return ""
+}
+
+// setContainerImage replaces the image of the redisgraph container of the pod spec.
+func setContainerImage(podSpec *corev1.PodSpec, image string) {
+	for i := range podSpec.Containers {
+		if podSpec.Containers[i].Name == redisgraphContainer {
+			podSpec.Containers[i].Image = image
+		}
+	}
+}
+
+// holdContainerImage keeps the redisgraph container on the held image.
+func (r *reconcileRequest) holdContainerImage(podSpec *corev1.PodSpec) {
+	if r.heldImage != "" {
+		setContainerImage(podSpec, r.heldImage)
+	}
+}
+
+// setImageStatus reports the pinned image and the ImageVerified condition, removed without an image policy.
+// A rejected image isn't rolled out, the image pinned before is still reported.
+func (r *reconcileRequest) setImageStatus(cr *searchv1alpha1.SearchOperator) {
+	if !r.imageChecked {
+		return
+	}
+	if r.imageCondition == nil {
+		cr.Status.PinnedImage = ""
+		meta.RemoveStatusCondition(&cr.Status.Conditions, conditionImageVerified)
+		return
+	}
+	if r.imageCondition.Status == metav1.ConditionTrue {
+		cr.Status.PinnedImage = r.pinnedImage
+	}
+	meta.SetStatusCondition(&cr.Status.Conditions, *r.imageCondition)
+}
+
+// imageConditionCurrent is true when the ImageVerified condition of the status is the result of the image policy.
+func (r *reconcileRequest) imageConditionCurrent(cr *searchv1alpha1.SearchOperator) bool {
+	if r.imageCondition == nil {
+		return meta.FindStatusCondition(cr.Status.Conditions, conditionImageVerified) == nil
+	}
+	return meta.IsStatusConditionPresentAndEqual(cr.Status.Conditions, conditionImageVerified, r.imageCondition.Status)
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	appv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testRedisgraphImage = "quay.io/stolostron/redisgraph-tls:2.5.0"
+
+// fakeRegistry is the local stand-in for a registry, it resolves the tags it knows.
+type fakeRegistry struct {
+	digests map[string]string
+	creds   *RegistryCredentials
+	calls   int
+}
+
+func (r *fakeRegistry) Digest(ctx context.Context, ref ImageReference, creds *RegistryCredentials) (string, error) {
+	r.calls++
+	r.creds = creds
+	if digest, ok := r.digests[ref.Name+":"+ref.Tag]; ok {
+		return digest, nil
+	}
+	return "", errors.New("manifest unknown")
+}
+
+type fakeVerifier struct {
+	verified []string
+	err      error
+}
+
+func (v *fakeVerifier) Verify(ctx context.Context, image string) error {
+	v.verified = append(v.verified, image)
+	return v.err
+}
+
+func imagePolicyOperator(policy *searchv1alpha1.ImagePolicy) *searchv1alpha1.SearchOperator {
+	operator := commonSetup().srchOperator.DeepCopy()
+	operator.Spec.SearchImageOverrides.Redisgraph_TLS = testRedisgraphImage
+	operator.Spec.ImagePolicy = policy
+	return operator
+}
+
+func TestCheckImageWithoutPolicy(t *testing.T) {
+	operator := imagePolicyOperator(nil)
+	reconciler := SearchOperatorReconciler{Client: fake.NewFakeClientWithScheme(commonSetup().scheme)}
+	request := reconciler.newRequest(testRequest)
+
+	assert.Nil(t, request.checkImage(context.TODO(), operator))
+	assert.Equal(t, "", request.pinnedImage, "Expected the image not to be pinned without a policy.")
+	assert.Nil(t, request.imageCondition)
+}
+
+func TestCheckImagePinsDigest(t *testing.T) {
+	testSetup := commonSetup()
+	operator := imagePolicyOperator(&searchv1alpha1.ImagePolicy{PinDigest: true,
+		AllowedRegistries: []string{"quay.io/stolostron"}})
+	operator.Spec.PullSecret = "pull-secret"
+	pullSecret := testSetup.secret.DeepCopy()
+	pullSecret.Name = "pull-secret"
+	pullSecret.Data = map[string][]byte{".dockerconfigjson": []byte(`{"auths":{"quay.io":{"auth":"cm9ib3Q6c2VjcmV0"}}}`)}
+	registry := &fakeRegistry{digests: map[string]string{testRedisgraphImage: testDigest}}
+	verifier := &fakeVerifier{}
+	operator.Spec.ImagePolicy.VerifySignature = true
+	reconciler := SearchOperatorReconciler{Client: fake.NewFakeClientWithScheme(testSetup.scheme, pullSecret),
+		Registry: registry, Verifier: verifier}
+	request := reconciler.newRequest(testSetup.request)
+
+	assert.Nil(t, request.checkImage(context.TODO(), operator))
+	pinned := "quay.io/stolostron/redisgraph-tls@" + testDigest
+	assert.Equal(t, pinned, request.pinnedImage)
+	assert.Equal(t, &RegistryCredentials{Username: "robot", Password: "secret"}, registry.creds,
+		"Expected the credentials of the pull secret.")
+	assert.Equal(t, []string{pinned}, verifier.verified, "Expected the pinned image to be verified.")
+	assert.Equal(t, metav1.ConditionTrue, request.imageCondition.Status)
+}
+
+func TestCheckImageRejected(t *testing.T) {
+	tests := []struct {
+		policy   *searchv1alpha1.ImagePolicy
+		registry RegistryClient
+		verifier ImageVerifier
+		reason   string
+		retry    bool
+	}{
+		{policy: &searchv1alpha1.ImagePolicy{AllowedRegistries: []string{"registry.redhat.io"}},
+			reason: "RegistryNotAllowed"},
+		{policy: &searchv1alpha1.ImagePolicy{AllowedRegistries: []string{"quay.io/stolostron-dev"}},
+			reason: "RegistryNotAllowed"},
+		{policy: &searchv1alpha1.ImagePolicy{PinDigest: true}, reason: "ResolveFailed"},
+		{policy: &searchv1alpha1.ImagePolicy{PinDigest: true}, registry: &fakeRegistry{}, reason: "ResolveFailed",
+			retry: true},
+		{policy: &searchv1alpha1.ImagePolicy{VerifySignature: true}, reason: "VerificationFailed"},
+		{policy: &searchv1alpha1.ImagePolicy{VerifySignature: true},
+			verifier: &fakeVerifier{err: errors.New("no matching signatures")}, reason: "VerificationFailed",
+			retry: true},
+	}
+	for _, test := range tests {
+		operator := imagePolicyOperator(test.policy)
+		reconciler := SearchOperatorReconciler{Client: fake.NewFakeClientWithScheme(commonSetup().scheme),
+			Registry: test.registry, Verifier: test.verifier}
+		request := reconciler.newRequest(testRequest)
+
+		err := request.checkImage(context.TODO(), operator)
+		assert.NotNil(t, err, "Expected the image to be rejected with %s.", test.reason)
+		assert.Equal(t, test.reason, request.imageCondition.Reason)
+		assert.Equal(t, metav1.ConditionFalse, request.imageCondition.Status)
+		assert.Equal(t, test.retry, imageRetryResult(err) != 0, "Expected %s to be retried: %v", test.reason,
+			test.retry)
+		assert.Equal(t, "", request.pinnedImage)
+	}
+}
+
+func TestReconcilePinsImage(t *testing.T) {
+	testSetup := commonSetup()
+	operator := imagePolicyOperator(&searchv1alpha1.ImagePolicy{PinDigest: true})
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, operator, testSetup.secret, testSetup.pvc,
+		testSetup.podWithPVC)
+	registry := &fakeRegistry{digests: map[string]string{testRedisgraphImage: testDigest}}
+	reconciler := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme, Registry: registry}
+
+	_, err := reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected reconcile to succeed. Got error: %v", err)
+
+	pinned := "quay.io/stolostron/redisgraph-tls@" + testDigest
+	sset := &appv1.StatefulSet{}
+	_ = client.Get(context.TODO(), testSetup.request.NamespacedName, operator)
+	err = client.Get(context.TODO(), types.NamespacedName{Name: testNames.StatefulSet, Namespace: testNamespace}, sset)
+	assert.Nil(t, err, "Expected the StatefulSet to be created. Got error: %v", err)
+	assert.Equal(t, pinned, sset.Spec.Template.Spec.Containers[0].Image, "Expected the StatefulSet to run the digest.")
+	assert.Equal(t, pinned, operator.Status.PinnedImage, "Expected the pinned image in the status.")
+	assert.True(t, meta.IsStatusConditionTrue(operator.Status.Conditions, conditionImageVerified))
+
+	// The pinned image is reused until the spec changes
+	_, err = reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected reconcile to succeed. Got error: %v", err)
+	assert.Equal(t, 1, registry.calls, "Expected the tag to be resolved once.")
+	_ = client.Get(context.TODO(), types.NamespacedName{Name: testNames.StatefulSet, Namespace: testNamespace}, sset)
+	assert.Equal(t, pinned, sset.Spec.Template.Spec.Containers[0].Image, "Expected the StatefulSet to be kept.")
+
+	// A tag that can't be resolved isn't rolled out, the pinned image keeps running.
+	// The fake client doesn't bump the generation like the API server does.
+	_ = client.Get(context.TODO(), testSetup.request.NamespacedName, operator)
+	operator.Spec.SearchImageOverrides.Redisgraph_TLS = "quay.io/stolostron/redisgraph-tls:2.6.0"
+	operator.Spec.NodeSelector = map[string]string{"node-role.kubernetes.io/infra": ""}
+	operator.Generation++
+	assert.Nil(t, client.Update(context.TODO(), operator))
+	result, err := reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected reconcile to succeed. Got error: %v", err)
+	assert.Equal(t, imageCheckRetryInterval, result.RequeueAfter, "Expected the image to be resolved again.")
+	assert.Equal(t, 2, registry.calls, "Expected the new tag to be resolved.")
+	_ = client.Get(context.TODO(), types.NamespacedName{Name: testNames.StatefulSet, Namespace: testNamespace}, sset)
+	assert.Equal(t, pinned, sset.Spec.Template.Spec.Containers[0].Image, "Expected the image to be kept.")
+	assert.Equal(t, operator.Spec.NodeSelector, sset.Spec.Template.Spec.NodeSelector,
+		"Expected the rest of the StatefulSet to be reconciled.")
+	_ = client.Get(context.TODO(), testSetup.request.NamespacedName, operator)
+	assert.Equal(t, pinned, operator.Status.PinnedImage, "Expected the running image to be reported.")
+	condition := meta.FindStatusCondition(operator.Status.Conditions, conditionImageVerified)
+	assert.Equal(t, "ResolveFailed", condition.Reason)
+}
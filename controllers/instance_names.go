@@ -0,0 +1,136 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stolostron/search-operator/render"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// defaultInstanceName is the SearchOperator created during install, its objects keep their original names
+	defaultInstanceName = render.DefaultInstanceName
+	// searchOperatorLabel links a SearchCustomization to a SearchOperator when searchOperatorRef isn't set
+	searchOperatorLabel = render.InstanceLabel
+	conditionMatched    = "Matched"
+)
+
+// storageClassPvcName is the name of the PVC created on a user provided storageClass.
+func (r *reconcileRequest) storageClassPvcName(storageClass string) string {
+	return r.names.StorageClassPVC(storageClass)
+}
+
+// redisgraphSelector selects the redisgraph pods of the instance being reconciled.
+func (r *reconcileRequest) redisgraphSelector() client.MatchingLabelsSelector {
+	return client.MatchingLabelsSelector{Selector: r.names.PodListSelector()}
+}
+
+// customizationTarget returns the name of the SearchOperator instance the customization applies to.
+func customizationTarget(custom client.Object) string {
+	if cr, ok := custom.(*searchv1alpha1.SearchCustomization); ok && cr.Spec.SearchOperatorRef != "" {
+		return cr.Spec.SearchOperatorRef
+	}
+	if name := custom.GetLabels()[searchOperatorLabel]; name != "" {
+		return name
+	}
+	return defaultInstanceName
+}
+
+// findCustomization returns the SearchCustomization that applies to the SearchOperator instance.
+// If several match, the first one by name is used. A NotFound error is returned if none match.
+func (r *SearchOperatorReconciler) findCustomization(ctx context.Context, cr *searchv1alpha1.SearchOperator) (
+	*searchv1alpha1.SearchCustomization, error) {
+	list := &searchv1alpha1.SearchCustomizationList{}
+	if err := r.Client.List(ctx, list, client.InNamespace(cr.Namespace)); err != nil {
+		return &searchv1alpha1.SearchCustomization{}, err
+	}
+	matches := []searchv1alpha1.SearchCustomization{}
+	for _, item := range list.Items {
+		if customizationTarget(&item) == cr.Name {
+			matches = append(matches, item)
+		}
+	}
+	if len(matches) == 0 {
+		return &searchv1alpha1.SearchCustomization{}, errors.NewNotFound(
+			searchv1alpha1.GroupVersion.WithResource("searchcustomizations").GroupResource(), cr.Name)
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Name < matches[j].Name })
+	if len(matches) > 1 {
+		logf.FromContext(ctx).Info("Multiple SearchCustomizations match the SearchOperator, using the first",
+			"searchCustomization", matches[0].Name, "matches", len(matches))
+	}
+	return &matches[0], nil
+}
+
+// markUnmatchedCustomizations sets the Matched condition to false on the customizations
+// that reference a SearchOperator instance that doesn't exist.
+func (r *SearchOperatorReconciler) markUnmatchedCustomizations(ctx context.Context, namespace, name string) error {
+	log := logf.FromContext(ctx)
+	list := &searchv1alpha1.SearchCustomizationList{}
+	if err := r.Client.List(ctx, list, client.InNamespace(namespace)); err != nil {
+		return err
+	}
+	for i := range list.Items {
+		custom := &list.Items[i]
+		if customizationTarget(custom) != name {
+			continue
+		}
+		condition := metav1.Condition{
+			Type:               conditionMatched,
+			Status:             metav1.ConditionFalse,
+			Reason:             "SearchOperatorNotFound",
+			Message:            fmt.Sprintf("SearchOperator %s not found in namespace %s", name, namespace),
+			ObservedGeneration: custom.Generation,
+		}
+		if meta.IsStatusConditionPresentAndEqual(custom.Status.Conditions, conditionMatched, condition.Status) {
+			continue
+		}
+		meta.SetStatusCondition(&custom.Status.Conditions, condition)
+		if err := r.Client.Status().Update(ctx, custom); err != nil {
+			return err
+		}
+		log.Info("SearchCustomization doesn't match a SearchOperator", "searchCustomization", custom.Name,
+			"searchOperator", name)
+	}
+	return nil
+}
+
+// setMatchedCondition records on the customization the SearchOperator instance it's applied to.
+func (r *reconcileRequest) setMatchedCondition(custom *searchv1alpha1.SearchCustomization) {
+	meta.SetStatusCondition(&custom.Status.Conditions, metav1.Condition{
+		Type:               conditionMatched,
+		Status:             metav1.ConditionTrue,
+		Reason:             "SearchOperatorFound",
+		Message:            fmt.Sprintf("Applied to SearchOperator %s", r.names.Instance),
+		ObservedGeneration: custom.Generation,
+	})
+}
+
+// rejectInstanceName reports on the SearchOperator that its name is reserved, none of its objects are created.
+func (r *reconcileRequest) rejectInstanceName(ctx context.Context, cr *searchv1alpha1.SearchOperator,
+	nameErr error) error {
+	condition := metav1.Condition{
+		Type:               conditionAvailable,
+		Status:             metav1.ConditionFalse,
+		Reason:             "InvalidName",
+		Message:            nameErr.Error(),
+		ObservedGeneration: cr.Generation,
+	}
+	if cr.Status.PersistenceStatus == statusInvalidName &&
+		meta.IsStatusConditionPresentAndEqual(cr.Status.Conditions, conditionAvailable, condition.Status) {
+		return nil
+	}
+	cr.Status.PersistenceStatus = statusInvalidName
+	meta.SetStatusCondition(&cr.Status.Conditions, condition)
+	return r.Client.Status().Update(ctx, cr)
+}
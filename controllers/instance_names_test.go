@@ -0,0 +1,150 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestInstanceNames(t *testing.T) {
+	reconciler := &SearchOperatorReconciler{}
+	request := reconciler.newRequest(testRequest)
+	assert.Equal(t, "search-redisgraph", request.names.StatefulSet)
+	assert.Equal(t, "redisgraph-user-secret", request.names.RedisSecret)
+	assert.Equal(t, "search-redisgraph-pvc-0", request.pvcName)
+	assert.Equal(t, "gp2-search-redisgraph-0", request.storageClassPvcName("gp2"))
+	assert.NotContains(t, request.names.PodSelector(), searchOperatorLabel,
+		"Expected default instance to keep its selector.")
+	assert.False(t, request.redisgraphSelector().Matches(labels.Set{"app": "search", "component": "redisgraph",
+		searchOperatorLabel: "my-search"}), "Expected the default instance to leave out the pods of named instances.")
+
+	request = reconciler.newRequest(reconcile.Request{NamespacedName: types.NamespacedName{Name: "my-search",
+		Namespace: testNamespace}})
+	assert.Equal(t, "my-search-redisgraph", request.names.StatefulSet)
+	assert.Equal(t, "my-search-redisgraph-user-secret", request.names.RedisSecret)
+	assert.Equal(t, "my-search-redisgraph-pvc-0", request.pvcName)
+	assert.Equal(t, "gp2-my-search-redisgraph-0", request.storageClassPvcName("gp2"))
+	assert.Equal(t, "my-search", request.names.PodSelector()[searchOperatorLabel])
+	assert.True(t, request.redisgraphSelector().Matches(labels.Set{"app": "search", "component": "redisgraph",
+		searchOperatorLabel: "my-search"}))
+}
+
+func TestCustomizationTarget(t *testing.T) {
+	custom := createFakeSearchCustomizationCR("test-cluster", true)
+	assert.Equal(t, defaultInstanceName, customizationTarget(custom), "Expected default instance without a reference.")
+
+	custom.Labels = map[string]string{searchOperatorLabel: "labeled"}
+	assert.Equal(t, "labeled", customizationTarget(custom), "Expected instance from the label.")
+
+	custom.Spec.SearchOperatorRef = "referenced"
+	assert.Equal(t, "referenced", customizationTarget(custom), "Expected spec reference to take precedence.")
+}
+
+func Test_ReconcileNamedInstance(t *testing.T) {
+	testSetup := commonSetup()
+	operator := testSetup.srchOperator.DeepCopy()
+	operator.Name = "my-search"
+	custom := createFakeSearchCustomizationCR(testNamespace, false)
+	custom.Name = "my-customization"
+	custom.Spec.SearchOperatorRef = operator.Name
+	// The customization for the default instance must not be applied to my-search.
+	defaultCustom := createFakeSearchCustomizationCR(testNamespace, true)
+	pod := createFakeRedisGraphPod(testNamespace, false, true)
+	pod.Labels[searchOperatorLabel] = operator.Name
+
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, operator, custom, defaultCustom, pod)
+	reconciler := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: operator.Name, Namespace: testNamespace}}
+
+	_, err := reconciler.Reconcile(testSetup.context, req)
+	assert.Nil(t, err, "Expected reconcile to complete successfully. Got error: %v", err)
+
+	sts := &appv1.StatefulSet{}
+	err = client.Get(context.TODO(), types.NamespacedName{Name: "my-search-redisgraph", Namespace: testNamespace}, sts)
+	assert.Nil(t, err, "Expected statefulset named after the instance. Got error: %v", err)
+	assert.Equal(t, operator.Name, sts.Spec.Selector.MatchLabels[searchOperatorLabel])
+	assert.Equal(t, operator.Name, sts.Spec.Template.Labels[searchOperatorLabel])
+
+	secret := &corev1.Secret{}
+	err = client.Get(context.TODO(), types.NamespacedName{Name: "my-search-redisgraph-user-secret",
+		Namespace: testNamespace}, secret)
+	assert.Nil(t, err, "Expected secret named after the instance. Got error: %v", err)
+
+	instance := &searchv1alpha1.SearchOperator{}
+	err = client.Get(context.TODO(), req.NamespacedName, instance)
+	assert.Nil(t, err, "Expected search Operator to be found. Got error: %v", err)
+	assert.Equal(t, statusNoPersistence, instance.Status.PersistenceStatus,
+		"Expected persistence disabled by the referencing customization.")
+
+	found := &searchv1alpha1.SearchCustomization{}
+	err = client.Get(context.TODO(), types.NamespacedName{Name: custom.Name, Namespace: testNamespace}, found)
+	assert.Nil(t, err, "Expected search customization to be found. Got error: %v", err)
+	assert.True(t, meta.IsStatusConditionTrue(found.Status.Conditions, conditionMatched),
+		"Expected Matched condition on the referencing customization.")
+	err = client.Get(context.TODO(), types.NamespacedName{Name: defaultCustom.Name, Namespace: testNamespace}, found)
+	assert.Nil(t, err, "Expected search customization to be found. Got error: %v", err)
+	assert.Nil(t, meta.FindStatusCondition(found.Status.Conditions, conditionMatched),
+		"Expected the default customization to be left alone.")
+}
+
+func Test_ReconcileReservedInstanceName(t *testing.T) {
+	testSetup := commonSetup()
+	operator := testSetup.srchOperator.DeepCopy()
+	operator.Name = "search"
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, operator, testSetup.secret)
+	reconciler := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: operator.Name, Namespace: testNamespace}}
+	secret := &corev1.Secret{}
+	_ = client.Get(context.TODO(), types.NamespacedName{Name: testNames.RedisSecret, Namespace: testNamespace}, secret)
+
+	_, err := reconciler.Reconcile(testSetup.context, req)
+	assert.Nil(t, err, "Expected reconcile to complete successfully. Got error: %v", err)
+
+	err = client.Get(context.TODO(), types.NamespacedName{Name: testNames.StatefulSet, Namespace: testNamespace},
+		&appv1.StatefulSet{})
+	assert.True(t, errors.IsNotFound(err), "Expected the StatefulSet of the default instance not to be created.")
+	found := &corev1.Secret{}
+	_ = client.Get(context.TODO(), types.NamespacedName{Name: testNames.RedisSecret, Namespace: testNamespace}, found)
+	assert.Equal(t, secret.ResourceVersion, found.ResourceVersion,
+		"Expected the secret of the default instance to be left alone.")
+	updated := &searchv1alpha1.SearchOperator{}
+	_ = client.Get(context.TODO(), req.NamespacedName, updated)
+	assert.Equal(t, statusInvalidName, updated.Status.PersistenceStatus)
+	available := meta.FindStatusCondition(updated.Status.Conditions, conditionAvailable)
+	assert.NotNil(t, available, "Expected the Available condition.")
+	assert.Equal(t, "InvalidName", available.Reason)
+}
+
+func Test_CustomizationMatchesNoOperator(t *testing.T) {
+	testSetup := commonSetup()
+	custom := createFakeSearchCustomizationCR(testNamespace, true)
+	custom.Spec.SearchOperatorRef = "missing"
+
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, custom)
+	reconciler := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "missing", Namespace: testNamespace}}
+
+	_, err := reconciler.Reconcile(testSetup.context, req)
+	assert.Nil(t, err, "Expected reconcile to complete successfully. Got error: %v", err)
+
+	found := &searchv1alpha1.SearchCustomization{}
+	err = client.Get(context.TODO(), types.NamespacedName{Name: custom.Name, Namespace: testNamespace}, found)
+	assert.Nil(t, err, "Expected search customization to be found. Got error: %v", err)
+	matched := meta.FindStatusCondition(found.Status.Conditions, conditionMatched)
+	assert.NotNil(t, matched, "Expected Matched condition.")
+	assert.Equal(t, "SearchOperatorNotFound", matched.Reason)
+	assert.Equal(t, "False", string(matched.Status))
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	appv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	annotationPaused  = "search.open-cluster-management.io/paused"
+	conditionPaused   = "Paused"
+	statusMaintenance = "Redisgraph scaled to zero for maintenance"
+)
+
+// isPaused returns true if the operator must not change any resource owned by the instance.
+func isPaused(cr client.Object) bool {
+	if operator, ok := cr.(*searchv1alpha1.SearchOperator); ok && operator.Spec.Paused {
+		return true
+	}
+	return cr.GetAnnotations()[annotationPaused] == "true"
+}
+
+// pausedChanged returns true if the object was paused or resumed. The annotation doesn't change the generation.
+func pausedChanged(old, new client.Object) bool {
+	return old.GetAnnotations()[annotationPaused] != new.GetAnnotations()[annotationPaused]
+}
+
+// setPausedCondition reports whether the instance is paused.
+func setPausedCondition(cr *searchv1alpha1.SearchOperator) {
+	condition := metav1.Condition{
+		Type:               conditionPaused,
+		Status:             metav1.ConditionFalse,
+		Reason:             "Reconciling",
+		Message:            "Resources are managed by the operator",
+		ObservedGeneration: cr.Generation,
+	}
+	if isPaused(cr) {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "Paused"
+		condition.Message = "Resources are not changed while the SearchOperator is paused"
+	}
+	meta.SetStatusCondition(&cr.Status.Conditions, condition)
+}
+
+// observedStatus returns the persistence status to report while paused, without changing any resource.
+func (r *reconcileRequest) observedStatus(ctx context.Context, cr *searchv1alpha1.SearchOperator) string {
+	sts := &appv1.StatefulSet{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: r.names.StatefulSet, Namespace: r.namespace}, sts)
+	switch {
+	case err != nil:
+		return redisNotRunning
+	case sts.Spec.Replicas != nil && *sts.Spec.Replicas == 0:
+		return statusMaintenance
+	case r.isPodRunning(ctx, true, 1) || r.isPodRunning(ctx, false, 1):
+		return cr.Status.PersistenceStatus
+	}
+	return redisNotRunning
+}
+
+// scaleRedisToZero stops redisgraph by scaling its StatefulSet to zero. The StatefulSet and PVC are kept.
+func (r *reconcileRequest) scaleRedisToZero(ctx context.Context, kclient client.Client) error {
+	log := logf.FromContext(ctx)
+	sts := &appv1.StatefulSet{}
+	err := kclient.Get(ctx, types.NamespacedName{Name: r.names.StatefulSet, Namespace: r.namespace}, sts)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if sts.Spec.Replicas != nil && *sts.Spec.Replicas == 0 {
+		log.V(1).Info("Redisgraph already scaled to zero", "statefulSet", r.names.StatefulSet)
+		return nil
+	}
+	sts.Spec.Replicas = int32Ptr(0)
+	if err = kclient.Update(ctx, sts); err != nil {
+		return err
+	}
+	log.Info("Scaled redisgraph to zero for maintenance", "statefulSet", r.names.StatefulSet)
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestIsPaused(t *testing.T) {
+	cr := &searchv1alpha1.SearchOperator{}
+	assert.False(t, isPaused(cr), "Expected instance not to be paused by default.")
+
+	cr.Spec.Paused = true
+	assert.True(t, isPaused(cr), "Expected spec.paused to pause the instance.")
+
+	annotated := &searchv1alpha1.SearchOperator{}
+	annotated.Annotations = map[string]string{annotationPaused: "true"}
+	assert.True(t, isPaused(annotated), "Expected the annotation to pause the instance.")
+	assert.True(t, pausedChanged(&searchv1alpha1.SearchOperator{}, annotated))
+	assert.False(t, pausedChanged(annotated, annotated.DeepCopy()))
+}
+
+func Test_PausedReconcileDoesNotChangeResources(t *testing.T) {
+	testSetup := commonSetup()
+	instance := testSetup.srchOperator.DeepCopy()
+	instance.Spec.Paused = true
+	// The customization disables persistence, which would normally replace the statefulset.
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, instance, testSetup.pvc, testSetup.podWithPVC,
+		createFakeSearchCustomizationCR(testNamespace, false))
+	reconciler := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
+	request := reconciler.newRequest(testSetup.request)
+	request.executeDeployment(context.TODO(), client, instance, true, true)
+
+	_, err := reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected paused reconcile to complete successfully. Got error: %v", err)
+
+	sts := &appv1.StatefulSet{}
+	err = client.Get(context.TODO(), types.NamespacedName{Name: testNames.StatefulSet, Namespace: testNamespace}, sts)
+	assert.Nil(t, err, "Expected statefulset to be kept. Got error: %v", err)
+	for _, container := range sts.Spec.Template.Spec.Containers {
+		for _, env := range container.Env {
+			if env.Name == "SAVERDB" {
+				assert.Equal(t, "true", env.Value, "Expected statefulset not to be updated while paused.")
+			}
+		}
+	}
+	err = client.Get(context.TODO(), types.NamespacedName{Name: testNames.RedisSecret, Namespace: testNamespace}, &corev1.Secret{})
+	assert.True(t, errors.IsNotFound(err), "Expected secret not to be created while paused.")
+
+	found := &searchv1alpha1.SearchOperator{}
+	err = client.Get(context.TODO(), testSetup.request.NamespacedName, found)
+	assert.Nil(t, err, "Expected search Operator to be found. Got error: %v", err)
+	assert.True(t, meta.IsStatusConditionTrue(found.Status.Conditions, conditionPaused),
+		"Expected Paused condition to be true.")
+}
+
+func Test_MaintenanceScalesToZeroKeepingPVC(t *testing.T) {
+	testSetup := commonSetup()
+	instance := testSetup.srchOperator.DeepCopy()
+	instance.Spec.Maintenance = true
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, instance, testSetup.secret, testSetup.pvc,
+		testSetup.podWithPVC)
+	reconciler := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
+	request := reconciler.newRequest(testSetup.request)
+	request.executeDeployment(context.TODO(), client, instance, true, true)
+
+	_, err := reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected maintenance reconcile to complete successfully. Got error: %v", err)
+
+	sts := &appv1.StatefulSet{}
+	err = client.Get(context.TODO(), types.NamespacedName{Name: testNames.StatefulSet, Namespace: testNamespace}, sts)
+	assert.Nil(t, err, "Expected statefulset to be kept. Got error: %v", err)
+	assert.Equal(t, int32(0), *sts.Spec.Replicas, "Expected redisgraph scaled to zero.")
+	err = client.Get(context.TODO(), types.NamespacedName{Name: testNames.DefaultPVC, Namespace: testNamespace},
+		&corev1.PersistentVolumeClaim{})
+	assert.Nil(t, err, "Expected PVC to be kept. Got error: %v", err)
+
+	found := &searchv1alpha1.SearchOperator{}
+	err = client.Get(context.TODO(), testSetup.request.NamespacedName, found)
+	assert.Nil(t, err, "Expected search Operator to be found. Got error: %v", err)
+	assert.Equal(t, statusMaintenance, found.Status.PersistenceStatus)
+	assert.False(t, meta.IsStatusConditionTrue(found.Status.Conditions, conditionAvailable),
+		"Expected Available condition to be false during maintenance.")
+
+	// Leaving maintenance scales redisgraph back up with the same PVC.
+	found.Spec.Maintenance = false
+	err = client.Update(context.TODO(), found)
+	assert.Nil(t, err, "Expected search Operator to be updated. Got error: %v", err)
+	_, err = reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected reconcile to complete successfully. Got error: %v", err)
+
+	err = client.Get(context.TODO(), types.NamespacedName{Name: testNames.StatefulSet, Namespace: testNamespace}, sts)
+	assert.Nil(t, err, "Expected statefulset to be found. Got error: %v", err)
+	assert.Equal(t, int32(1), *sts.Spec.Replicas, "Expected redisgraph scaled back up.")
+	err = client.Get(context.TODO(), testSetup.request.NamespacedName, found)
+	assert.Nil(t, err, "Expected search Operator to be found. Got error: %v", err)
+	assert.Equal(t, statusUsingPVC, found.Status.PersistenceStatus)
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"os"
+	"reflect"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stolostron/search-operator/render"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// operatorNamespace is the namespace the operator runs in, its pods are admitted to redisgraph by the NetworkPolicy
+var operatorNamespace = os.Getenv("POD_NAMESPACE")
+
+// networkPolicyWanted is true if the NetworkPolicy is enabled and the operator deploys redisgraph for the instance.
+func (r *reconcileRequest) networkPolicyWanted(cr *searchv1alpha1.SearchOperator) bool {
+	return render.NetworkPolicyEnabled(cr) && r.deployEnabled && externalDatabase(cr) == nil
+}
+
+// expectedNetworkPolicy returns the NetworkPolicy of the redisgraph pods of the instance.
+func (r *reconcileRequest) expectedNetworkPolicy(cr *searchv1alpha1.SearchOperator) *networkingv1.NetworkPolicy {
+	policy := render.NetworkPolicy(cr, r.names, operatorNamespace)
+	policy.Namespace = r.namespace
+	return policy
+}
+
+// networkPolicyNeedsUpdate is true if the found NetworkPolicy differs from the expected one in what the operator owns.
+func networkPolicyNeedsUpdate(found, expected *networkingv1.NetworkPolicy) bool {
+	return !reflect.DeepEqual(found.Spec, expected.Spec) || !reflect.DeepEqual(found.Labels, expected.Labels) ||
+		!reflect.DeepEqual(found.OwnerReferences, expected.OwnerReferences)
+}
+
+// reconcileNetworkPolicy creates or updates the NetworkPolicy in front of redisgraph when it's enabled,
+// and deletes it otherwise.
+func (r *reconcileRequest) reconcileNetworkPolicy(ctx context.Context,
+	cr *searchv1alpha1.SearchOperator) error {
+	log := logf.FromContext(ctx, "networkPolicy", r.names.StatefulSet)
+	found := &networkingv1.NetworkPolicy{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: r.names.StatefulSet, Namespace: r.namespace}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+	if !r.networkPolicyWanted(cr) {
+		if !exists {
+			return nil
+		}
+		if err = r.Client.Delete(ctx, found); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		log.Info("NetworkPolicy deleted")
+		return nil
+	}
+	expected := r.expectedNetworkPolicy(cr)
+	if !exists {
+		if err = r.Client.Create(ctx, expected); err != nil {
+			return err
+		}
+		log.Info("NetworkPolicy created")
+		return nil
+	}
+	if !networkPolicyNeedsUpdate(found, expected) {
+		log.V(1).Info("No changes required for NetworkPolicy")
+		return nil
+	}
+	found.Labels = expected.Labels
+	found.OwnerReferences = expected.OwnerReferences
+	found.Spec = expected.Spec
+	if err = r.Client.Update(ctx, found); err != nil {
+		return err
+	}
+	log.Info("NetworkPolicy updated")
+	return nil
+}
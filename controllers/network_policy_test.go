@@ -0,0 +1,54 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileNetworkPolicy(t *testing.T) {
+	testSetup := commonSetup()
+	instance := testSetup.srchOperator.DeepCopy()
+	instance.Spec.NetworkPolicy = &searchv1alpha1.NetworkPolicySpec{Enabled: true}
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, instance, testSetup.secret, testSetup.pvc,
+		testSetup.podWithPVC)
+	reconciler := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
+	request := reconciler.newRequest(testSetup.request)
+
+	_, err := reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected reconcile to succeed. Got error: %v", err)
+	policy := &networkingv1.NetworkPolicy{}
+	key := types.NamespacedName{Name: testNames.StatefulSet, Namespace: testNamespace}
+	err = client.Get(context.TODO(), key, policy)
+	assert.Nil(t, err, "Expected the NetworkPolicy to be created. Got error: %v", err)
+	assert.Len(t, policy.Spec.Ingress[0].From, 4, "Expected the search components and the operator to be admitted.")
+	assert.Equal(t, instance.Name, policy.OwnerReferences[0].Name, "Expected the SearchOperator to own the policy.")
+
+	// Changing the clients updates the policy
+	_ = client.Get(context.TODO(), testSetup.request.NamespacedName, instance)
+	instance.Spec.NetworkPolicy.Clients = []searchv1alpha1.NetworkPolicyClient{
+		{Name: "search-api", MatchLabels: map[string]string{"app": "search-api"}},
+	}
+	assert.Nil(t, client.Update(context.TODO(), instance))
+	plan, err := request.planNetworkPolicy(context.TODO(), instance)
+	assert.Nil(t, err)
+	assert.Len(t, plan, 1, "Expected the update to be planned.")
+	assert.Nil(t, request.reconcileNetworkPolicy(context.TODO(), instance))
+	_ = client.Get(context.TODO(), key, policy)
+	assert.Len(t, policy.Spec.Ingress[0].From, 2, "Expected the client of the spec and the operator.")
+
+	// Disabling the policy deletes it
+	instance.Spec.NetworkPolicy.Enabled = false
+	assert.Nil(t, request.reconcileNetworkPolicy(context.TODO(), instance))
+	err = client.Get(context.TODO(), key, policy)
+	assert.True(t, errors.IsNotFound(err), "Expected the NetworkPolicy to be deleted. Got %v", err)
+}
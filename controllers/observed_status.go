@@ -0,0 +1,98 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// effectiveConfig returns the configuration in use for the instance being reconciled, with the defaults resolved.
+func (r *reconcileRequest) effectiveConfig() *searchv1alpha1.EffectiveConfig {
+	return &searchv1alpha1.EffectiveConfig{
+		Persistence:         r.persistence,
+		AllowDegrade:        r.allowdegrade,
+		StorageClass:        r.storageClass,
+		StorageSize:         r.storageSize,
+		PVCName:             r.pvcName,
+		StatefulSetName:     r.names.StatefulSet,
+		SearchCustomization: r.customizationName,
+	}
+}
+
+// observeRedisgraph returns the images running in the redisgraph pods, with the digests reported by the container
+// runtime, and the revisions of the StatefulSet. Both are empty when redisgraph isn't deployed.
+func (r *reconcileRequest) observeRedisgraph(ctx context.Context, kclient client.Client) ([]searchv1alpha1.ImageStatus,
+	*searchv1alpha1.StatefulSetRevision) {
+	log := logf.FromContext(ctx)
+	var revision *searchv1alpha1.StatefulSetRevision
+	sset := &appv1.StatefulSet{}
+	err := kclient.Get(ctx, types.NamespacedName{Name: r.names.StatefulSet, Namespace: r.namespace}, sset)
+	if err == nil {
+		revision = &searchv1alpha1.StatefulSetRevision{
+			Name:            sset.Name,
+			CurrentRevision: sset.Status.CurrentRevision,
+			UpdateRevision:  sset.Status.UpdateRevision,
+		}
+	} else if client.IgnoreNotFound(err) != nil {
+		log.Error(err, "Error getting statefulset", "statefulSet", r.names.StatefulSet)
+	}
+
+	pods := &corev1.PodList{}
+	if err = kclient.List(ctx, pods, client.InNamespace(r.namespace),
+		r.redisgraphSelector()); err != nil {
+		log.Error(err, "Error listing redisgraph pods")
+		return nil, revision
+	}
+	var images []searchv1alpha1.ImageStatus
+	for _, pod := range pods.Items {
+		if !pod.DeletionTimestamp.IsZero() {
+			continue
+		}
+		for _, container := range pod.Status.ContainerStatuses {
+			images = append(images, searchv1alpha1.ImageStatus{
+				Container: container.Name,
+				Image:     container.Image,
+				ImageID:   container.ImageID,
+			})
+		}
+	}
+	sort.Slice(images, func(i, j int) bool {
+		if images[i].Container != images[j].Container {
+			return images[i].Container < images[j].Container
+		}
+		return images[i].ImageID < images[j].ImageID
+	})
+	return images, revision
+}
+
+// setObservedStatus sets the generation, the effective configuration and what's observed of redisgraph.
+func (r *reconcileRequest) setObservedStatus(ctx context.Context, kclient client.Client,
+	cr *searchv1alpha1.SearchOperator, generation int64) {
+	cr.Status.ObservedGeneration = generation
+	cr.Status.Effective = r.effectiveConfig()
+	cr.Status.Images, cr.Status.StatefulSet = r.observeRedisgraph(ctx, kclient)
+}
+
+// observedStatusCurrent is true when the status was written for the current generation with the current
+// configuration, no upgrade or ACL result needs to be recorded, and the images and revisions of redisgraph haven't
+// changed since.
+func (r *reconcileRequest) observedStatusCurrent(ctx context.Context, kclient client.Client,
+	cr *searchv1alpha1.SearchOperator) bool {
+	if cr.Status.ObservedGeneration != cr.Generation || !reflect.DeepEqual(cr.Status.Effective, r.effectiveConfig()) ||
+		cr.Status.PinnedImage != r.pinnedImage || !r.imageConditionCurrent(cr) || r.upgradeRecord != nil ||
+		!r.aclConditionCurrent(cr) {
+		return false
+	}
+	images, revision := r.observeRedisgraph(ctx, kclient)
+	return reflect.DeepEqual(cr.Status.Images, images) && reflect.DeepEqual(cr.Status.StatefulSet, revision)
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const redisgraphImageID = "quay.io/stolostron/redisgraph-tls@sha256:0123456789abcdef"
+
+func runningRedisgraph() (*appv1.StatefulSet, *corev1.Pod) {
+	sset := &appv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: testNames.StatefulSet, Namespace: testNamespace},
+		Status: appv1.StatefulSetStatus{CurrentRevision: "search-redisgraph-5d4b9c",
+			UpdateRevision: "search-redisgraph-7f8a2e"},
+	}
+	pod := createFakeRedisGraphPod(testNamespace, true, true)
+	pod.Name = testNames.StatefulSet + "-0"
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "redisgraph", Ready: true,
+		Image: "quay.io/stolostron/redisgraph-tls:2.5.0", ImageID: redisgraphImageID}}
+	return sset, pod
+}
+
+func TestEffectiveConfigDefaults(t *testing.T) {
+	request := (&SearchOperatorReconciler{}).newRequest(testRequest)
+
+	assert.Equal(t, &searchv1alpha1.EffectiveConfig{Persistence: true, AllowDegrade: true, StorageSize: "10Gi",
+		PVCName: "search-redisgraph-pvc-0", StatefulSetName: "search-redisgraph"}, request.effectiveConfig(),
+		"Expected the defaults to be resolved.")
+
+	custom := createFakeSearchCustomizationCR(testNamespace, true)
+	custom.Spec.StorageClass = "gp2"
+	request.setCustomValues(custom)
+	effective := request.effectiveConfig()
+	assert.False(t, effective.AllowDegrade, "Expected no fallback to an emptyDir with a customization.")
+	assert.Equal(t, "gp2", effective.StorageClass)
+	assert.Equal(t, "1Gi", effective.StorageSize)
+	assert.Equal(t, request.storageClassPvcName("gp2"), effective.PVCName, "Expected the derived PVC name.")
+	assert.Equal(t, custom.Name, effective.SearchCustomization)
+}
+
+func TestObserveRedisgraph(t *testing.T) {
+	testSetup := commonSetup()
+	request := (&SearchOperatorReconciler{}).newRequest(testSetup.request)
+	images, revision := request.observeRedisgraph(context.TODO(), fake.NewFakeClientWithScheme(testSetup.scheme))
+	assert.Nil(t, images, "Expected no images when redisgraph isn't deployed.")
+	assert.Nil(t, revision, "Expected no revision when redisgraph isn't deployed.")
+
+	sset, pod := runningRedisgraph()
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, sset, pod)
+	images, revision = request.observeRedisgraph(context.TODO(), client)
+	assert.Equal(t, []searchv1alpha1.ImageStatus{{Container: "redisgraph",
+		Image: "quay.io/stolostron/redisgraph-tls:2.5.0", ImageID: redisgraphImageID}}, images)
+	assert.Equal(t, &searchv1alpha1.StatefulSetRevision{Name: testNames.StatefulSet,
+		CurrentRevision: "search-redisgraph-5d4b9c", UpdateRevision: "search-redisgraph-7f8a2e"}, revision)
+}
+
+func TestUpdateCRsReportsObservedStatus(t *testing.T) {
+	testSetup := commonSetup()
+	request := (&SearchOperatorReconciler{}).newRequest(testSetup.request)
+	operator := testSetup.srchOperator.DeepCopy()
+	operator.Generation = 3
+	custom := createFakeSearchCustomizationCR(testNamespace, true)
+	custom.Generation = 2
+	sset, pod := runningRedisgraph()
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, operator, custom, sset, pod)
+
+	err := request.updateCRs(context.TODO(), client, operator, statusUsingPVC, custom, true, "", "10Gi", true)
+	assert.Nil(t, err, "Expected CR statuses to be updated successfully. Got error: %v", err)
+
+	updated := &searchv1alpha1.SearchOperator{}
+	_ = client.Get(context.TODO(), testSetup.request.NamespacedName, updated)
+	assert.Equal(t, int64(3), updated.Status.ObservedGeneration)
+	assert.Equal(t, request.effectiveConfig(), updated.Status.Effective)
+	assert.Equal(t, redisgraphImageID, updated.Status.Images[0].ImageID, "Expected the digest of the running image.")
+	assert.Equal(t, "search-redisgraph-7f8a2e", updated.Status.StatefulSet.UpdateRevision)
+	assert.True(t, request.observedStatusCurrent(context.TODO(), client, updated), "Expected the status to be current.")
+
+	updatedCustom := &searchv1alpha1.SearchCustomization{}
+	_ = client.Get(context.TODO(), types.NamespacedName{Name: custom.Name, Namespace: testNamespace}, updatedCustom)
+	assert.Equal(t, int64(2), updatedCustom.Status.ObservedGeneration)
+	assert.Equal(t, "10Gi", updatedCustom.Status.StorageSize)
+	assert.Equal(t, "search-redisgraph-pvc-0", updatedCustom.Status.PVCName)
+}
+
+func TestObservedStatusCurrent(t *testing.T) {
+	testSetup := commonSetup()
+	request := (&SearchOperatorReconciler{}).newRequest(testSetup.request)
+	sset, pod := runningRedisgraph()
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, sset, pod)
+	operator := testSetup.srchOperator.DeepCopy()
+	operator.Generation = 2
+	request.setObservedStatus(context.TODO(), client, operator, 2)
+	assert.True(t, request.observedStatusCurrent(context.TODO(), client, operator))
+
+	operator.Generation = 3
+	assert.False(t, request.observedStatusCurrent(context.TODO(), client, operator), "Expected a new generation to be reported.")
+
+	operator.Generation = 2
+	sset.Status.CurrentRevision = sset.Status.UpdateRevision
+	assert.Nil(t, client.Status().Update(context.TODO(), sset))
+	assert.False(t, request.observedStatusCurrent(context.TODO(), client, operator), "Expected a new revision to be reported.")
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	passwordCharsetAlphanumeric        = "Alphanumeric"
+	passwordCharsetAlphanumericSymbols = "AlphanumericSymbols"
+	passwordCharsetHex                 = "Hex"
+	defaultPasswordLength              = 16
+	defaultACLUserName                 = "search"
+	// annotationGeneratedAt on the redisgraph user secret is when the operator last generated a password in it
+	annotationGeneratedAt = "search.open-cluster-management.io/generated-at"
+	// Keys of the redisgraph user secret
+	secretKeyPassword    = "redispwd"
+	secretKeyACLUser     = "redisuser"
+	secretKeyACLPassword = "redisuserpwd"
+	alphanumericChars    = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+// passwordCharsets are the characters of the generated passwords for each charset. The symbols are the unreserved
+// characters of a URL, which don't need quoting in a shell either.
+var passwordCharsets = map[string]string{
+	passwordCharsetAlphanumeric:        alphanumericChars,
+	passwordCharsetAlphanumericSymbols: alphanumericChars + "-._~",
+	passwordCharsetHex:                 "0123456789abcdef",
+}
+
+// defaultACLCategories and defaultACLCommands are what the ACL user can run by default, the dangerous category
+// is always denied
+var (
+	defaultACLCategories = []string{"read", "write", "connection"}
+	defaultACLCommands   = []string{"graph.query", "graph.ro_query"}
+)
+
+// randReader is the entropy source of the generated passwords
+var randReader io.Reader = rand.Reader
+
+// generatePass returns a random password of length characters of the charset.
+func generatePass(length int, charset string) ([]byte, error) {
+	chars, ok := passwordCharsets[charset]
+	if !ok {
+		return nil, fmt.Errorf("unknown password charset %q", charset)
+	}
+	buf := make([]byte, length)
+	for i := 0; i < length; i++ {
+		nBig, err := rand.Int(randReader, big.NewInt(int64(len(chars))))
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate password: %w", err)
+		}
+		buf[i] = chars[nBig.Int64()]
+	}
+	return buf, nil
+}
+
+// generatePassword returns a password following the password policy of the SearchOperator.
+func generatePassword(cr *searchv1alpha1.SearchOperator) ([]byte, error) {
+	length, charset := defaultPasswordLength, passwordCharsetAlphanumeric
+	if policy := cr.Spec.PasswordPolicy; policy != nil {
+		if policy.Length > 0 {
+			length = policy.Length
+		}
+		if policy.Charset != "" {
+			charset = policy.Charset
+		}
+	}
+	return generatePass(length, charset)
+}
+
+// aclUserSpec returns the ACL user of the search components, nil if the SearchOperator doesn't create one.
+func aclUserSpec(cr *searchv1alpha1.SearchOperator) *searchv1alpha1.ACLUserSpec {
+	if cr.Spec.PasswordPolicy == nil {
+		return nil
+	}
+	return cr.Spec.PasswordPolicy.ACLUser
+}
+
+// aclUserName returns the name of the ACL user, empty if the SearchOperator doesn't create one.
+func aclUserName(cr *searchv1alpha1.SearchOperator) string {
+	spec := aclUserSpec(cr)
+	if spec == nil {
+		return ""
+	}
+	if spec.Name == "" {
+		return defaultACLUserName
+	}
+	return spec.Name
+}
+
+// aclUserRules returns the rules of ACL SETUSER for the ACL user with the password. Only the hash of the password
+// is sent to Redis. The dangerous category is denied last, so no command of the spec can allow it.
+func aclUserRules(spec *searchv1alpha1.ACLUserSpec, password []byte) []string {
+	rules := passwordRules(password)
+	categories, commands := defaultACLCategories, defaultACLCommands
+	if len(spec.Categories) > 0 {
+		categories = spec.Categories
+	}
+	if len(spec.Commands) > 0 {
+		commands = spec.Commands
+	}
+	for _, category := range categories {
+		rules = append(rules, "+@"+category)
+	}
+	for _, command := range commands {
+		rules = append(rules, "+"+command)
+	}
+	return append(rules, "-@dangerous")
+}
+
+// setGeneratedAt records on the secret that a password was generated now.
+func setGeneratedAt(secret *corev1.Secret) {
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[annotationGeneratedAt] = time.Now().UTC().Format(time.RFC3339)
+}
+
+// syncACLCredentials adds the name and a generated password of the ACL user to the redisgraph user secret, or
+// removes them when the SearchOperator has no ACL user. It returns true if the secret changed.
+func syncACLCredentials(cr *searchv1alpha1.SearchOperator, secret *corev1.Secret) (bool, error) {
+	name := aclUserName(cr)
+	if name == "" {
+		_, hasUser := secret.Data[secretKeyACLUser]
+		_, hasPassword := secret.Data[secretKeyACLPassword]
+		delete(secret.Data, secretKeyACLUser)
+		delete(secret.Data, secretKeyACLPassword)
+		return hasUser || hasPassword, nil
+	}
+	if string(secret.Data[secretKeyACLUser]) == name && len(secret.Data[secretKeyACLPassword]) > 0 {
+		return false, nil
+	}
+	password, err := generatePassword(cr)
+	if err != nil {
+		return false, err
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[secretKeyACLUser] = []byte(name)
+	secret.Data[secretKeyACLPassword] = password
+	setGeneratedAt(secret)
+	return true, nil
+}
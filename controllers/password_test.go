@@ -0,0 +1,107 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("entropy source unavailable")
+}
+
+func TestGeneratePass(t *testing.T) {
+	for charset, chars := range passwordCharsets {
+		password, err := generatePass(32, charset)
+		assert.Nil(t, err, "Expected a %s password. Got error: %v", charset, err)
+		assert.Len(t, password, 32)
+		for _, c := range password {
+			assert.True(t, strings.ContainsRune(chars, rune(c)), "Expected %q to be in the %s charset.", c, charset)
+		}
+	}
+
+	_, err := generatePass(16, "Emoji")
+	assert.NotNil(t, err, "Expected an unknown charset to fail.")
+
+	saved := randReader
+	randReader = failingReader{}
+	defer func() { randReader = saved }()
+	password, err := generatePass(16, passwordCharsetAlphanumeric)
+	assert.NotNil(t, err, "Expected the failure of the entropy source to be returned.")
+	assert.Nil(t, password)
+}
+
+func TestGeneratePassword(t *testing.T) {
+	operator := commonSetup().srchOperator.DeepCopy()
+	password, err := generatePassword(operator)
+	assert.Nil(t, err)
+	assert.Len(t, password, defaultPasswordLength, "Expected the default length without a policy.")
+
+	operator.Spec.PasswordPolicy = &searchv1alpha1.PasswordPolicy{Length: 40, Charset: passwordCharsetHex}
+	password, err = generatePassword(operator)
+	assert.Nil(t, err)
+	assert.Len(t, password, 40, "Expected the length of the policy.")
+	assert.Empty(t, strings.Trim(string(password), passwordCharsets[passwordCharsetHex]),
+		"Expected a hex password.")
+}
+
+func TestACLUserRules(t *testing.T) {
+	rules := aclUserRules(&searchv1alpha1.ACLUserSpec{}, []byte("secret"))
+	assert.Equal(t, []string{"reset", "on",
+		"#2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b", "~*", "-@all",
+		"+@read", "+@write", "+@connection", "+graph.query", "+graph.ro_query", "-@dangerous"}, rules,
+		"Expected the default categories and commands with the hash of the password.")
+
+	rules = aclUserRules(&searchv1alpha1.ACLUserSpec{Categories: []string{"read"}, Commands: []string{"flushall"}},
+		[]byte("secret"))
+	assert.Equal(t, []string{"+@read", "+flushall", "-@dangerous"}, rules[5:],
+		"Expected the categories and commands of the spec, with the dangerous category denied last.")
+}
+
+func TestSyncACLCredentials(t *testing.T) {
+	operator := commonSetup().srchOperator.DeepCopy()
+	secret, err := (&SearchOperatorReconciler{}).newRequest(testRequest).newRedisSecret(operator)
+	assert.Nil(t, err)
+	assert.Len(t, secret.Data[secretKeyPassword], defaultPasswordLength)
+	generatedAt, err := time.Parse(time.RFC3339, secret.Annotations[annotationGeneratedAt])
+	assert.Nil(t, err, "Expected the generation time on the secret. Got error: %v", err)
+	assert.WithinDuration(t, time.Now(), generatedAt, time.Minute)
+	assert.NotContains(t, secret.Data, secretKeyACLUser, "Expected no ACL user without a policy.")
+
+	operator.Spec.PasswordPolicy = &searchv1alpha1.PasswordPolicy{ACLUser: &searchv1alpha1.ACLUserSpec{}}
+	secret.Annotations = nil
+	changed, err := syncACLCredentials(operator, secret)
+	assert.Nil(t, err)
+	assert.True(t, changed, "Expected the ACL user to be added.")
+	assert.Equal(t, defaultACLUserName, string(secret.Data[secretKeyACLUser]))
+	assert.Len(t, secret.Data[secretKeyACLPassword], defaultPasswordLength)
+	assert.NotEmpty(t, secret.Annotations[annotationGeneratedAt], "Expected the generation time to be recorded.")
+
+	changed, err = syncACLCredentials(operator, secret)
+	assert.Nil(t, err)
+	assert.False(t, changed, "Expected the password of the ACL user to be kept.")
+
+	operator.Spec.PasswordPolicy.ACLUser = nil
+	changed, err = syncACLCredentials(operator, secret)
+	assert.Nil(t, err)
+	assert.True(t, changed, "Expected the ACL user to be removed.")
+	assert.Equal(t, []string{secretKeyPassword}, keys(secret))
+}
+
+func keys(secret *corev1.Secret) []string {
+	list := []string{}
+	for key := range secret.Data {
+		list = append(list, key)
+	}
+	return list
+}
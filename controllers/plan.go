@@ -0,0 +1,370 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stolostron/search-operator/render"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	annotationDryRun = "search.open-cluster-management.io/dry-run"
+	actionCreate     = "create"
+	actionUpdate     = "update"
+	actionDelete     = "delete"
+)
+
+// isDryRun returns true if the operator must only report the changes it would make.
+func isDryRun(cr client.Object) bool {
+	return cr.GetAnnotations()[annotationDryRun] == "true"
+}
+
+// dryRunChanged returns true if the dry-run annotation was set or removed.
+func dryRunChanged(old, new client.Object) bool {
+	return old.GetAnnotations()[annotationDryRun] != new.GetAnnotations()[annotationDryRun]
+}
+
+// planChanges computes the changes Reconcile would make to the resources of the instance, following the same
+// decisions on the database mode and persistence, without applying anything. Changes made by Reconcile when
+// redisgraph fails to start, like falling back to emptyDir and deleting the PVC, can't be known in advance.
+func (r *reconcileRequest) planChanges(ctx context.Context,
+	cr *searchv1alpha1.SearchOperator) (*searchv1alpha1.ReconcilePlan, error) {
+	changes := []searchv1alpha1.PlannedChange{}
+	secret := &corev1.Secret{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: r.names.RedisSecret, Namespace: r.namespace}, secret)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	} else if err != nil {
+		changes = append(changes, searchv1alpha1.PlannedChange{Action: actionCreate, Kind: "Secret",
+			Name: r.names.RedisSecret, Reason: "generate the redisgraph password"})
+	} else if changed, err := syncACLCredentials(cr, secret.DeepCopy()); err != nil {
+		return nil, err
+	} else if changed {
+		reason := "generate the password of the ACL user"
+		if aclUserSpec(cr) == nil {
+			reason = "remove the ACL user"
+		}
+		changes = append(changes, searchv1alpha1.PlannedChange{Action: actionUpdate, Kind: "Secret",
+			Name: r.names.RedisSecret, Reason: reason})
+	}
+	account := &corev1.ServiceAccount{}
+	err = r.Client.Get(ctx, types.NamespacedName{Name: r.names.ServiceAccount, Namespace: r.namespace}, account)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	} else if err != nil {
+		changes = append(changes, searchv1alpha1.PlannedChange{Action: actionCreate, Kind: "ServiceAccount",
+			Name: r.names.ServiceAccount, Reason: "run redisgraph without API permissions"})
+	} else if serviceAccountNeedsUpdate(account, r.expectedServiceAccount(cr)) {
+		changes = append(changes, searchv1alpha1.PlannedChange{Action: actionUpdate, Kind: "ServiceAccount",
+			Name: r.names.ServiceAccount, Reason: "restore the redisgraph ServiceAccount"})
+	}
+	userChanges, err := r.planComponentUsers(ctx, cr)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, userChanges...)
+	connectionChanges, err := r.planConnection(ctx, cr)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, connectionChanges...)
+	serviceChanges, err := r.planService(ctx, cr)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, serviceChanges...)
+	policyChanges, err := r.planNetworkPolicy(ctx, cr)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, policyChanges...)
+
+	sts := &appv1.StatefulSet{}
+	err = r.Client.Get(ctx, types.NamespacedName{Name: r.names.StatefulSet, Namespace: r.namespace}, sts)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	stsFound := err == nil
+	switch {
+	case !r.deployEnabled || externalDatabase(cr) != nil:
+		if stsFound {
+			reason := "the database is disabled"
+			if r.deployEnabled {
+				reason = "an external Redisgraph endpoint is used"
+			}
+			changes = append(changes, searchv1alpha1.PlannedChange{Action: actionDelete, Kind: "StatefulSet",
+				Name: r.names.StatefulSet, Destructive: !persistentVolume(sts), Reason: reason})
+		}
+	case cr.Spec.Maintenance:
+		if stsFound && (sts.Spec.Replicas == nil || *sts.Spec.Replicas != 0) {
+			changes = append(changes, searchv1alpha1.PlannedChange{Action: actionUpdate, Kind: "StatefulSet",
+				Name: r.names.StatefulSet, Destructive: !persistentVolume(sts), Reason: "scale to zero for maintenance"})
+		}
+	default:
+		// The StatefulSet keeps its image while the image policy rejects the image, and isn't created
+		if err = r.checkImage(ctx, cr); err != nil {
+			logf.FromContext(ctx).Info("Image policy rejects the redisgraph image", "reason", err.Error())
+			held, err := r.holdRunningImage(ctx)
+			if err != nil {
+				return nil, err
+			} else if !held {
+				break
+			}
+		} else {
+			r.setUpgradeState(cr)
+		}
+		volumeChanges, err := r.planVolumes(ctx, cr, sts, stsFound)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, volumeChanges...)
+	}
+	return newPlan(cr, changes), nil
+}
+
+// planConnection plans the changes to the connection secret and the dependents restarted when it changes.
+func (r *reconcileRequest) planConnection(ctx context.Context,
+	cr *searchv1alpha1.SearchOperator) ([]searchv1alpha1.PlannedChange, error) {
+	changes := []searchv1alpha1.PlannedChange{}
+	found := &corev1.Secret{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: r.names.ConnectionSecret, Namespace: r.namespace}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	foundSecret := err == nil
+	if !r.deployEnabled {
+		if foundSecret {
+			changes = append(changes, searchv1alpha1.PlannedChange{Action: actionDelete, Kind: "Secret",
+				Name: r.names.ConnectionSecret, Reason: "the database is disabled"})
+		}
+		return changes, nil
+	}
+	info, err := r.readConnection(ctx, cr)
+	if err != nil {
+		// The connection is published once the secrets it is read from exist
+		logf.FromContext(ctx).V(1).Info("Unable to read the Redisgraph connection", "error", err.Error())
+		return changes, nil
+	}
+	secret := info.connectionSecret(cr, r.names.ConnectionSecret)
+	switch {
+	case !foundSecret:
+		changes = append(changes, searchv1alpha1.PlannedChange{Action: actionCreate, Kind: "Secret",
+			Name: r.names.ConnectionSecret, Reason: "publish the Redisgraph connection"})
+	case found.Type != secret.Type:
+		changes = append(changes, searchv1alpha1.PlannedChange{Action: actionDelete, Kind: "Secret",
+			Name: r.names.ConnectionSecret, Reason: "the type of the connection Secret changed"},
+			searchv1alpha1.PlannedChange{Action: actionCreate, Kind: "Secret",
+				Name: r.names.ConnectionSecret, Reason: "publish the Redisgraph connection"})
+	case !reflect.DeepEqual(found.Data, secret.Data) || !reflect.DeepEqual(found.Labels, secret.Labels):
+		changes = append(changes, searchv1alpha1.PlannedChange{Action: actionUpdate, Kind: "Secret",
+			Name: r.names.ConnectionSecret, Reason: "the Redisgraph connection changed"})
+	}
+	applied := checksum(secret.Data)
+	for _, dependent := range dependentSelectors(cr) {
+		deployments, err := r.dependentDeployments(ctx, r.Client, dependent)
+		if err != nil {
+			return nil, err
+		}
+		for i := range deployments {
+			deployment := &deployments[i]
+			switch {
+			case !rolloutAllowed(deployment):
+			case r.restartPending || appliedChecksum(cr, deployment, applied) != applied:
+				changes = append(changes, searchv1alpha1.PlannedChange{Action: actionUpdate, Kind: "Deployment",
+					Name: deployment.Name, Reason: fmt.Sprintf("rolling restart of %s", dependent.Name)})
+			}
+		}
+	}
+	return changes, nil
+}
+
+// planService plans the changes to the Service in front of redisgraph.
+func (r *reconcileRequest) planService(ctx context.Context,
+	cr *searchv1alpha1.SearchOperator) ([]searchv1alpha1.PlannedChange, error) {
+	changes := []searchv1alpha1.PlannedChange{}
+	found := &corev1.Service{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: r.names.StatefulSet, Namespace: r.namespace}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	exists := err == nil
+	switch {
+	case !r.serviceWanted(cr):
+		if exists && metav1.IsControlledBy(found, cr) {
+			changes = append(changes, searchv1alpha1.PlannedChange{Action: actionDelete, Kind: "Service",
+				Name: r.names.StatefulSet, Reason: "redisgraph isn't deployed by the operator"})
+		}
+	case !exists:
+		changes = append(changes, searchv1alpha1.PlannedChange{Action: actionCreate, Kind: "Service",
+			Name: r.names.StatefulSet, Reason: "expose redisgraph at the host of the connection secret"})
+	case serviceNeedsUpdate(found, r.expectedService(cr)):
+		changes = append(changes, searchv1alpha1.PlannedChange{Action: actionUpdate, Kind: "Service",
+			Name: r.names.StatefulSet, Reason: "restore the redisgraph Service"})
+	}
+	return changes, nil
+}
+
+// planNetworkPolicy plans the changes to the NetworkPolicy in front of redisgraph.
+func (r *reconcileRequest) planNetworkPolicy(ctx context.Context,
+	cr *searchv1alpha1.SearchOperator) ([]searchv1alpha1.PlannedChange, error) {
+	changes := []searchv1alpha1.PlannedChange{}
+	found := &networkingv1.NetworkPolicy{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: r.names.StatefulSet, Namespace: r.namespace}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	exists := err == nil
+	switch {
+	case !r.networkPolicyWanted(cr) && exists:
+		reason := "the NetworkPolicy is disabled"
+		if render.NetworkPolicyEnabled(cr) {
+			reason = "redisgraph isn't deployed by the operator"
+		}
+		changes = append(changes, searchv1alpha1.PlannedChange{Action: actionDelete, Kind: "NetworkPolicy",
+			Name: r.names.StatefulSet, Reason: reason})
+	case !r.networkPolicyWanted(cr):
+	case !exists:
+		changes = append(changes, searchv1alpha1.PlannedChange{Action: actionCreate, Kind: "NetworkPolicy",
+			Name: r.names.StatefulSet, Reason: "only admit the search components and the operator to redisgraph"})
+	case networkPolicyNeedsUpdate(found, r.expectedNetworkPolicy(cr)):
+		changes = append(changes, searchv1alpha1.PlannedChange{Action: actionUpdate, Kind: "NetworkPolicy",
+			Name: r.names.StatefulSet, Reason: "the admitted clients changed"})
+	}
+	return changes, nil
+}
+
+// planVolumes plans the changes to the PVC and the StatefulSet for the persistence settings in use.
+func (r *reconcileRequest) planVolumes(ctx context.Context, cr *searchv1alpha1.SearchOperator,
+	sts *appv1.StatefulSet, stsFound bool) ([]searchv1alpha1.PlannedChange, error) {
+	changes := []searchv1alpha1.PlannedChange{}
+	usePVC := r.persistence
+	if r.persistence {
+		pvc := &corev1.PersistentVolumeClaim{}
+		err := r.Client.Get(ctx, types.NamespacedName{Name: r.pvcName, Namespace: r.namespace}, pvc)
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		}
+		// A redisgraph degraded to emptyDir is kept as long as it runs
+		degraded := r.expectedStatefulSet(ctx, r.Client, cr, false, r.persistence)
+		if r.allowdegrade && cr.Status.PersistenceStatus == statusDegradedEmptyDir && stsFound &&
+			!r.statefulSetNeedsUpdate(ctx, r.Client, degraded) {
+			usePVC = false
+		} else if err != nil {
+			changes = append(changes, searchv1alpha1.PlannedChange{Action: actionCreate,
+				Kind: "PersistentVolumeClaim", Name: r.pvcName,
+				Reason: fmt.Sprintf("storage class %q with size %s", r.storageClass, r.storageSize)})
+		}
+	}
+	expected := r.expectedStatefulSet(ctx, r.Client, cr, usePVC, r.persistence)
+	switch {
+	case !stsFound:
+		changes = append(changes, searchv1alpha1.PlannedChange{Action: actionCreate, Kind: "StatefulSet",
+			Name: r.names.StatefulSet, Reason: volumeDescription(expected)})
+	case r.statefulSetNeedsUpdate(ctx, r.Client, expected):
+		// Updating the StatefulSet restarts redisgraph, data is only kept when it stays on the same PVC
+		change := searchv1alpha1.PlannedChange{Action: actionUpdate, Kind: "StatefulSet", Name: r.names.StatefulSet,
+			Destructive: !persistentVolume(sts) || !reflect.DeepEqual(persistVolume(sts), persistVolume(expected)),
+			Reason:      "the redisgraph spec changed"}
+		before, after := containerImage(&sts.Spec.Template.Spec), containerImage(&expected.Spec.Template.Spec)
+		if r.Upgrader != nil && before != after && persistentVolume(sts) {
+			change.Reason = fmt.Sprintf("upgrade redisgraph from %s to %s, the data is saved and verified",
+				before, after)
+		}
+		if before, after := volumeDescription(sts), volumeDescription(expected); before != after {
+			change.Reason = fmt.Sprintf("data moves from %s to %s", before, after)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// persistVolume returns the volume redisgraph saves its data to, nil if persistence is disabled.
+func persistVolume(sts *appv1.StatefulSet) *corev1.VolumeSource {
+	for _, volume := range sts.Spec.Template.Spec.Volumes {
+		if volume.Name == "persist" {
+			return &volume.VolumeSource
+		}
+	}
+	return nil
+}
+
+// persistentVolume is true if the redisgraph data survives deleting the pods of the StatefulSet.
+func persistentVolume(sts *appv1.StatefulSet) bool {
+	volume := persistVolume(sts)
+	return volume != nil && volume.PersistentVolumeClaim != nil
+}
+
+func volumeDescription(sts *appv1.StatefulSet) string {
+	volume := persistVolume(sts)
+	switch {
+	case volume == nil:
+		return "no persistence"
+	case volume.PersistentVolumeClaim != nil:
+		return "PVC " + volume.PersistentVolumeClaim.ClaimName
+	}
+	return "emptyDir"
+}
+
+// newPlan returns the plan for the changes with its human readable summary.
+func newPlan(cr *searchv1alpha1.SearchOperator, changes []searchv1alpha1.PlannedChange) *searchv1alpha1.ReconcilePlan {
+	plan := &searchv1alpha1.ReconcilePlan{
+		GeneratedAt:        metav1.Now(),
+		ObservedGeneration: cr.Generation,
+		Changes:            changes,
+	}
+	lines := []string{}
+	for _, change := range changes {
+		line := fmt.Sprintf("%s %s %s", change.Action, change.Kind, change.Name)
+		if change.Destructive {
+			plan.Destructive = true
+			line += " (destructive)"
+		}
+		if change.Reason != "" {
+			line += ": " + change.Reason
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "no changes")
+	}
+	plan.Summary = strings.Join(lines, "\n")
+	return plan
+}
+
+// reportPlan computes the plan and writes it to the status. Nothing else is changed.
+func (r *reconcileRequest) reportPlan(ctx context.Context, cr *searchv1alpha1.SearchOperator) error {
+	log := logf.FromContext(ctx)
+	plan, err := r.planChanges(ctx, cr)
+	if err != nil {
+		return err
+	}
+	for _, change := range plan.Changes {
+		log.Info("Planned change", "action", change.Action, "kind", change.Kind, "resource", change.Name,
+			"destructive", change.Destructive, "reason", change.Reason)
+	}
+	found, err := fetchSrchOperator(ctx, r.Client, cr)
+	if err != nil {
+		return err
+	}
+	found.Status.Plan = plan
+	if err = r.Client.Status().Update(ctx, found); err != nil {
+		log.Error(err, "Failed to update SearchOperator plan", "name", cr.Name)
+		return err
+	}
+	log.Info("Dry run, updated SearchOperator plan", "name", cr.Name, "destructive", plan.Destructive)
+	return nil
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestIsDryRun(t *testing.T) {
+	cr := &searchv1alpha1.SearchOperator{}
+	assert.False(t, isDryRun(cr), "Expected changes to be applied by default.")
+
+	annotated := &searchv1alpha1.SearchOperator{}
+	annotated.Annotations = map[string]string{annotationDryRun: "true"}
+	assert.True(t, isDryRun(annotated), "Expected the annotation to enable dry run.")
+	assert.True(t, dryRunChanged(cr, annotated))
+	assert.False(t, dryRunChanged(annotated, annotated.DeepCopy()))
+}
+
+func TestNewPlan(t *testing.T) {
+	cr := &searchv1alpha1.SearchOperator{}
+	cr.Generation = 3
+	plan := newPlan(cr, []searchv1alpha1.PlannedChange{})
+	assert.False(t, plan.Destructive)
+	assert.Equal(t, "no changes", plan.Summary)
+	assert.Equal(t, int64(3), plan.ObservedGeneration)
+
+	plan = newPlan(cr, []searchv1alpha1.PlannedChange{
+		{Action: actionCreate, Kind: "Secret", Name: "test-secret"},
+		{Action: actionDelete, Kind: "StatefulSet", Name: "test-sts", Destructive: true, Reason: "test reason"},
+	})
+	assert.True(t, plan.Destructive, "Expected a destructive change to make the plan destructive.")
+	assert.Equal(t, "create Secret test-secret\ndelete StatefulSet test-sts (destructive): test reason", plan.Summary)
+}
+
+func Test_DryRunPlansWithoutChanging(t *testing.T) {
+	testSetup := commonSetup()
+	instance := testSetup.srchOperator.DeepCopy()
+	instance.Annotations = map[string]string{annotationDryRun: "true"}
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, instance, createFakeSearchCustomizationCR(testNamespace, true))
+	reconciler := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
+
+	_, err := reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected dry run reconcile to complete successfully. Got error: %v", err)
+
+	err = client.Get(context.TODO(), types.NamespacedName{Name: testNames.RedisSecret, Namespace: testNamespace}, &corev1.Secret{})
+	assert.True(t, errors.IsNotFound(err), "Expected secret not to be created in dry run.")
+	err = client.Get(context.TODO(), types.NamespacedName{Name: testNames.DefaultPVC, Namespace: testNamespace},
+		&corev1.PersistentVolumeClaim{})
+	assert.True(t, errors.IsNotFound(err), "Expected PVC not to be created in dry run.")
+	err = client.Get(context.TODO(), types.NamespacedName{Name: testNames.StatefulSet, Namespace: testNamespace},
+		&appv1.StatefulSet{})
+	assert.True(t, errors.IsNotFound(err), "Expected statefulset not to be created in dry run.")
+
+	found := &searchv1alpha1.SearchOperator{}
+	err = client.Get(context.TODO(), testSetup.request.NamespacedName, found)
+	assert.Nil(t, err, "Expected search Operator to be found. Got error: %v", err)
+	assert.NotNil(t, found.Status.Plan, "Expected the plan in the status.")
+	assert.False(t, found.Status.Plan.Destructive)
+	assert.Equal(t, []searchv1alpha1.PlannedChange{
+		{Action: actionCreate, Kind: "Secret", Name: testNames.RedisSecret, Reason: "generate the redisgraph password"},
+		{Action: actionCreate, Kind: "ServiceAccount", Name: testNames.ServiceAccount,
+			Reason: "run redisgraph without API permissions"},
+		{Action: actionCreate, Kind: "Service", Name: testNames.StatefulSet,
+			Reason: "expose redisgraph at the host of the connection secret"},
+		{Action: actionCreate, Kind: "PersistentVolumeClaim", Name: testNames.DefaultPVC,
+			Reason: `storage class "" with size 1Gi`},
+		{Action: actionCreate, Kind: "StatefulSet", Name: testNames.StatefulSet, Reason: "PVC " + testNames.DefaultPVC},
+	}, found.Status.Plan.Changes)
+	assert.Empty(t, found.Status.PersistenceStatus, "Expected only the plan to be written.")
+}
+
+func Test_DryRunReportsDestructiveChange(t *testing.T) {
+	testSetup := commonSetup()
+	instance := testSetup.srchOperator.DeepCopy()
+	instance.Annotations = map[string]string{annotationDryRun: "true"}
+	// The customization disables persistence, which replaces the PVC of the running statefulset.
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, instance, testSetup.secret, testSetup.pvc,
+		testSetup.podWithPVC, createFakeSearchCustomizationCR(testNamespace, false))
+	reconciler := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
+	request := reconciler.newRequest(testSetup.request)
+	request.executeDeployment(context.TODO(), client, instance, true, true)
+	before := &appv1.StatefulSet{}
+	err := client.Get(context.TODO(), types.NamespacedName{Name: testNames.StatefulSet, Namespace: testNamespace}, before)
+	assert.Nil(t, err, "Expected statefulset to be found. Got error: %v", err)
+
+	_, err = reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected dry run reconcile to complete successfully. Got error: %v", err)
+
+	after := &appv1.StatefulSet{}
+	err = client.Get(context.TODO(), types.NamespacedName{Name: testNames.StatefulSet, Namespace: testNamespace}, after)
+	assert.Nil(t, err, "Expected statefulset to be kept. Got error: %v", err)
+	assert.Equal(t, before.ResourceVersion, after.ResourceVersion, "Expected statefulset not to be updated.")
+
+	found := &searchv1alpha1.SearchOperator{}
+	err = client.Get(context.TODO(), testSetup.request.NamespacedName, found)
+	assert.Nil(t, err, "Expected search Operator to be found. Got error: %v", err)
+	assert.True(t, found.Status.Plan.Destructive, "Expected disabling persistence to be destructive.")
+	assert.Contains(t, found.Status.Plan.Summary, "update StatefulSet "+testNames.StatefulSet+
+		" (destructive): data moves from PVC "+testNames.DefaultPVC+" to no persistence")
+
+	// The plan is cleared once changes are applied again.
+	delete(found.Annotations, annotationDryRun)
+	err = client.Update(context.TODO(), found)
+	assert.Nil(t, err, "Expected search Operator to be updated. Got error: %v", err)
+	_, err = reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.NotNil(t, err, "Expected reconcile to fail without a redisgraph pod with persistence disabled.")
+	err = client.Get(context.TODO(), testSetup.request.NamespacedName, found)
+	assert.Nil(t, err, "Expected search Operator to be found. Got error: %v", err)
+	assert.Nil(t, found.Status.Plan, "Expected the plan to be cleared.")
+}
+
+func TestPlanACLCredentials(t *testing.T) {
+	testSetup := commonSetup()
+	instance := testSetup.srchOperator.DeepCopy()
+	instance.Spec.PasswordPolicy = &searchv1alpha1.PasswordPolicy{ACLUser: &searchv1alpha1.ACLUserSpec{}}
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, instance, testSetup.secret)
+	reconciler := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
+	request := reconciler.newRequest(testSetup.request)
+
+	plan, err := request.planChanges(context.TODO(), instance)
+	assert.Nil(t, err, "Expected the plan. Got error: %v", err)
+	assert.Contains(t, plan.Changes, searchv1alpha1.PlannedChange{Action: actionUpdate, Kind: "Secret",
+		Name: testNames.RedisSecret, Reason: "generate the password of the ACL user"})
+	secret := &corev1.Secret{}
+	_ = client.Get(context.TODO(), types.NamespacedName{Name: testNames.RedisSecret, Namespace: testNamespace}, secret)
+	assert.NotContains(t, secret.Data, secretKeyACLPassword, "Expected the secret not to be changed by the plan.")
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Reasons reported in the SearchOperator status when the Redisgraph pod is not running.
+const (
+	failureImagePull             = "ImagePullBackOff"
+	failureOOMKilled             = "OOMKilled"
+	failureCrashLoop             = "CrashLoopBackOff"
+	failurePVCPending            = "PVCPending"
+	failureFailedMount           = "FailedMount"
+	failureInsufficientResources = "InsufficientResources"
+	failureUnschedulable         = "Unschedulable"
+)
+
+// podFailure describes why the Redisgraph pod is not running.
+// An empty Reason means the cause could not be determined.
+type podFailure struct {
+	Reason  string
+	Message string
+}
+
+// storageRelated returns true if the failure is caused by the PVC or the volume mount, or if
+// the cause could not be determined. Falling back to EmptyDir doesn't help with any other failure.
+// A pod that is unschedulable for an unknown reason is most likely waiting on its PVC.
+func (f podFailure) storageRelated() bool {
+	switch f.Reason {
+	case "", failurePVCPending, failureFailedMount, failureUnschedulable:
+		return true
+	}
+	return false
+}
+
+func (f podFailure) String() string {
+	if f.Reason == "" {
+		return ""
+	}
+	if f.Message == "" {
+		return f.Reason
+	}
+	return fmt.Sprintf("%s: %s", f.Reason, f.Message)
+}
+
+// diagnosePod looks at the pod, its PVC and its events to find out why the pod is not running.
+func (r *SearchOperatorReconciler) diagnosePod(ctx context.Context, pod corev1.Pod) podFailure {
+	var pvc *corev1.PersistentVolumeClaim
+	for _, vol := range pod.Spec.Volumes {
+		if vol.PersistentVolumeClaim == nil {
+			continue
+		}
+		found := &corev1.PersistentVolumeClaim{}
+		err := r.Client.Get(ctx,
+			types.NamespacedName{Name: vol.PersistentVolumeClaim.ClaimName, Namespace: pod.Namespace}, found)
+		if err == nil {
+			pvc = found
+		}
+	}
+	var reader client.Reader = r.Client
+	if r.APIReader != nil {
+		reader = r.APIReader
+	}
+	events := &corev1.EventList{}
+	if err := reader.List(ctx, events, client.InNamespace(pod.Namespace),
+		client.MatchingFields{"involvedObject.kind": "Pod", "involvedObject.name": pod.Name}); err != nil {
+		logf.FromContext(ctx).Error(err, "Error listing events for redisgraph pod", "pod", pod.Name)
+	}
+	return classifyPodFailure(pod, events.Items, pvc)
+}
+
+// classifyPodFailure finds the reason a pod is not running from its container statuses,
+// its scheduling condition, the state of its PVC and the events recorded for it.
+func classifyPodFailure(pod corev1.Pod, events []corev1.Event, pvc *corev1.PersistentVolumeClaim) podFailure {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Terminated != nil && status.State.Terminated.Reason == "OOMKilled" {
+			return podFailure{failureOOMKilled, fmt.Sprintf("container %s was OOMKilled", status.Name)}
+		}
+		if status.State.Waiting == nil {
+			continue
+		}
+		switch status.State.Waiting.Reason {
+		case "ImagePullBackOff", "ErrImagePull", "InvalidImageName":
+			return podFailure{failureImagePull, status.State.Waiting.Message}
+		case "CrashLoopBackOff":
+			if status.LastTerminationState.Terminated != nil &&
+				status.LastTerminationState.Terminated.Reason == "OOMKilled" {
+				return podFailure{failureOOMKilled, fmt.Sprintf("container %s was OOMKilled", status.Name)}
+			}
+			return podFailure{failureCrashLoop, status.State.Waiting.Message}
+		}
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Reason != "Unschedulable" {
+			continue
+		}
+		return classifySchedulingMessage(condition.Message)
+	}
+	if pvc != nil && pvc.Status.Phase == corev1.ClaimPending {
+		return podFailure{failurePVCPending, fmt.Sprintf("PersistentVolumeClaim %s is pending", pvc.Name)}
+	}
+	for _, event := range events {
+		switch event.Reason {
+		case "FailedMount", "FailedAttachVolume":
+			return podFailure{failureFailedMount, event.Message}
+		case "FailedScheduling":
+			return classifySchedulingMessage(event.Message)
+		}
+	}
+	return podFailure{}
+}
+
+func classifySchedulingMessage(message string) podFailure {
+	lower := strings.ToLower(message)
+	switch {
+	case strings.Contains(lower, "insufficient"):
+		return podFailure{failureInsufficientResources, message}
+	case strings.Contains(lower, "persistentvolumeclaim"), strings.Contains(lower, "volume"):
+		return podFailure{failurePVCPending, message}
+	}
+	return podFailure{failureUnschedulable, message}
+}
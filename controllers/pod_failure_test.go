@@ -0,0 +1,141 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestClassifyPodFailure(t *testing.T) {
+	waiting := func(reason string) corev1.Pod {
+		return corev1.Pod{Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+			{Name: "redisgraph", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason}}},
+		}}}
+	}
+	unschedulable := func(message string) corev1.Pod {
+		return corev1.Pod{Status: corev1.PodStatus{Conditions: []corev1.PodCondition{
+			{Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: "Unschedulable", Message: message},
+		}}}
+	}
+	oomLooping := waiting("CrashLoopBackOff")
+	oomLooping.Status.ContainerStatuses[0].LastTerminationState.Terminated =
+		&corev1.ContainerStateTerminated{Reason: "OOMKilled"}
+	pendingPVC := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: testNames.DefaultPVC},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimPending},
+	}
+
+	tests := []struct {
+		name    string
+		pod     corev1.Pod
+		events  []corev1.Event
+		pvc     *corev1.PersistentVolumeClaim
+		reason  string
+		storage bool
+	}{
+		{"image pull", waiting("ErrImagePull"), nil, nil, failureImagePull, false},
+		{"crash loop", waiting("CrashLoopBackOff"), nil, nil, failureCrashLoop, false},
+		{"crash loop after OOM", oomLooping, nil, nil, failureOOMKilled, false},
+		{"insufficient memory", unschedulable("0/3 nodes are available: 3 Insufficient memory."), nil, nil,
+			failureInsufficientResources, false},
+		{"unbound PVC", unschedulable("pod has unbound immediate PersistentVolumeClaims."), nil, nil,
+			failurePVCPending, true},
+		{"unknown scheduling problem", unschedulable(""), nil, nil, failureUnschedulable, true},
+		{"pending PVC", corev1.Pod{}, nil, pendingPVC, failurePVCPending, true},
+		{"failed mount", corev1.Pod{}, []corev1.Event{{Reason: "FailedMount", Message: "timed out"}}, nil,
+			failureFailedMount, true},
+		{"still starting", corev1.Pod{}, nil, nil, "", true},
+	}
+	for _, test := range tests {
+		failure := classifyPodFailure(test.pod, test.events, test.pvc)
+		assert.Equal(t, test.reason, failure.Reason, "%s: unexpected failure reason", test.name)
+		assert.Equal(t, test.storage, failure.storageRelated(), "%s: unexpected storage classification", test.name)
+	}
+}
+
+func Test_CrashLoopingPodDoesNotDegrade(t *testing.T) {
+	testSetup := commonSetup()
+	req := testSetup.request
+
+	crashingPod := createFakeRedisGraphPod(testNamespace, true, true)
+	crashingPod.Name = "search-redisgraph-0"
+	crashingPod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{Name: "redisgraph", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}},
+	}
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, testSetup.srchOperator, testSetup.secret, testSetup.pvc, crashingPod)
+	nilSearchOperator := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
+
+	_, err := nilSearchOperator.Reconcile(testSetup.context, req)
+	assert.NotNil(t, err, "Expected Reconcile error to be not nil. Got nil.")
+
+	instance := &searchv1alpha1.SearchOperator{}
+	err = client.Get(context.TODO(), req.NamespacedName, instance)
+	assert.Nil(t, err, "Expected search Operator to be found. Got error: %v", err)
+	assert.Equal(t, statusFailedUsingPVC, instance.Status.PersistenceStatus,
+		"Search Operator should not degrade to EmptyDir when the pod is crash looping.")
+	assert.True(t, strings.HasPrefix(instance.Status.PodFailureReason, failureCrashLoop),
+		"Expected failure reason %s. Got %s", failureCrashLoop, instance.Status.PodFailureReason)
+
+	foundPVC := &corev1.PersistentVolumeClaim{}
+	err = client.Get(context.TODO(), types.NamespacedName{Name: testNames.DefaultPVC, Namespace: testNamespace}, foundPVC)
+	assert.Nil(t, err, "Expected PVC to be kept. Got error: %v", err)
+
+	foundStatefulset := &appv1.StatefulSet{}
+	err = client.Get(context.TODO(), types.NamespacedName{Name: testNames.StatefulSet, Namespace: testNamespace}, foundStatefulset)
+	assert.True(t, errors.IsNotFound(err), "Expected statefulset Not Found error. Got %v", err)
+}
+
+// eventsReader returns its events to lists filtered by the involved pod, like the API server.
+type eventsReader struct {
+	client.Reader
+	events []corev1.Event
+}
+
+func (r *eventsReader) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	listOpts := &client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	if listOpts.FieldSelector == nil {
+		return errors.NewBadRequest("expected a field selector on the events")
+	}
+	events := list.(*corev1.EventList)
+	for _, event := range r.events {
+		if listOpts.FieldSelector.Matches(fields.Set{"involvedObject.kind": event.InvolvedObject.Kind,
+			"involvedObject.name": event.InvolvedObject.Name}) {
+			events.Items = append(events.Items, event)
+		}
+	}
+	return nil
+}
+
+func TestDiagnosePodListsItsEvents(t *testing.T) {
+	testSetup := commonSetup()
+	pod := *testSetup.podWithPVC
+	pod.Status = corev1.PodStatus{}
+	reader := &eventsReader{events: []corev1.Event{
+		{InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "search-api-7c9d4"}, Reason: "FailedMount"},
+		{InvolvedObject: corev1.ObjectReference{Kind: "PersistentVolumeClaim", Name: pod.Name},
+			Reason: "FailedScheduling"},
+	}}
+	reconciler := SearchOperatorReconciler{Client: fake.NewFakeClientWithScheme(testSetup.scheme),
+		Scheme: testSetup.scheme, APIReader: reader}
+
+	assert.Equal(t, "", reconciler.diagnosePod(context.TODO(), pod).Reason,
+		"Expected the events of other objects not to be read.")
+	reader.events = append(reader.events, corev1.Event{
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: pod.Name}, Reason: "FailedMount"})
+	assert.Equal(t, failureFailedMount, reconciler.diagnosePod(context.TODO(), pod).Reason)
+}
@@ -0,0 +1,300 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	searchv1beta1 "github.com/stolostron/search-operator/api/v1beta1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+)
+
+// rolePath is the role generated from the kubebuilder:rbac markers: make manifests
+const rolePath = "../config/rbac/role.yaml"
+
+// leaderElectionRolePath is the role the manager uses for leader election
+const leaderElectionRolePath = "../config/rbac/leader_election_role.yaml"
+
+// deployRolePaths are the roles of the operator deployed from deploy/, with the rules of both roles
+var deployRolePaths = []string{"../deploy/role.yaml", "../deploy/cluster_role.yaml"}
+
+// irregularPlurals are the resources of the kinds the plural isn't guessed for
+var irregularPlurals = map[string]string{"Search": "searches"}
+
+// permission is a verb on a resource of an API group, as granted by a role.
+type permission struct {
+	group, resource, verb string
+}
+
+func (p permission) String() string {
+	return p.group + "/" + p.resource + ":" + p.verb
+}
+
+// recordingClient records the permissions the calls made through it require.
+type recordingClient struct {
+	client.Client
+	used map[permission]bool
+}
+
+func newRecordingClient(kclient client.Client) *recordingClient {
+	return &recordingClient{Client: kclient, used: map[permission]bool{}}
+}
+
+// recordingReader records the permissions of the reads made through it, which go to the API server.
+type recordingReader struct {
+	client.Reader
+	recorder *recordingClient
+}
+
+func (r *recordingReader) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	r.recorder.record(obj, "", "get")
+	return r.Reader.Get(ctx, key, obj)
+}
+
+func (r *recordingReader) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	r.recorder.record(list, "", "list")
+	return r.Reader.List(ctx, list, opts...)
+}
+
+// record adds the verbs on the resource of obj, with the subresource if it's set.
+func (c *recordingClient) record(obj runtime.Object, subresource string, verbs ...string) {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		panic(err)
+	}
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	c.recordKind(gvk, subresource, verbs...)
+}
+
+func (c *recordingClient) recordKind(gvk schema.GroupVersionKind, subresource string, verbs ...string) {
+	resource, _ := meta.UnsafeGuessKindToResource(gvk)
+	name := resource.Resource
+	if plural, ok := irregularPlurals[gvk.Kind]; ok {
+		name = plural
+	}
+	if subresource != "" {
+		name += "/" + subresource
+	}
+	for _, verb := range verbs {
+		c.used[permission{group: gvk.Group, resource: name, verb: verb}] = true
+	}
+}
+
+// recordOwners adds the update of the finalizers of the owners blocking the deletion of obj, which the API server
+// requires to set such an owner reference.
+func (c *recordingClient) recordOwners(obj client.Object) {
+	for _, owner := range obj.GetOwnerReferences() {
+		if owner.BlockOwnerDeletion != nil && *owner.BlockOwnerDeletion {
+			c.recordKind(schema.FromAPIVersionAndKind(owner.APIVersion, owner.Kind), "finalizers", "update")
+		}
+	}
+}
+
+// Get is served from the cache of the manager, which lists and watches the type.
+func (c *recordingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	c.record(obj, "", "get", "list", "watch")
+	return c.Client.Get(ctx, key, obj)
+}
+
+func (c *recordingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	c.record(list, "", "list", "watch")
+	return c.Client.List(ctx, list, opts...)
+}
+
+func (c *recordingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	c.record(obj, "", "create")
+	c.recordOwners(obj)
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *recordingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	c.record(obj, "", "update")
+	c.recordOwners(obj)
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *recordingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	opts ...client.PatchOption) error {
+	c.record(obj, "", "patch")
+	c.recordOwners(obj)
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *recordingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	c.record(obj, "", "delete")
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *recordingClient) Status() client.StatusWriter {
+	return &recordingStatusWriter{StatusWriter: c.Client.Status(), client: c}
+}
+
+// recordingStatusWriter records the permissions on the status subresource.
+type recordingStatusWriter struct {
+	client.StatusWriter
+	client *recordingClient
+}
+
+func (w *recordingStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	w.client.record(obj, "status", "update")
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+func (w *recordingStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	opts ...client.PatchOption) error {
+	w.client.record(obj, "status", "patch")
+	return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+}
+
+// grantedPermissions reads the permissions of the role at path.
+func grantedPermissions(t *testing.T, path string) map[permission]bool {
+	data, err := os.ReadFile(path) // #nosec G304
+	assert.Nil(t, err, "Expected the role %s. Got error: %v", path, err)
+	role := &rbacv1.ClusterRole{}
+	assert.Nil(t, yaml.Unmarshal(data, role), "Expected the role %s to parse.", path)
+	granted := map[permission]bool{}
+	for _, rule := range role.Rules {
+		for _, group := range rule.APIGroups {
+			for _, resource := range rule.Resources {
+				for _, verb := range rule.Verbs {
+					granted[permission{group: group, resource: resource, verb: verb}] = true
+				}
+			}
+		}
+	}
+	return granted
+}
+
+// missing returns the permissions of a that aren't in b.
+func missing(a, b map[permission]bool) []string {
+	list := []string{}
+	for p := range a {
+		if !b[p] {
+			list = append(list, p.String())
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+// TestRBACMatchesClientCalls runs the reconcilers through the paths that create, update and delete each of their
+// objects, and compares the permissions the calls require with the manager role.
+func TestRBACMatchesClientCalls(t *testing.T) {
+	testSetup := commonSetup()
+	ctx := context.TODO()
+	instance := testSetup.srchOperator.DeepCopy()
+	instance.Spec.NetworkPolicy = &searchv1alpha1.NetworkPolicySpec{Enabled: true}
+	api := dependentDeployment("search-api", map[string]string{"app": "search", "component": "search-api"})
+	apiPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "search-api-7c9d4", Namespace: testNamespace,
+		Labels: api.Spec.Template.Labels}}
+	kclient := newRecordingClient(fake.NewFakeClientWithScheme(testSetup.scheme, instance, testSetup.podWithPVC,
+		collectorDeployment(), api, apiPod, createFakeSearchCustomizationCR(testNamespace, true)))
+	reconciler := SearchOperatorReconciler{Client: kclient, Log: log, Scheme: testSetup.scheme,
+		APIReader: &recordingReader{Reader: kclient.Client, recorder: kclient}}
+	request := reconciler.newRequest(testSetup.request)
+
+	// Deploy redisgraph with its secret, ServiceAccount, NetworkPolicy, PVC and StatefulSet
+	_, err := reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected reconcile to succeed. Got error: %v", err)
+	// Restore the ServiceAccount, the Service and the NetworkPolicy, publish a changed connection and restart the dependents
+	account := &corev1.ServiceAccount{}
+	_ = kclient.Get(ctx, types.NamespacedName{Name: testNames.ServiceAccount, Namespace: testNamespace}, account)
+	account.Labels = nil
+	assert.Nil(t, kclient.Client.Update(ctx, account))
+	assert.Nil(t, request.setupServiceAccount(ctx, instance))
+	service := &corev1.Service{}
+	_ = kclient.Get(ctx, types.NamespacedName{Name: testNames.StatefulSet, Namespace: testNamespace}, service)
+	service.Spec.Selector = nil
+	assert.Nil(t, kclient.Client.Update(ctx, service))
+	assert.Nil(t, request.reconcileService(ctx, instance))
+	instance.Spec.NetworkPolicy.Clients = []searchv1alpha1.NetworkPolicyClient{
+		{Name: "search-api", MatchLabels: map[string]string{"app": "search-api"}},
+	}
+	assert.Nil(t, request.reconcileNetworkPolicy(ctx, instance))
+	connection := &corev1.Secret{}
+	_ = kclient.Get(ctx, types.NamespacedName{Name: testNames.ConnectionSecret, Namespace: testNamespace}, connection)
+	connection.Data = nil
+	assert.Nil(t, kclient.Client.Update(ctx, connection))
+	assert.Nil(t, request.publishConnection(ctx, instance))
+	request.restartSearchComponents(ctx, instance)
+	// Diagnose a pod that isn't running
+	reconciler.diagnosePod(ctx, *testSetup.podWithPVC)
+	// Scale down for maintenance, then fall back to an emptyDir and disable the database
+	assert.Nil(t, request.scaleRedisToZero(ctx, kclient))
+	assert.Nil(t, request.deleteRedisStatefulSet(ctx, kclient))
+	assert.Nil(t, request.deletePVC(ctx, kclient))
+	instance.Spec.NetworkPolicy.Enabled = false
+	assert.Nil(t, request.reconcileNetworkPolicy(ctx, instance))
+	assert.Nil(t, request.deleteConnectionSecret(ctx, kclient))
+	request.deployEnabled = false
+	assert.Nil(t, request.reconcileService(ctx, instance))
+	request.deployEnabled = true
+	// A deleted instance reports the customizations still referencing it
+	_, err = reconciler.Reconcile(testSetup.context, reconcile.Request{
+		NamespacedName: types.NamespacedName{Name: "deleted", Namespace: testNamespace}})
+	assert.Nil(t, err, "Expected reconcile to succeed. Got error: %v", err)
+
+	// Import a Search, project it, and drop the SearchCustomization when the Search doesn't set the storage
+	operator := testSetup.srchOperator.DeepCopy()
+	operator.Annotations = map[string]string{annotationImport: "true"}
+	searchClient := newRecordingClient(fake.NewFakeClientWithScheme(testSetup.scheme, operator,
+		createFakeSearchCustomizationCR(testNamespace, true)))
+	searchReconciler := SearchReconciler{Client: searchClient, Scheme: testSetup.scheme}
+	for i := 0; i < 2; i++ {
+		_, err = searchReconciler.Reconcile(testSetup.context, testSetup.request)
+		assert.Nil(t, err, "Expected reconcile to succeed. Got error: %v", err)
+	}
+	search := &searchv1beta1.Search{}
+	_ = searchClient.Get(ctx, testSetup.request.NamespacedName, search)
+	search.Spec.Storage = searchv1beta1.StorageSpec{}
+	assert.Nil(t, searchClient.Client.Update(ctx, search))
+	_, err = searchReconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected reconcile to succeed. Got error: %v", err)
+	searchReconciler.Client = newRecordingClient(fake.NewFakeClientWithScheme(testSetup.scheme,
+		testSearch(searchv1beta1.StorageSpec{Size: "5Gi"})))
+	_, err = searchReconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected reconcile to succeed. Got error: %v", err)
+
+	used := map[permission]bool{}
+	for _, c := range []*recordingClient{kclient, searchClient, searchReconciler.Client.(*recordingClient)} {
+		for p := range c.used {
+			used[p] = true
+		}
+	}
+	granted := grantedPermissions(t, rolePath)
+	assert.Empty(t, missing(used, granted), "Expected the manager role to grant every call of the reconcilers.")
+	assert.Empty(t, missing(granted, used), "Expected the manager role to only grant what the reconcilers use.")
+
+	// The deployed roles also grant leader election
+	leaderElection := grantedPermissions(t, leaderElectionRolePath)
+	for _, path := range deployRolePaths {
+		granted = grantedPermissions(t, path)
+		assert.Empty(t, missing(leaderElection, granted), "Expected %s to grant leader election.", path)
+		// configmaps are also read by the reconcilers, only drop what leader election alone needs
+		for p := range leaderElection {
+			if !used[p] {
+				delete(granted, p)
+			}
+		}
+		assert.Empty(t, missing(used, granted), "Expected %s to grant every call of the reconcilers.", path)
+		assert.Empty(t, missing(granted, used), "Expected %s to only grant what the reconcilers use.", path)
+	}
+}
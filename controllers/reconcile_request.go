@@ -0,0 +1,86 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"time"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stolostron/search-operator/render"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// reconcileRequest is the state of one reconcile of a SearchOperator instance. Each request gets its own, so
+// instances are reconciled concurrently without sharing the values in use or the results written to their status.
+type reconcileRequest struct {
+	*SearchOperatorReconciler
+
+	// namespace of the SearchOperator instance being reconciled, each instance gets its own redisgraph
+	namespace string
+	// names of the objects owned by the instance
+	names render.Names
+
+	// Persistence values in use, from the SearchCustomization or the defaults
+	pvcName      string
+	persistence  bool
+	allowdegrade bool
+	storageClass string
+	storageSize  string
+	startingSpec searchv1alpha1.SearchCustomizationSpec
+	// customizationName is the SearchCustomization the persistence values in use come from, empty for the defaults
+	customizationName string
+
+	// deployEnabled is true if redisgraph is deployed for the instance
+	deployEnabled bool
+	// restartPending is true while the search components still need to be restarted after enabling redisgraph
+	restartPending bool
+	deployStatus   bool
+
+	// redisPodFailure holds the reason the Redisgraph pod failed the last readiness check
+	redisPodFailure podFailure
+	// redisHealth holds the result of the last active health check, nil if it wasn't checked
+	redisHealth *searchv1alpha1.RedisHealthStatus
+	// bindingPublished is true if the connection secret of the instance is up to date
+	bindingPublished bool
+	// connectionChecksum is the checksum of the connection secret published for the instance
+	connectionChecksum string
+	// dependentsStatus is the rollout status of the dependents written to the SearchOperator status
+	dependentsStatus []searchv1alpha1.DependentStatus
+
+	// pinnedImage is the redisgraph image with its digest the StatefulSet runs, empty if it isn't pinned
+	pinnedImage string
+	// imageCondition is the result of the image policy, nil if the SearchOperator has none
+	imageCondition *metav1.Condition
+	// imageChecked is set once the image policy is applied, the status is kept when redisgraph isn't rolled out
+	imageChecked bool
+	// imageRetryAfter is how soon the image policy is applied again after it rejected the image
+	imageRetryAfter time.Duration
+
+	// upgradeRecord is the upgrade started or advanced by the reconcile, nil if there's nothing to record
+	upgradeRecord *searchv1alpha1.UpgradeRecord
+	// heldImage is the image the StatefulSet runs instead of the image in the spec: the image before the upgrade
+	// while the data is saved or after a failed upgrade, or the image being rolled out
+	heldImage string
+	// upgradeRequeueAfter is how soon an upgrade in progress is advanced again
+	upgradeRequeueAfter time.Duration
+
+	// aclCondition is the result of configuring the ACL users, nil if there are none
+	aclCondition *metav1.Condition
+	// aclChecked is set once the ACL users are configured, the status is kept when redisgraph isn't running
+	aclChecked bool
+}
+
+// newRequest returns the state of reconciling the SearchOperator of the request, with the default persistence
+// values and redisgraph deployed.
+func (r *SearchOperatorReconciler) newRequest(req ctrl.Request) *reconcileRequest {
+	request := &reconcileRequest{
+		SearchOperatorReconciler: r,
+		namespace:                req.Namespace,
+		names:                    render.NamesFor(req.Name),
+		deployEnabled:            true,
+	}
+	request.setCustomValues(nil)
+	return request
+}
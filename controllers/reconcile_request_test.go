@@ -0,0 +1,32 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestNewRequest(t *testing.T) {
+	reconciler := &SearchOperatorReconciler{}
+	request := reconciler.newRequest(testRequest)
+	assert.Equal(t, testNamespace, request.namespace)
+	assert.Equal(t, testNames, request.names)
+	assert.True(t, request.persistence, "Expected persistence by default.")
+	assert.True(t, request.allowdegrade)
+	assert.Equal(t, "10Gi", request.storageSize)
+	assert.True(t, request.deployEnabled, "Expected redisgraph to be deployed by default.")
+
+	// Each request has its own state
+	request.deployEnabled = false
+	request.pinnedImage = "quay.io/stolostron/redisgraph-tls@" + testDigest
+	other := reconciler.newRequest(reconcile.Request{NamespacedName: types.NamespacedName{Name: defaultInstanceName,
+		Namespace: "team-b"}})
+	assert.True(t, other.deployEnabled, "Expected the state of a request not to leak into another.")
+	assert.Equal(t, "", other.pinnedImage)
+	assert.Equal(t, "team-b", other.namespace)
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stolostron/search-operator/render"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	conditionACLConfigured = "ACLConfigured"
+	// defaultRedisUser is the user of requirepass, used by the operator and never deleted
+	defaultRedisUser = "default"
+)
+
+// RedisACLUser is a Redis ACL user with the rules of ACL SETUSER.
+type RedisACLUser struct {
+	Name  string
+	Rules []string
+}
+
+// RedisACLClient configures the ACL users of Redisgraph.
+type RedisACLClient interface {
+	// SetUsers creates or resets the users and deletes the other users, except the default user.
+	SetUsers(ctx context.Context, target RedisTarget, users []RedisACLUser) error
+}
+
+// TLSRedisACLClient connects to Redisgraph over TLS as the default user to run the ACL commands.
+type TLSRedisACLClient struct {
+	Timeout time.Duration
+}
+
+func (c *TLSRedisACLClient) SetUsers(ctx context.Context, target RedisTarget, users []RedisACLUser) error {
+	conn, err := dialRedis(ctx, target, c.Timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	wanted := map[string]bool{defaultRedisUser: true}
+	for _, user := range users {
+		wanted[user.Name] = true
+		if _, err = conn.do(append([]string{"ACL", "SETUSER", user.Name}, user.Rules...)...); err != nil {
+			return fmt.Errorf("ACL SETUSER %s failed: %w", user.Name, err)
+		}
+	}
+	reply, err := conn.do("ACL", "USERS")
+	if err != nil {
+		return fmt.Errorf("ACL USERS failed: %w", err)
+	}
+	names, _ := reply.([]interface{})
+	for _, item := range names {
+		name, _ := item.(string)
+		if name == "" || wanted[name] {
+			continue
+		}
+		if _, err = conn.do("ACL", "DELUSER", name); err != nil {
+			return fmt.Errorf("ACL DELUSER %s failed: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// aclUsers returns the ACL user of the password policy and the component users of the instance, from their
+// secrets.
+func (r *reconcileRequest) aclUsers(ctx context.Context,
+	cr *searchv1alpha1.SearchOperator) ([]RedisACLUser, error) {
+	users := []RedisACLUser{}
+	if spec := aclUserSpec(cr); spec != nil {
+		secret := &corev1.Secret{}
+		if err := r.Client.Get(ctx, types.NamespacedName{Name: r.names.RedisSecret, Namespace: r.namespace},
+			secret); err != nil {
+			return nil, err
+		}
+		name, password := string(secret.Data[secretKeyACLUser]), secret.Data[secretKeyACLPassword]
+		if name != aclUserName(cr) || len(password) == 0 {
+			return nil, fmt.Errorf("the credentials of the ACL user %s aren't in secret %s", aclUserName(cr),
+				r.names.RedisSecret)
+		}
+		users = append(users, RedisACLUser{Name: name, Rules: aclUserRules(spec, password)})
+	}
+	if render.ComponentUsersEnabled(cr) {
+		components, err := r.componentACLUsers(ctx)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, components...)
+	}
+	return users, nil
+}
+
+// configureACL sets the ACL users of the running redisgraph. ACL SETUSER doesn't survive a restart of redis, so
+// it runs every time redisgraph is found ready. Once the ACL user is removed from the spec, the users are deleted
+// a last time and the condition is removed.
+func (r *reconcileRequest) configureACL(ctx context.Context, cr *searchv1alpha1.SearchOperator) {
+	if r.ACL == nil || (aclUserSpec(cr) == nil && !render.ComponentUsersEnabled(cr) &&
+		meta.FindStatusCondition(cr.Status.Conditions, conditionACLConfigured) == nil) {
+		return
+	}
+	r.aclCondition, r.aclChecked = nil, true
+	condition := metav1.Condition{
+		Type:               conditionACLConfigured,
+		Status:             metav1.ConditionTrue,
+		Reason:             "UsersConfigured",
+		ObservedGeneration: cr.Generation,
+	}
+	users, err := r.aclUsers(ctx, cr)
+	var target RedisTarget
+	if err == nil {
+		target, err = r.redisTarget(ctx, cr)
+	}
+	if err == nil {
+		err = r.ACL.SetUsers(ctx, target, users)
+	}
+	if err != nil {
+		logf.FromContext(ctx).Info("Unable to configure the Redis ACL users", "error", err.Error())
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "ConfigureFailed"
+		condition.Message = err.Error()
+		r.aclCondition = &condition
+		return
+	}
+	if len(users) == 0 {
+		return
+	}
+	names := make([]string, 0, len(users))
+	for _, user := range users {
+		names = append(names, user.Name)
+	}
+	condition.Message = "Configured ACL users " + strings.Join(names, ", ")
+	r.aclCondition = &condition
+}
+
+// setACLStatus reports the ACLConfigured condition, removed once there are no ACL users.
+func (r *reconcileRequest) setACLStatus(cr *searchv1alpha1.SearchOperator) {
+	if !r.aclChecked {
+		return
+	}
+	if r.aclCondition == nil {
+		meta.RemoveStatusCondition(&cr.Status.Conditions, conditionACLConfigured)
+		return
+	}
+	meta.SetStatusCondition(&cr.Status.Conditions, *r.aclCondition)
+}
+
+// aclConditionCurrent is true when the ACLConfigured condition of the status is the result of configuring the users.
+func (r *reconcileRequest) aclConditionCurrent(cr *searchv1alpha1.SearchOperator) bool {
+	if !r.aclChecked {
+		return true
+	}
+	if r.aclCondition == nil {
+		return meta.FindStatusCondition(cr.Status.Conditions, conditionACLConfigured) == nil
+	}
+	found := meta.FindStatusCondition(cr.Status.Conditions, conditionACLConfigured)
+	return found != nil && found.Status == r.aclCondition.Status && found.Message == r.aclCondition.Message
+}
+
+// redisgraphBecameReady is true if the object is a StatefulSet with more ready replicas than before, for example
+// when redisgraph restarted and lost the ACL users.
+func redisgraphBecameReady(old, new client.Object) bool {
+	oldSts, ok := old.(*appv1.StatefulSet)
+	if !ok {
+		return false
+	}
+	newSts, ok := new.(*appv1.StatefulSet)
+	return ok && newSts.Status.ReadyReplicas > oldSts.Status.ReadyReplicas
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type fakeACL struct {
+	users [][]RedisACLUser
+	err   error
+}
+
+func (a *fakeACL) SetUsers(ctx context.Context, target RedisTarget, users []RedisACLUser) error {
+	a.users = append(a.users, users)
+	return a.err
+}
+
+func TestTLSRedisACLClient(t *testing.T) {
+	server := newFakeRedis(t, "secret")
+	var mu sync.Mutex
+	commands := []string{}
+	server.handlers["ACL"] = func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		commands = append(commands, strings.Join(args[1:], " "))
+		if strings.ToUpper(args[1]) == "USERS" {
+			return "*3\r\n" + bulkString("default") + bulkString("search") + bulkString("stale")
+		}
+		return "+OK\r\n"
+	}
+	client := &TLSRedisACLClient{Timeout: 2 * time.Second}
+
+	err := client.SetUsers(context.TODO(), server.target("secret"),
+		[]RedisACLUser{{Name: "search", Rules: []string{"reset", "on"}}})
+	assert.Nil(t, err, "Expected the users to be set. Got error: %v", err)
+	mu.Lock()
+	assert.Equal(t, []string{"SETUSER search reset on", "USERS", "DELUSER stale"}, commands,
+		"Expected the user to be set and the other users but default to be deleted.")
+	mu.Unlock()
+
+	server.handlers["ACL"] = func(args []string) string { return "-ERR Error in ACL SETUSER modifier\r\n" }
+	err = client.SetUsers(context.TODO(), server.target("secret"), []RedisACLUser{{Name: "search"}})
+	assert.NotNil(t, err, "Expected the failure of ACL SETUSER to be returned.")
+}
+
+func TestConfigureACL(t *testing.T) {
+	testSetup := commonSetup()
+	ctx := context.TODO()
+	instance := testSetup.srchOperator.DeepCopy()
+	instance.Spec.PasswordPolicy = &searchv1alpha1.PasswordPolicy{ACLUser: &searchv1alpha1.ACLUserSpec{Name: "api"}}
+	kclient := fake.NewFakeClientWithScheme(testSetup.scheme, instance, serviceCA())
+	acl := &fakeACL{}
+	reconciler := SearchOperatorReconciler{Client: kclient, Log: log, Scheme: testSetup.scheme, ACL: acl}
+	request := reconciler.newRequest(testSetup.request)
+	assert.Nil(t, request.setupSecret(ctx, kclient, instance))
+
+	request.aclCondition, request.aclChecked = nil, false
+	request.configureACL(ctx, instance)
+	assert.Len(t, acl.users, 1, "Expected the ACL users to be set.")
+	assert.Equal(t, "api", acl.users[0][0].Name)
+	assert.NotNil(t, request.aclCondition)
+	assert.Equal(t, metav1.ConditionTrue, request.aclCondition.Status)
+	assert.False(t, request.aclConditionCurrent(instance), "Expected the condition to be written to the status.")
+	request.setACLStatus(instance)
+	assert.True(t, request.aclConditionCurrent(instance))
+
+	// The connection is published as the ACL user
+	info, err := request.readConnection(ctx, instance)
+	assert.Nil(t, err)
+	secret := info.connectionSecret(instance, testNames.ConnectionSecret)
+	assert.Equal(t, "api", string(secret.Data["username"]))
+	assert.NotEqual(t, info.password, string(secret.Data["password"]),
+		"Expected the search components to use the password of the ACL user.")
+
+	acl.err = errors.New("NOPERM this user has no permissions to run the 'acl' command")
+	request.configureACL(ctx, instance)
+	request.setACLStatus(instance)
+	condition := meta.FindStatusCondition(instance.Status.Conditions, conditionACLConfigured)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, "ConfigureFailed", condition.Reason)
+
+	// Once the ACL user is removed, the users are deleted and the condition is removed
+	acl.err = nil
+	instance.Spec.PasswordPolicy = nil
+	request.configureACL(ctx, instance)
+	assert.Empty(t, acl.users[len(acl.users)-1], "Expected the ACL users to be deleted.")
+	request.setACLStatus(instance)
+	assert.Nil(t, meta.FindStatusCondition(instance.Status.Conditions, conditionACLConfigured))
+
+	calls := len(acl.users)
+	request.configureACL(ctx, instance)
+	assert.Len(t, acl.users, calls, "Expected no ACL commands without ACL users.")
+}
+
+func TestRedisgraphBecameReady(t *testing.T) {
+	old := &appv1.StatefulSet{}
+	ready := &appv1.StatefulSet{Status: appv1.StatefulSetStatus{ReadyReplicas: 1}}
+	assert.True(t, redisgraphBecameReady(old, ready))
+	assert.False(t, redisgraphBecameReady(ready, old))
+	assert.False(t, redisgraphBecameReady(&corev1.Pod{}, &corev1.Pod{}))
+}
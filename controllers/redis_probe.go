@@ -0,0 +1,307 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stolostron/search-operator/render"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	redisPort           = render.RedisPort
+	redisGraphName      = "search-db"
+	redisCertSecret     = render.RedisCertSecret
+	conditionAvailable  = "Available"
+	defaultProbeTimeout = 5 * time.Second
+	// serviceCAConfigMap is injected in every namespace with the bundle of the service CA
+	serviceCAConfigMap = "openshift-service-ca.crt"
+	serviceCAKey       = "service-ca.crt"
+)
+
+// healthCheckInterval is how often a healthy Redisgraph is probed again
+var healthCheckInterval = 5 * time.Minute
+
+// RedisTarget is the address and credentials used to connect to Redisgraph.
+type RedisTarget struct {
+	Address string
+	// Username is the ACL user to authenticate as, the default user if empty
+	Username  string
+	Password  string
+	TLSConfig *tls.Config
+}
+
+// RedisProbeResult is the outcome of a successful health probe.
+type RedisProbeResult struct {
+	Latency            time.Duration
+	GraphModuleVersion string
+}
+
+// RedisProber actively checks that Redisgraph answers queries.
+type RedisProber interface {
+	Probe(ctx context.Context, target RedisTarget) (RedisProbeResult, error)
+}
+
+// TLSRedisProber connects to Redisgraph over TLS and runs PING and a trivial read-only graph query.
+type TLSRedisProber struct {
+	Timeout time.Duration
+}
+
+func (p *TLSRedisProber) Probe(ctx context.Context, target RedisTarget) (RedisProbeResult, error) {
+	result := RedisProbeResult{}
+	conn, err := dialRedis(ctx, target, p.Timeout)
+	if err != nil {
+		return result, err
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	reply, err := conn.do("PING")
+	if err != nil {
+		return result, fmt.Errorf("PING failed: %w", err)
+	}
+	if reply != "PONG" {
+		return result, fmt.Errorf("unexpected reply to PING: %v", reply)
+	}
+	// The graph doesn't exist until search-aggregator writes it, the module still answered the query
+	if _, err = conn.do("GRAPH.RO_QUERY", redisGraphName, "RETURN 1"); err != nil && !isEmptyGraph(err) {
+		return result, fmt.Errorf("GRAPH.RO_QUERY failed: %w", err)
+	}
+	result.Latency = time.Since(start)
+
+	info, err := conn.do("INFO", "modules")
+	if err != nil {
+		return result, fmt.Errorf("INFO modules failed: %w", err)
+	}
+	infoStr, _ := info.(string)
+	result.GraphModuleVersion = graphModuleVersion(infoStr)
+	return result, nil
+}
+
+// graphModuleVersion finds the version of the graph module in the output of INFO modules.
+// e.g. module:name=graph,ver=20811,api=1,filters=0,usedby=[],using=[],options=[]
+func graphModuleVersion(info string) string {
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "module:") {
+			continue
+		}
+		fields := map[string]string{}
+		for _, field := range strings.Split(strings.TrimPrefix(line, "module:"), ",") {
+			if kv := strings.SplitN(field, "=", 2); len(kv) == 2 {
+				fields[kv[0]] = kv[1]
+			}
+		}
+		if fields["name"] == "graph" {
+			return fields["ver"]
+		}
+	}
+	return ""
+}
+
+// redisTarget builds the connection details for Redisgraph from the password and certificate secrets.
+func (r *reconcileRequest) redisTarget(ctx context.Context, cr *searchv1alpha1.SearchOperator) (
+	RedisTarget, error) {
+	info, err := r.readConnection(ctx, cr)
+	if err != nil {
+		return RedisTarget{}, err
+	}
+	return info.target()
+}
+
+// checkRedisHealth probes Redisgraph and keeps the result to be written to the SearchOperator status.
+func (r *reconcileRequest) checkRedisHealth(ctx context.Context, cr *searchv1alpha1.SearchOperator) {
+	if r.Prober == nil {
+		return
+	}
+	health := &searchv1alpha1.RedisHealthStatus{LastProbeTime: metav1.Now()}
+	r.redisHealth = health
+	target, err := r.probeTarget(ctx, cr)
+	if err != nil {
+		health.Error = err.Error()
+		return
+	}
+	result, err := r.Prober.Probe(ctx, target)
+	if err != nil {
+		health.Error = err.Error()
+		logf.FromContext(ctx).Info("Redisgraph health check failed", "address", target.Address, "error", err.Error())
+		return
+	}
+	health.LatencyMilliseconds = result.Latency.Milliseconds()
+	health.GraphModuleVersion = result.GraphModuleVersion
+}
+
+// healthCheckResult requeues healthy instances so their health keeps being checked.
+// Instances with dependents rolling out or an upgrade in progress are checked again sooner.
+func (r *reconcileRequest) healthCheckResult() ctrl.Result {
+	result := ctrl.Result{}
+	switch {
+	case r.rolloutInProgress():
+		result.RequeueAfter = rolloutCheckInterval
+	case r.Prober != nil:
+		result.RequeueAfter = healthCheckInterval
+	}
+	// A rejected image is checked again sooner
+	if r.imageRetryAfter > 0 && (result.RequeueAfter == 0 || r.imageRetryAfter < result.RequeueAfter) {
+		result.RequeueAfter = r.imageRetryAfter
+	}
+	// An upgrade saving the data is advanced sooner
+	if r.upgradeRequeueAfter > 0 && (result.RequeueAfter == 0 || r.upgradeRequeueAfter < result.RequeueAfter) {
+		result.RequeueAfter = r.upgradeRequeueAfter
+	}
+	return result
+}
+
+// setAvailableCondition sets the Available condition from the persistence status and the last health check.
+func setAvailableCondition(cr *searchv1alpha1.SearchOperator, persistenceStatus string) {
+	condition := metav1.Condition{
+		Type:               conditionAvailable,
+		Status:             metav1.ConditionTrue,
+		Reason:             "RedisgraphRunning",
+		Message:            persistenceStatus,
+		ObservedGeneration: cr.Generation,
+	}
+	switch {
+	case persistenceStatus != statusUsingPVC && persistenceStatus != statusDegradedEmptyDir &&
+		persistenceStatus != statusNoPersistence && persistenceStatus != statusExternal:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "RedisgraphNotRunning"
+	case cr.Status.RedisHealth != nil && cr.Status.RedisHealth.Error != "":
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "HealthCheckFailed"
+		condition.Message = cr.Status.RedisHealth.Error
+	case cr.Status.RedisHealth != nil:
+		condition.Reason = "HealthCheckPassed"
+	}
+	meta.SetStatusCondition(&cr.Status.Conditions, condition)
+}
+
+// redisConn is a minimal client for the Redis serialization protocol, enough for health checks.
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+
+// isEmptyGraph returns true if the error is the reply of RedisGraph to a read-only query on a graph that doesn't
+// exist, which GRAPH.QUERY would have created.
+func isEmptyGraph(err error) bool {
+	return strings.Contains(err.Error(), "empty key")
+}
+
+func dialRedis(ctx context.Context, target RedisTarget, timeout time.Duration) (*redisConn, error) {
+	if timeout == 0 {
+		timeout = defaultProbeTimeout
+	}
+	var conn net.Conn
+	var err error
+	if target.TLSConfig != nil {
+		dialer := &tls.Dialer{NetDialer: &net.Dialer{Timeout: timeout}, Config: target.TLSConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", target.Address)
+	} else {
+		dialer := &net.Dialer{Timeout: timeout}
+		conn, err = dialer.DialContext(ctx, "tcp", target.Address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	rc := &redisConn{conn: conn, reader: bufio.NewReader(conn)}
+	if target.Password != "" {
+		auth := []string{"AUTH", target.Password}
+		if target.Username != "" {
+			auth = []string{"AUTH", target.Username, target.Password}
+		}
+		if _, err = rc.do(auth...); err != nil {
+			rc.Close()
+			return nil, fmt.Errorf("AUTH failed: %w", err)
+		}
+	}
+	return rc, nil
+}
+
+func (c *redisConn) Close() error {
+	return c.conn.Close()
+}
+
+// do sends a command and returns its reply as a string, int64, []interface{} or nil.
+func (c *redisConn) do(args ...string) (interface{}, error) {
+	var cmd strings.Builder
+	fmt.Fprintf(&cmd, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&cmd, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, cmd.String()); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+func (c *redisConn) readReply() (interface{}, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty reply from redis")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(c.reader, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:size]), nil
+	case '*':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, 0, size)
+		for i := 0; i < size; i++ {
+			item, err := c.readReply()
+			if err != nil {
+				// Errors nested in arrays belong to the item, not the command.
+				if _, ok := err.(redisError); !ok {
+					return nil, err
+				}
+				item = err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unexpected reply from redis: %q", line)
+}
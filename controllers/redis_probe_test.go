@@ -0,0 +1,220 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeRedis is a local stand-in for Redisgraph that speaks enough RESP over TLS for the health probe.
+type fakeRedis struct {
+	listener net.Listener
+	password string
+	// caPEM is the self-signed serving certificate, usable as CA to verify the server
+	caPEM []byte
+	// handlers override the reply to a command, keyed by the upper case command name
+	handlers map[string]func(args []string) string
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	assert.Nil(t, err)
+
+	server := &fakeRedis{listener: listener, password: password, handlers: map[string]func([]string) string{},
+		caPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+	go server.serve()
+	t.Cleanup(func() { listener.Close() })
+	return server
+}
+
+func (s *fakeRedis) target(password string) RedisTarget {
+	return RedisTarget{
+		Address:   s.listener.Addr().String(),
+		Password:  password,
+		TLSConfig: &tls.Config{InsecureSkipVerify: true}, // #nosec G402
+	}
+}
+
+func (s *fakeRedis) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	rc := &redisConn{conn: conn, reader: bufio.NewReader(conn)}
+	authenticated := s.password == ""
+	for {
+		reply, err := rc.readReply()
+		if err != nil {
+			return
+		}
+		items, _ := reply.([]interface{})
+		args := make([]string, 0, len(items))
+		for _, item := range items {
+			args = append(args, fmt.Sprint(item))
+		}
+		if len(args) == 0 {
+			return
+		}
+		cmd := strings.ToUpper(args[0])
+		var out string
+		switch {
+		case s.handlers[cmd] != nil:
+			out = s.handlers[cmd](args)
+		case cmd == "AUTH":
+			authenticated = args[len(args)-1] == s.password
+			out = "+OK\r\n"
+			if !authenticated {
+				out = "-WRONGPASS invalid username-password pair\r\n"
+			}
+		case !authenticated:
+			out = "-NOAUTH Authentication required.\r\n"
+		case cmd == "PING":
+			out = "+PONG\r\n"
+		case cmd == "GRAPH.RO_QUERY":
+			out = "*1\r\n*1\r\n" + bulkString("Query internal execution time: 0.1 milliseconds")
+		case cmd == "INFO":
+			out = bulkString("# Modules\r\nmodule:name=graph,ver=20811,api=1,filters=0,usedby=[],using=[],options=[]\r\n")
+		default:
+			out = fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+		}
+		if _, err = io.WriteString(conn, out); err != nil {
+			return
+		}
+	}
+}
+
+func bulkString(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+func TestTLSRedisProber(t *testing.T) {
+	server := newFakeRedis(t, "secret")
+	prober := &TLSRedisProber{Timeout: 2 * time.Second}
+
+	result, err := prober.Probe(context.TODO(), server.target("secret"))
+	assert.Nil(t, err, "Expected probe to pass. Got error: %v", err)
+	assert.Equal(t, "20811", result.GraphModuleVersion, "Expected graph module version from INFO modules.")
+
+	_, err = prober.Probe(context.TODO(), server.target("wrong"))
+	assert.NotNil(t, err, "Expected probe to fail with the wrong password.")
+	assert.Contains(t, err.Error(), "WRONGPASS")
+
+	// The graph isn't created by the probe before search-aggregator writes it
+	server.handlers["GRAPH.QUERY"] = func([]string) string { return "-ERR the probe must not write the graph\r\n" }
+	server.handlers["GRAPH.RO_QUERY"] = func([]string) string {
+		return "-ERR Invalid graph operation on empty key\r\n"
+	}
+	_, err = prober.Probe(context.TODO(), server.target("secret"))
+	assert.Nil(t, err, "Expected probe to pass before the graph exists. Got error: %v", err)
+
+	server.handlers["GRAPH.RO_QUERY"] = func([]string) string { return "-ERR unknown command 'GRAPH.RO_QUERY'\r\n" }
+	_, err = prober.Probe(context.TODO(), server.target("secret"))
+	assert.NotNil(t, err, "Expected probe to fail when the graph module isn't loaded.")
+	assert.Contains(t, err.Error(), "GRAPH.RO_QUERY")
+}
+
+func TestTLSRedisProberUser(t *testing.T) {
+	// The credentials are checked by the AUTH handler
+	server := newFakeRedis(t, "")
+	var auth []string
+	server.handlers["AUTH"] = func(args []string) string {
+		auth = args
+		return "+OK\r\n"
+	}
+	server.handlers["GRAPH.QUERY"] = func([]string) string {
+		return "-NOPERM this user has no permissions to run the 'graph.query' command\r\n"
+	}
+	prober := &TLSRedisProber{Timeout: 2 * time.Second}
+	target := server.target("secret")
+	target.Username = "search-operator"
+
+	result, err := prober.Probe(context.TODO(), target)
+	assert.Nil(t, err, "Expected the probe to only run read-only commands. Got error: %v", err)
+	assert.Equal(t, []string{"AUTH", "search-operator", "secret"}, auth, "Expected to authenticate as the user.")
+	assert.Equal(t, "20811", result.GraphModuleVersion)
+}
+
+type stubProber struct {
+	result RedisProbeResult
+	err    error
+}
+
+func (p *stubProber) Probe(ctx context.Context, target RedisTarget) (RedisProbeResult, error) {
+	return p.result, p.err
+}
+
+func Test_ReconcileRecordsRedisHealth(t *testing.T) {
+	testSetup := commonSetup()
+	req := testSetup.request
+	prober := &stubProber{result: RedisProbeResult{Latency: 3 * time.Millisecond, GraphModuleVersion: "20811"}}
+
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, testSetup.srchOperator, testSetup.secret,
+		testSetup.pvc, testSetup.podWithPVC, serviceCA())
+	reconciler := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme, Prober: prober}
+
+	result, err := reconciler.Reconcile(testSetup.context, req)
+	assert.Nil(t, err, "Expected search Operator reconcile to complete successfully. Got error: %v", err)
+	assert.Equal(t, healthCheckInterval, result.RequeueAfter, "Expected healthy instance to be probed again.")
+
+	instance := &searchv1alpha1.SearchOperator{}
+	err = client.Get(context.TODO(), req.NamespacedName, instance)
+	assert.Nil(t, err, "Expected search Operator to be found. Got error: %v", err)
+	assert.NotNil(t, instance.Status.RedisHealth, "Expected health check result in status.")
+	assert.Equal(t, int64(3), instance.Status.RedisHealth.LatencyMilliseconds)
+	assert.Equal(t, "20811", instance.Status.RedisHealth.GraphModuleVersion)
+	assert.True(t, meta.IsStatusConditionTrue(instance.Status.Conditions, conditionAvailable),
+		"Expected Available condition to be true.")
+
+	// Pod is still running, but Redisgraph doesn't answer anymore.
+	prober.err = errors.New("GRAPH.RO_QUERY failed: ERR unknown command")
+	_, err = reconciler.Reconcile(testSetup.context, req)
+	assert.Nil(t, err, "Expected search Operator reconcile to complete successfully. Got error: %v", err)
+
+	err = client.Get(context.TODO(), req.NamespacedName, instance)
+	assert.Nil(t, err, "Expected search Operator to be found. Got error: %v", err)
+	available := meta.FindStatusCondition(instance.Status.Conditions, conditionAvailable)
+	assert.NotNil(t, available, "Expected Available condition.")
+	assert.Equal(t, metav1.ConditionFalse, available.Status, "Expected Available condition to be false.")
+	assert.Equal(t, "HealthCheckFailed", available.Reason)
+	assert.Equal(t, prober.err.Error(), instance.Status.RedisHealth.Error)
+}
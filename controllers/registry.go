@@ -0,0 +1,382 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	dockerHub           = "docker.io"
+	dockerHubAPI        = "registry-1.docker.io"
+	dockerHubAuth       = "auth.docker.io"
+	defaultRegistryWait = 10 * time.Second
+	defaultVerifyWait   = time.Minute
+)
+
+// manifestMediaTypes are accepted when resolving a digest, the digest of a manifest list or an image index is
+// kept so the node pulls the image of its platform.
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+var digestPattern = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// ImageReference is an image reference split into the parts used by the registry API.
+type ImageReference struct {
+	// Name of the image as written, without the tag or digest
+	Name string
+	// Registry host, docker.io when the name doesn't have one
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseImageReference parses an image reference like quay.io/stolostron/redisgraph-tls:2.5.0. The tag defaults to
+// latest if the reference has neither a tag nor a digest.
+func ParseImageReference(image string) (ImageReference, error) {
+	ref := ImageReference{}
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, ref.Digest = name[:i], name[i+1:]
+		if !digestPattern.MatchString(ref.Digest) {
+			return ref, fmt.Errorf("invalid digest in image %q", image)
+		}
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, ref.Tag = name[:i], name[i+1:]
+	}
+	if name == "" || strings.ToLower(name) != name || strings.Contains(name, "//") {
+		return ref, fmt.Errorf("invalid image %q", image)
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	ref.Name = name
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		ref.Registry, ref.Repository = parts[0], parts[1]
+	} else {
+		ref.Registry, ref.Repository = dockerHub, name
+		if len(parts) == 1 {
+			ref.Repository = "library/" + name
+		}
+	}
+	return ref, nil
+}
+
+// Pinned returns the reference to the image with the digest.
+func (ref ImageReference) Pinned(digest string) string {
+	return ref.Name + "@" + digest
+}
+
+// apiHost is the host serving the registry API.
+func (ref ImageReference) apiHost() string {
+	if ref.Registry == dockerHub {
+		return dockerHubAPI
+	}
+	return ref.Registry
+}
+
+// RegistryCredentials authenticate to a registry, they are read from the image pull secret.
+type RegistryCredentials struct {
+	Username string
+	Password string
+}
+
+// RegistryClient resolves image references to the digest of their manifest.
+type RegistryClient interface {
+	Digest(ctx context.Context, ref ImageReference, creds *RegistryCredentials) (string, error)
+}
+
+// HTTPRegistryClient resolves digests with the registry HTTP API V2, anonymously or with basic or bearer token
+// authentication. Tokens are only requested from https realms on the host of the registry, auth.docker.io for
+// docker.io, or one of TokenHosts, since the credentials of the pull secret are sent with the request.
+type HTTPRegistryClient struct {
+	// Client sends the requests, a client with the timeout is used if nil
+	Client  *http.Client
+	Timeout time.Duration
+	// TokenHosts are the hosts of the token services trusted with the credentials of every registry
+	TokenHosts []string
+}
+
+func (c *HTTPRegistryClient) Digest(ctx context.Context, ref ImageReference, creds *RegistryCredentials) (
+	string, error) {
+	if ref.Digest != "" {
+		return ref.Digest, nil
+	}
+	manifestURL := fmt.Sprintf("https://%s/v2/%s/manifests/%s", ref.apiHost(), ref.Repository, ref.Tag)
+	resp, err := c.requestManifest(ctx, http.MethodHead, manifestURL, "")
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	authorization := ""
+	if resp.StatusCode == http.StatusUnauthorized {
+		authorization, err = c.authorize(ctx, resp.Header.Get("WWW-Authenticate"), ref, creds)
+		if err != nil {
+			return "", err
+		}
+		if resp, err = c.requestManifest(ctx, http.MethodHead, manifestURL, authorization); err != nil {
+			return "", err
+		}
+		resp.Body.Close()
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry %s returned %s for %s:%s", ref.Registry, resp.Status, ref.Name, ref.Tag)
+	}
+	if digest := resp.Header.Get("Docker-Content-Digest"); digestPattern.MatchString(digest) {
+		return digest, nil
+	}
+	// Not every registry returns the digest to HEAD requests, it's the hash of the manifest
+	if resp, err = c.requestManifest(ctx, http.MethodGet, manifestURL, authorization); err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry %s returned %s for %s:%s", ref.Registry, resp.Status, ref.Name, ref.Tag)
+	}
+	hash := sha256.New()
+	if _, err = io.Copy(hash, resp.Body); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("sha256:%x", hash.Sum(nil)), nil
+}
+
+func (c *HTTPRegistryClient) client() *http.Client {
+	if c.Client != nil {
+		return c.Client
+	}
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = defaultRegistryWait
+	}
+	return &http.Client{Timeout: timeout}
+}
+
+func (c *HTTPRegistryClient) requestManifest(ctx context.Context, method, manifestURL, authorization string) (
+	*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, manifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	return c.client().Do(req)
+}
+
+// authorize returns the Authorization header answering the challenge of the registry.
+func (c *HTTPRegistryClient) authorize(ctx context.Context, challenge string, ref ImageReference,
+	creds *RegistryCredentials) (string, error) {
+	scheme, params := parseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if creds == nil {
+			return "", fmt.Errorf("registry %s requires credentials, none found in the image pull secret",
+				ref.Registry)
+		}
+		return "Basic " + basicAuth(creds), nil
+	case "bearer":
+	default:
+		return "", fmt.Errorf("registry %s uses an unsupported authentication challenge %q", ref.Registry, challenge)
+	}
+	tokenURL, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("registry %s returned an invalid token realm %q", ref.Registry, params["realm"])
+	}
+	if tokenURL.Scheme != "https" {
+		return "", fmt.Errorf("registry %s returned a token realm %q that isn't https", ref.Registry, params["realm"])
+	}
+	if !c.trustedTokenHost(ref, tokenURL.Host) {
+		return "", fmt.Errorf("registry %s returned a token realm %q on an untrusted host", ref.Registry,
+			params["realm"])
+	}
+	query := tokenURL.Query()
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + ref.Repository + ":pull"
+	}
+	query.Set("scope", scope)
+	tokenURL.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if creds != nil {
+		req.Header.Set("Authorization", "Basic "+basicAuth(creds))
+	}
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token service of registry %s returned %s", ref.Registry, resp.Status)
+	}
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err = json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("invalid token from registry %s: %w", ref.Registry, err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	return "Bearer " + token.Token, nil
+}
+
+// trustedTokenHost returns true if the credentials of the registry can be sent to the token service on host.
+func (c *HTTPRegistryClient) trustedTokenHost(ref ImageReference, host string) bool {
+	host = strings.ToLower(host)
+	if host == ref.apiHost() || (ref.Registry == dockerHub && host == dockerHubAuth) {
+		return true
+	}
+	for _, trusted := range c.TokenHosts {
+		if host == strings.ToLower(trusted) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseChallenge parses a WWW-Authenticate header like Bearer realm="https://auth",service="registry".
+func parseChallenge(challenge string) (string, map[string]string) {
+	params := map[string]string{}
+	scheme, rest, _ := cut(strings.TrimSpace(challenge), " ")
+	for rest = strings.TrimSpace(rest); rest != ""; {
+		key, value, found := cut(rest, "=")
+		if !found {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		if strings.HasPrefix(value, `"`) {
+			end := strings.Index(value[1:], `"`)
+			if end < 0 {
+				params[key] = value[1:]
+				break
+			}
+			params[key], rest = value[1:end+1], value[end+2:]
+		} else {
+			params[key], rest, _ = cut(value, ",")
+		}
+		rest = strings.TrimLeft(rest, ", ")
+	}
+	return scheme, params
+}
+
+// cut slices s around the first separator, like strings.Cut of newer Go versions.
+func cut(s, sep string) (string, string, bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+func basicAuth(creds *RegistryCredentials) string {
+	return base64.StdEncoding.EncodeToString([]byte(creds.Username + ":" + creds.Password))
+}
+
+// pullSecretCredentials returns the credentials of the registry from a dockerconfigjson or dockercfg secret,
+// nil if the secret has none.
+func pullSecretCredentials(secret *corev1.Secret, registry string) (*RegistryCredentials, error) {
+	type auth struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Auth     string `json:"auth"`
+	}
+	auths := map[string]auth{}
+	if data, ok := secret.Data[corev1.DockerConfigJsonKey]; ok {
+		config := struct {
+			Auths map[string]auth `json:"auths"`
+		}{}
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("invalid %s in secret %s: %w", corev1.DockerConfigJsonKey, secret.Name, err)
+		}
+		auths = config.Auths
+	} else if data, ok := secret.Data[corev1.DockerConfigKey]; ok {
+		if err := json.Unmarshal(data, &auths); err != nil {
+			return nil, fmt.Errorf("invalid %s in secret %s: %w", corev1.DockerConfigKey, secret.Name, err)
+		}
+	}
+	for server, entry := range auths {
+		if registryHost(server) != registry {
+			continue
+		}
+		creds := &RegistryCredentials{Username: entry.Username, Password: entry.Password}
+		if entry.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("invalid auth for %s in secret %s: %w", server, secret.Name, err)
+			}
+			creds.Username, creds.Password, _ = cut(string(decoded), ":")
+		}
+		return creds, nil
+	}
+	return nil, nil
+}
+
+// registryHost returns the registry host of a server in a docker config, which can be a URL.
+func registryHost(server string) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+	host, _, _ = cut(host, "/")
+	switch host {
+	case "index.docker.io", dockerHubAPI:
+		return dockerHub
+	}
+	return host
+}
+
+// ImageVerifier checks the signature of an image before it's rolled out.
+type ImageVerifier interface {
+	Verify(ctx context.Context, image string) error
+}
+
+// CommandImageVerifier runs a command with the image reference as its last argument, for example
+// cosign verify --key /etc/keys/cosign.pub. The image is rejected if the command fails.
+type CommandImageVerifier struct {
+	Command []string
+	Timeout time.Duration
+}
+
+func (v *CommandImageVerifier) Verify(ctx context.Context, image string) error {
+	if len(v.Command) == 0 {
+		return fmt.Errorf("no image verification command configured")
+	}
+	timeout := v.Timeout
+	if timeout == 0 {
+		timeout = defaultVerifyWait
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	args := append(append([]string{}, v.Command[1:]...), image)
+	// #nosec G204 -- the command is configured by the cluster admin with IMAGE_VERIFY_COMMAND
+	output, err := exec.CommandContext(ctx, v.Command[0], args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("verification of %s failed: %w: %s", image, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
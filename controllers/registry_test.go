@@ -0,0 +1,183 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testDigest = "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+
+func TestParseImageReference(t *testing.T) {
+	tests := []struct {
+		image string
+		want  ImageReference
+	}{
+		{"quay.io/stolostron/redisgraph-tls:2.5.0", ImageReference{Name: "quay.io/stolostron/redisgraph-tls",
+			Registry: "quay.io", Repository: "stolostron/redisgraph-tls", Tag: "2.5.0"}},
+		{"localhost:5000/redisgraph", ImageReference{Name: "localhost:5000/redisgraph",
+			Registry: "localhost:5000", Repository: "redisgraph", Tag: "latest"}},
+		{"redis", ImageReference{Name: "redis", Registry: "docker.io", Repository: "library/redis", Tag: "latest"}},
+		{"redislabs/redisgraph:2.8.9", ImageReference{Name: "redislabs/redisgraph", Registry: "docker.io",
+			Repository: "redislabs/redisgraph", Tag: "2.8.9"}},
+		{"quay.io/stolostron/redisgraph-tls:2.5.0@" + testDigest, ImageReference{
+			Name: "quay.io/stolostron/redisgraph-tls", Registry: "quay.io", Repository: "stolostron/redisgraph-tls",
+			Tag: "2.5.0", Digest: testDigest}},
+	}
+	for _, test := range tests {
+		ref, err := ParseImageReference(test.image)
+		assert.Nil(t, err, "Expected %s to be parsed. Got error: %v", test.image, err)
+		assert.Equal(t, test.want, ref)
+	}
+	for _, image := range []string{"", "quay.io/Stolostron/redisgraph", "redis@sha256:abc"} {
+		_, err := ParseImageReference(image)
+		assert.NotNil(t, err, "Expected %q to be invalid.", image)
+	}
+	ref, _ := ParseImageReference("quay.io/stolostron/redisgraph-tls:2.5.0")
+	assert.Equal(t, "quay.io/stolostron/redisgraph-tls@"+testDigest, ref.Pinned(testDigest))
+}
+
+// testRegistry is a registry serving one manifest behind a bearer token service, like quay.io.
+func testRegistry(t *testing.T, headDigest bool) (*httptest.Server, string) {
+	manifest := `{"schemaVersion":2}`
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(manifest)))
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.URL.Path == "/token":
+			user, password, ok := req.BasicAuth()
+			if !ok || user != "robot" || password != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			assert.Equal(t, "repository:stolostron/redisgraph-tls:pull", req.URL.Query().Get("scope"))
+			fmt.Fprint(w, `{"token":"abc"}`)
+		case req.Header.Get("Authorization") != "Bearer abc":
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+		case req.URL.Path == "/v2/stolostron/redisgraph-tls/manifests/2.5.0":
+			assert.Contains(t, req.Header.Get("Accept"), "application/vnd.oci.image.index.v1+json")
+			if headDigest {
+				w.Header().Set("Docker-Content-Digest", digest)
+			}
+			if req.Method == http.MethodGet {
+				fmt.Fprint(w, manifest)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server, digest
+}
+
+func TestHTTPRegistryClient(t *testing.T) {
+	for _, headDigest := range []bool{true, false} {
+		server, digest := testRegistry(t, headDigest)
+		host := strings.TrimPrefix(server.URL, "https://")
+		registry := &HTTPRegistryClient{Client: server.Client()}
+		creds := &RegistryCredentials{Username: "robot", Password: "secret"}
+
+		ref, _ := ParseImageReference(host + "/stolostron/redisgraph-tls:2.5.0")
+		resolved, err := registry.Digest(context.TODO(), ref, creds)
+		assert.Nil(t, err, "Expected the digest to be resolved. Got error: %v", err)
+		assert.Equal(t, digest, resolved, "Expected the digest of the manifest.")
+
+		_, err = registry.Digest(context.TODO(), ref, nil)
+		assert.NotNil(t, err, "Expected the token service to require the credentials.")
+		ref, _ = ParseImageReference(host + "/stolostron/redisgraph-tls:missing")
+		_, err = registry.Digest(context.TODO(), ref, creds)
+		assert.NotNil(t, err, "Expected an error for an unknown tag.")
+		server.Close()
+	}
+}
+
+func TestTokenRealm(t *testing.T) {
+	// The token service runs on another host than the registry and records the credentials it receives
+	credentialsSent := false
+	tokenService := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _, credentialsSent = req.BasicAuth()
+		fmt.Fprint(w, `{"token":"abc"}`)
+	}))
+	defer tokenService.Close()
+	realm := tokenService.URL + "/token"
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer abc" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s",service="registry"`, realm))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", testDigest)
+	}))
+	defer server.Close()
+	registry := &HTTPRegistryClient{Client: server.Client()}
+	creds := &RegistryCredentials{Username: "robot", Password: "secret"}
+	ref, _ := ParseImageReference(strings.TrimPrefix(server.URL, "https://") + "/stolostron/redisgraph-tls:2.5.0")
+
+	_, err := registry.Digest(context.TODO(), ref, creds)
+	assert.NotNil(t, err, "Expected a realm on another host to be rejected.")
+	assert.False(t, credentialsSent, "Expected the credentials not to be sent to another host.")
+
+	realm = strings.Replace(tokenService.URL, "https://", "http://", 1) + "/token"
+	registry.TokenHosts = []string{strings.TrimPrefix(tokenService.URL, "https://")}
+	_, err = registry.Digest(context.TODO(), ref, creds)
+	assert.NotNil(t, err, "Expected a realm without TLS to be rejected.")
+	assert.False(t, credentialsSent, "Expected the credentials not to be sent without TLS.")
+
+	realm = tokenService.URL + "/token"
+	resolved, err := registry.Digest(context.TODO(), ref, creds)
+	assert.Nil(t, err, "Expected the token service in TokenHosts to be trusted. Got error: %v", err)
+	assert.Equal(t, testDigest, resolved)
+	assert.True(t, credentialsSent)
+}
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(
+		`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:a/b:pull,push"`)
+	assert.Equal(t, "Bearer", scheme)
+	assert.Equal(t, map[string]string{"realm": "https://auth.docker.io/token", "service": "registry.docker.io",
+		"scope": "repository:a/b:pull,push"}, params)
+	scheme, params = parseChallenge(`Basic realm=Registry`)
+	assert.Equal(t, "Basic", scheme)
+	assert.Equal(t, "Registry", params["realm"])
+}
+
+func TestPullSecretCredentials(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "multiclusterhub-operator-pull-secret"},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{
+			"quay.io":{"auth":"cm9ib3Q6c2VjcmV0"},
+			"https://index.docker.io/v1/":{"username":"hub","password":"pass"}}}`)},
+	}
+	creds, err := pullSecretCredentials(secret, "quay.io")
+	assert.Nil(t, err)
+	assert.Equal(t, &RegistryCredentials{Username: "robot", Password: "secret"}, creds)
+	creds, _ = pullSecretCredentials(secret, "docker.io")
+	assert.Equal(t, &RegistryCredentials{Username: "hub", Password: "pass"}, creds)
+	creds, _ = pullSecretCredentials(secret, "registry.redhat.io")
+	assert.Nil(t, creds, "Expected no credentials for another registry.")
+
+	secret.Data[corev1.DockerConfigJsonKey] = []byte("not json")
+	_, err = pullSecretCredentials(secret, "quay.io")
+	assert.NotNil(t, err, "Expected an invalid pull secret to be reported.")
+}
+
+func TestCommandImageVerifier(t *testing.T) {
+	verifier := &CommandImageVerifier{Command: []string{"sh", "-c", `test "$0" = quay.io/stolostron/redisgraph-tls@` +
+		testDigest}}
+	assert.Nil(t, verifier.Verify(context.TODO(), "quay.io/stolostron/redisgraph-tls@"+testDigest),
+		"Expected the image to be passed as the last argument.")
+	assert.NotNil(t, verifier.Verify(context.TODO(), "quay.io/stolostron/redisgraph-tls:2.5.0"),
+		"Expected the image to be rejected when the command fails.")
+}
@@ -0,0 +1,328 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"os"
+	"reflect"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	searchv1beta1 "github.com/stolostron/search-operator/api/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// annotationImport on a SearchOperator set to "true" imports it, and its SearchCustomization, into a Search
+	annotationImport = "search.open-cluster-management.io/import"
+	// annotationImportedFrom on a Search lists the objects it was imported from
+	annotationImportedFrom = "search.open-cluster-management.io/imported-from"
+	// labelProjectedFrom on a SearchOperator or a SearchCustomization is the name of the Search projected onto it
+	labelProjectedFrom = "search.open-cluster-management.io/search"
+	conditionProjected = "Projected"
+)
+
+// SearchReconciler projects a Search onto the SearchOperator and SearchCustomization of the same name, which
+// the SearchOperatorReconciler then reconciles. The Search takes precedence: changes made directly to the
+// projections are overwritten. The projections are labeled with the name of the Search instead of being owned by
+// it, so deleting the Search doesn't delete the SearchOperator and the redisgraph data it owns.
+type SearchReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=search.open-cluster-management.io,resources=searches,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups=search.open-cluster-management.io,resources=searches/status,verbs=update
+// +kubebuilder:rbac:groups=search.open-cluster-management.io,resources=searchoperators,verbs=get;list;watch;create;update
+// +kubebuilder:rbac:groups=search.open-cluster-management.io,resources=searchcustomizations,verbs=get;list;watch;create;update;delete
+
+// Reconcile imports a SearchOperator annotated for import into a new Search, and projects existing Searches.
+func (r *SearchReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx = logf.IntoContext(ctx, logf.FromContext(ctx).WithValues("search", req.Name, "namespace", req.Namespace))
+	search := &searchv1beta1.Search{}
+	err := r.Client.Get(ctx, req.NamespacedName, search)
+	if errors.IsNotFound(err) {
+		return ctrl.Result{}, r.importSearch(ctx, req.NamespacedName)
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !search.DeletionTimestamp.IsZero() {
+		// The projections are released once the Search is gone
+		return ctrl.Result{}, nil
+	}
+	operator, err := r.projectSearchOperator(ctx, search)
+	var custom *searchv1alpha1.SearchCustomization
+	if err == nil {
+		custom, err = r.projectCustomization(ctx, search, operator)
+	}
+	if statusErr := r.updateSearchStatus(ctx, search, operator, custom, err); statusErr != nil && err == nil {
+		err = statusErr
+	}
+	return ctrl.Result{}, err
+}
+
+// importSearch creates a Search from the SearchOperator of the same name, if it's annotated for import,
+// and the SearchCustomization that applies to it. The projections of a deleted Search are released instead of
+// being imported again.
+func (r *SearchReconciler) importSearch(ctx context.Context, key types.NamespacedName) error {
+	operator := &searchv1alpha1.SearchOperator{}
+	err := r.Client.Get(ctx, key, operator)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	custom, err := FindCustomization(ctx, r.Client, operator)
+	if err != nil {
+		return err
+	}
+	if _, projected := operator.Labels[labelProjectedFrom]; projected {
+		return r.releaseProjections(ctx, operator, custom)
+	}
+	if operator.Annotations[annotationImport] != "true" {
+		return nil
+	}
+	search, err := searchFrom(operator, custom)
+	if err != nil {
+		return err
+	}
+	if err = r.Client.Create(ctx, search); err != nil {
+		return err
+	}
+	logf.FromContext(ctx).Info("Imported SearchOperator into a Search", "importedFrom",
+		search.Annotations[annotationImportedFrom])
+	return nil
+}
+
+// releaseProjections removes the label of the deleted Search from its projections, which are left in place, and
+// the import annotation so the SearchOperator isn't imported again.
+func (r *SearchReconciler) releaseProjections(ctx context.Context, operator *searchv1alpha1.SearchOperator,
+	custom *searchv1alpha1.SearchCustomization) error {
+	if custom != nil && custom.Labels[labelProjectedFrom] == operator.Labels[labelProjectedFrom] {
+		delete(custom.Labels, labelProjectedFrom)
+		if err := r.Client.Update(ctx, custom); err != nil {
+			return err
+		}
+	}
+	delete(operator.Labels, labelProjectedFrom)
+	delete(operator.Annotations, annotationImport)
+	if err := r.Client.Update(ctx, operator); err != nil {
+		return err
+	}
+	logf.FromContext(ctx).Info("Released the SearchOperator of the deleted Search")
+	return nil
+}
+
+// markProjection labels the object as a projection of the Search, and removes the owner reference to the Search
+// set by earlier versions, which would have the object garbage collected with the Search.
+func markProjection(search *searchv1beta1.Search, obj metav1.Object) {
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[labelProjectedFrom] = search.Name
+	obj.SetLabels(labels)
+	var refs []metav1.OwnerReference
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID != search.UID {
+			refs = append(refs, ref)
+		}
+	}
+	obj.SetOwnerReferences(refs)
+}
+
+// searchFrom returns the Search with the spec of the SearchOperator and the storage of the SearchCustomization.
+func searchFrom(operator *searchv1alpha1.SearchOperator,
+	custom *searchv1alpha1.SearchCustomization) (*searchv1beta1.Search, error) {
+	spoke := &searchv1beta1.SearchOperator{}
+	if err := spoke.ConvertFrom(operator); err != nil {
+		return nil, err
+	}
+	importedFrom := "SearchOperator/" + operator.Name
+	search := &searchv1beta1.Search{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        operator.Name,
+			Namespace:   operator.Namespace,
+			Labels:      operator.Labels,
+			Annotations: map[string]string{},
+		},
+		Spec: searchv1beta1.SearchSpec{SearchOperatorSpec: spoke.Spec},
+	}
+	if custom != nil {
+		customSpoke := &searchv1beta1.SearchCustomization{}
+		if err := customSpoke.ConvertFrom(custom); err != nil {
+			return nil, err
+		}
+		search.Spec.Storage = customSpoke.Spec.Storage
+		importedFrom += ",SearchCustomization/" + custom.Name
+	}
+	search.Annotations[annotationImportedFrom] = importedFrom
+	return search, nil
+}
+
+// projectSearchOperator creates or updates the SearchOperator of the Search.
+func (r *SearchReconciler) projectSearchOperator(ctx context.Context,
+	search *searchv1beta1.Search) (*searchv1alpha1.SearchOperator, error) {
+	spoke := &searchv1beta1.SearchOperator{Spec: search.Spec.SearchOperatorSpec}
+	hub := &searchv1alpha1.SearchOperator{}
+	if err := spoke.ConvertTo(hub); err != nil {
+		return nil, err
+	}
+	operator := &searchv1alpha1.SearchOperator{
+		ObjectMeta: metav1.ObjectMeta{Name: search.Name, Namespace: search.Namespace},
+	}
+	result, err := controllerutil.CreateOrUpdate(ctx, r.Client, operator, func() error {
+		operator.Spec = hub.Spec
+		markProjection(search, operator)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result != controllerutil.OperationResultNone {
+		logf.FromContext(ctx).Info("Projected Search onto SearchOperator", "operation", result)
+	}
+	return operator, nil
+}
+
+// projectCustomization makes the SearchCustomization that applies to the SearchOperator match the storage of
+// the Search, adopting an existing customization. Without storage the customization is deleted, so the
+// operator defaults apply, including the fallback to an emptyDir.
+func (r *SearchReconciler) projectCustomization(ctx context.Context, search *searchv1beta1.Search,
+	operator *searchv1alpha1.SearchOperator) (*searchv1alpha1.SearchCustomization, error) {
+	custom, err := FindCustomization(ctx, r.Client, operator)
+	if err != nil {
+		return nil, err
+	}
+	if search.Spec.Storage == (searchv1beta1.StorageSpec{}) {
+		if custom == nil {
+			return nil, nil
+		}
+		if custom.Labels[labelProjectedFrom] == search.Name {
+			if err = r.Client.Delete(ctx, custom); err != nil && !errors.IsNotFound(err) {
+				return nil, err
+			}
+			logf.FromContext(ctx).Info("Deleted the SearchCustomization, the Search doesn't set the storage",
+				"searchCustomization", custom.Name)
+			return nil, nil
+		}
+		// Adopted first, it's deleted on the next reconcile
+	}
+	if custom == nil {
+		custom = &searchv1alpha1.SearchCustomization{
+			ObjectMeta: metav1.ObjectMeta{Name: search.Name, Namespace: search.Namespace},
+		}
+	}
+	return r.updateCustomization(ctx, search, custom)
+}
+
+func (r *SearchReconciler) updateCustomization(ctx context.Context, search *searchv1beta1.Search,
+	custom *searchv1alpha1.SearchCustomization) (*searchv1alpha1.SearchCustomization, error) {
+	spoke := &searchv1beta1.SearchCustomization{Spec: searchv1beta1.SearchCustomizationSpec{
+		SearchOperatorRef: search.Name,
+		Storage:           search.Spec.Storage,
+	}}
+	hub := &searchv1alpha1.SearchCustomization{}
+	if err := spoke.ConvertTo(hub); err != nil {
+		return nil, err
+	}
+	result, err := controllerutil.CreateOrUpdate(ctx, r.Client, custom, func() error {
+		custom.Spec = hub.Spec
+		markProjection(search, custom)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result != controllerutil.OperationResultNone {
+		logf.FromContext(ctx).Info("Projected Search onto SearchCustomization", "searchCustomization", custom.Name,
+			"operation", result)
+	}
+	return custom, nil
+}
+
+// updateSearchStatus reports the status of the projections on the Search.
+func (r *SearchReconciler) updateSearchStatus(ctx context.Context, search *searchv1beta1.Search,
+	operator *searchv1alpha1.SearchOperator, custom *searchv1alpha1.SearchCustomization, projectErr error) error {
+	status := searchv1beta1.SearchStatus{ObservedGeneration: search.Status.ObservedGeneration}
+	if operator != nil {
+		spoke := &searchv1beta1.SearchOperator{}
+		if err := spoke.ConvertFrom(operator); err != nil {
+			return err
+		}
+		status.Conditions = spoke.Status.Conditions
+		status.Redisgraph = spoke.Status.Redisgraph
+		status.Binding = spoke.Status.Binding
+		status.Dependents = spoke.Status.Dependents
+	}
+	if custom != nil {
+		status.Storage = searchv1beta1.AppliedStorage{
+			Persistence:  custom.Status.Persistence,
+			StorageClass: custom.Status.StorageClass,
+			Size:         custom.Status.StorageSize,
+			PVCName:      custom.Status.PVCName,
+		}
+	}
+	// The Search is applied once projected and the SearchOperator reports the status of the projected spec
+	if projectErr == nil && operator != nil && operator.Status.ObservedGeneration == operator.Generation {
+		status.ObservedGeneration = search.Generation
+	}
+	condition := metav1.Condition{
+		Type:               conditionProjected,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Projected",
+		Message:            "The SearchOperator and SearchCustomization match the Search",
+		ObservedGeneration: search.Generation,
+	}
+	if projectErr != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "ProjectionFailed"
+		condition.Message = projectErr.Error()
+	}
+	// Keep the transition time of the Projected condition
+	status.Conditions = append([]metav1.Condition{}, status.Conditions...)
+	if existing := meta.FindStatusCondition(search.Status.Conditions, conditionProjected); existing != nil {
+		status.Conditions = append(status.Conditions, *existing)
+	}
+	meta.SetStatusCondition(&status.Conditions, condition)
+	if reflect.DeepEqual(search.Status, status) {
+		return nil
+	}
+	search.Status = status
+	return r.Client.Status().Update(ctx, search)
+}
+
+// searchRequests maps a SearchOperator to the Search of the same name.
+func searchRequests(a client.Object) []reconcile.Request {
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: a.GetName(), Namespace: a.GetNamespace()}},
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *SearchReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	watchNamespaces := WatchNamespaces(os.Getenv("WATCH_NAMESPACE"))
+	pred := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return namespaceWatched(watchNamespaces, obj.GetNamespace())
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&searchv1beta1.Search{}).
+		Watches(&source.Kind{Type: &searchv1alpha1.SearchOperator{}},
+			handler.EnqueueRequestsFromMapFunc(searchRequests)).
+		Watches(&source.Kind{Type: &searchv1alpha1.SearchCustomization{}},
+			handler.EnqueueRequestsFromMapFunc(searchCustomizationRequests)).
+		WithEventFilter(pred).Complete(r)
+}
@@ -0,0 +1,217 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	searchv1beta1 "github.com/stolostron/search-operator/api/v1beta1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func testSearch(storage searchv1beta1.StorageSpec) *searchv1beta1.Search {
+	return &searchv1beta1.Search{
+		ObjectMeta: metav1.ObjectMeta{Name: defaultInstanceName, Namespace: testNamespace, UID: "search-uid"},
+		Spec: searchv1beta1.SearchSpec{
+			SearchOperatorSpec: searchv1beta1.SearchOperatorSpec{
+				Redisgraph: searchv1beta1.RedisgraphSpec{
+					Image: "quay.io/stolostron/redisgraph-tls:2.5.0",
+					Resources: searchv1beta1.RedisgraphResources{
+						Requests: searchv1beta1.ResourceValues{CPU: "25m", Memory: "64Mi"},
+						Limits:   searchv1beta1.ResourceValues{Memory: "1Gi"},
+					},
+				},
+				ImagePullSecret: "multiclusterhub-operator-pull-secret",
+			},
+			Storage: storage,
+		},
+	}
+}
+
+func TestSearchImport(t *testing.T) {
+	testSetup := commonSetup()
+	operator := testSetup.srchOperator.DeepCopy()
+	operator.Annotations = map[string]string{annotationImport: "true"}
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, operator, createFakeSearchCustomizationCR(testNamespace, true))
+	reconciler := SearchReconciler{Client: client, Scheme: testSetup.scheme}
+
+	_, err := reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected import to succeed. Got error: %v", err)
+
+	search := &searchv1beta1.Search{}
+	err = client.Get(context.TODO(), testSetup.request.NamespacedName, search)
+	assert.Nil(t, err, "Expected the Search to be imported. Got error: %v", err)
+	assert.Equal(t, operator.Spec.Redisgraph_Resource.RequestMemory, search.Spec.Redisgraph.Resources.Requests.Memory)
+	assert.Equal(t, "1Gi", search.Spec.Storage.Size, "Expected the storage of the SearchCustomization.")
+	assert.Equal(t, "SearchOperator/searchoperator,SearchCustomization/searchcustomization",
+		search.Annotations[annotationImportedFrom])
+
+	// The imported objects become projections of the Search
+	_, err = reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected projection to succeed. Got error: %v", err)
+	projected := &searchv1alpha1.SearchOperator{}
+	_ = client.Get(context.TODO(), testSetup.request.NamespacedName, projected)
+	assert.Equal(t, search.Name, projected.Labels[labelProjectedFrom], "Expected the SearchOperator to be labeled.")
+	assert.Equal(t, operator.Spec, projected.Spec, "Expected the import not to change the SearchOperator spec.")
+	custom := &searchv1alpha1.SearchCustomization{}
+	_ = client.Get(context.TODO(), types.NamespacedName{Name: "searchcustomization", Namespace: testNamespace}, custom)
+	assert.Equal(t, search.Name, custom.Labels[labelProjectedFrom], "Expected the SearchCustomization to be adopted.")
+	assert.Equal(t, "1Gi", custom.Spec.StorageSize)
+}
+
+func TestSearchImportRequiresAnnotation(t *testing.T) {
+	testSetup := commonSetup()
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, testSetup.srchOperator)
+	reconciler := SearchReconciler{Client: client, Scheme: testSetup.scheme}
+
+	_, err := reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected reconcile to succeed. Got error: %v", err)
+	err = client.Get(context.TODO(), testSetup.request.NamespacedName, &searchv1beta1.Search{})
+	assert.True(t, errors.IsNotFound(err), "Expected no Search without the import annotation.")
+}
+
+func TestSearchProjection(t *testing.T) {
+	testSetup := commonSetup()
+	search := testSearch(searchv1beta1.StorageSpec{StorageClass: "gp2", Size: "20Gi"})
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, search)
+	reconciler := SearchReconciler{Client: client, Scheme: testSetup.scheme}
+
+	_, err := reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected projection to succeed. Got error: %v", err)
+
+	operator := &searchv1alpha1.SearchOperator{}
+	err = client.Get(context.TODO(), testSetup.request.NamespacedName, operator)
+	assert.Nil(t, err, "Expected the SearchOperator to be created. Got error: %v", err)
+	assert.Equal(t, "quay.io/stolostron/redisgraph-tls:2.5.0", operator.Spec.SearchImageOverrides.Redisgraph_TLS)
+	assert.Equal(t, "multiclusterhub-operator-pull-secret", operator.Spec.PullSecret)
+	custom := &searchv1alpha1.SearchCustomization{}
+	err = client.Get(context.TODO(), testSetup.request.NamespacedName, custom)
+	assert.Nil(t, err, "Expected the SearchCustomization to be created. Got error: %v", err)
+	assert.Equal(t, searchv1alpha1.SearchCustomizationSpec{StorageClass: "gp2", StorageSize: "20Gi",
+		SearchOperatorRef: defaultInstanceName}, custom.Spec)
+
+	// Changes to the projections are overwritten
+	operator.Spec.PullSecret = "edited"
+	assert.Nil(t, client.Update(context.TODO(), operator))
+	_, err = reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected projection to succeed. Got error: %v", err)
+	_ = client.Get(context.TODO(), testSetup.request.NamespacedName, operator)
+	assert.Equal(t, "multiclusterhub-operator-pull-secret", operator.Spec.PullSecret,
+		"Expected the Search to take precedence over the SearchOperator.")
+
+	updated := &searchv1beta1.Search{}
+	_ = client.Get(context.TODO(), testSetup.request.NamespacedName, updated)
+	assert.True(t, meta.IsStatusConditionTrue(updated.Status.Conditions, conditionProjected))
+}
+
+func TestSearchDeletionKeepsProjections(t *testing.T) {
+	testSetup := commonSetup()
+	search := testSearch(searchv1beta1.StorageSpec{StorageClass: "gp2", Size: "20Gi"})
+	// The SearchOperator is already controlled by another object, and by the Search in earlier versions
+	operator := testSetup.srchOperator.DeepCopy()
+	operator.Annotations = map[string]string{annotationImport: "true"}
+	operator.OwnerReferences = []metav1.OwnerReference{
+		*metav1.NewControllerRef(search, searchv1beta1.GroupVersion.WithKind("Search")),
+		{APIVersion: "operator.open-cluster-management.io/v1", Kind: "MultiClusterHub", Name: "multiclusterhub",
+			UID: "mch-uid"},
+	}
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, search, operator)
+	reconciler := SearchReconciler{Client: client, Scheme: testSetup.scheme}
+
+	_, err := reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected projection to succeed. Got error: %v", err)
+	projected := &searchv1alpha1.SearchOperator{}
+	_ = client.Get(context.TODO(), testSetup.request.NamespacedName, projected)
+	assert.Equal(t, operator.OwnerReferences[1:], projected.OwnerReferences,
+		"Expected the Search not to own the SearchOperator, so it isn't garbage collected with the Search.")
+	custom := &searchv1alpha1.SearchCustomization{}
+	_ = client.Get(context.TODO(), testSetup.request.NamespacedName, custom)
+	assert.Empty(t, custom.OwnerReferences, "Expected the Search not to own the SearchCustomization.")
+
+	// Deleting the Search releases the projections without importing them again
+	assert.Nil(t, client.Delete(context.TODO(), search))
+	for i := 0; i < 2; i++ {
+		_, err = reconciler.Reconcile(testSetup.context, testSetup.request)
+		assert.Nil(t, err, "Expected reconcile to succeed. Got error: %v", err)
+	}
+	err = client.Get(context.TODO(), testSetup.request.NamespacedName, projected)
+	assert.Nil(t, err, "Expected the SearchOperator to be kept. Got error: %v", err)
+	assert.NotContains(t, projected.Labels, labelProjectedFrom, "Expected the SearchOperator to be released.")
+	err = client.Get(context.TODO(), testSetup.request.NamespacedName, custom)
+	assert.Nil(t, err, "Expected the SearchCustomization to be kept. Got error: %v", err)
+	assert.NotContains(t, custom.Labels, labelProjectedFrom, "Expected the SearchCustomization to be released.")
+	err = client.Get(context.TODO(), testSetup.request.NamespacedName, &searchv1beta1.Search{})
+	assert.True(t, errors.IsNotFound(err), "Expected the released SearchOperator not to be imported again.")
+}
+
+func TestSearchWithoutStorage(t *testing.T) {
+	testSetup := commonSetup()
+	search := testSearch(searchv1beta1.StorageSpec{})
+	custom := createFakeSearchCustomizationCR(testNamespace, true)
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, search, custom)
+	reconciler := SearchReconciler{Client: client, Scheme: testSetup.scheme}
+
+	// The customization is adopted, then deleted so the defaults apply
+	for i := 0; i < 2; i++ {
+		_, err := reconciler.Reconcile(testSetup.context, testSetup.request)
+		assert.Nil(t, err, "Expected projection to succeed. Got error: %v", err)
+	}
+	err := client.Get(context.TODO(), types.NamespacedName{Name: custom.Name, Namespace: testNamespace},
+		&searchv1alpha1.SearchCustomization{})
+	assert.True(t, errors.IsNotFound(err), "Expected the SearchCustomization to be deleted.")
+}
+
+func TestSearchStatus(t *testing.T) {
+	testSetup := commonSetup()
+	search := testSearch(searchv1beta1.StorageSpec{})
+	operator := testSetup.srchOperator.DeepCopy()
+	operator.Status.PersistenceStatus = statusUsingPVC
+	operator.Status.Conditions = []metav1.Condition{{Type: conditionAvailable, Status: metav1.ConditionTrue,
+		Reason: "Healthy", LastTransitionTime: metav1.Now()}}
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, search, operator)
+	reconciler := SearchReconciler{Client: client, Scheme: testSetup.scheme}
+
+	_, err := reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected projection to succeed. Got error: %v", err)
+
+	updated := &searchv1beta1.Search{}
+	_ = client.Get(context.TODO(), testSetup.request.NamespacedName, updated)
+	assert.Equal(t, statusUsingPVC, updated.Status.Redisgraph.Persistence)
+	assert.True(t, meta.IsStatusConditionTrue(updated.Status.Conditions, conditionAvailable),
+		"Expected the conditions of the SearchOperator.")
+	assert.True(t, meta.IsStatusConditionTrue(updated.Status.Conditions, conditionProjected))
+}
+
+func TestSearchObservedGeneration(t *testing.T) {
+	testSetup := commonSetup()
+	search := testSearch(searchv1beta1.StorageSpec{})
+	search.Generation = 4
+	operator := testSetup.srchOperator.DeepCopy()
+	operator.Generation = 2
+	operator.Status.ObservedGeneration = 1
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, search, operator)
+	reconciler := SearchReconciler{Client: client, Scheme: testSetup.scheme}
+
+	_, err := reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected projection to succeed. Got error: %v", err)
+	updated := &searchv1beta1.Search{}
+	_ = client.Get(context.TODO(), testSetup.request.NamespacedName, updated)
+	assert.Equal(t, int64(0), updated.Status.ObservedGeneration,
+		"Expected the Search not to be observed until the SearchOperator reports the projected spec.")
+
+	_ = client.Get(context.TODO(), testSetup.request.NamespacedName, operator)
+	operator.Status.ObservedGeneration = operator.Generation
+	assert.Nil(t, client.Status().Update(context.TODO(), operator))
+	_, err = reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected projection to succeed. Got error: %v", err)
+	_ = client.Get(context.TODO(), testSetup.request.NamespacedName, updated)
+	assert.Equal(t, int64(4), updated.Status.ObservedGeneration)
+}
@@ -5,33 +5,30 @@ package controllers
 
 import (
 	"context"
-	"crypto/rand"
 	"fmt"
-	"math/big"
 	"os"
 	"reflect"
 	"strconv"
 	"time"
 
-	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/util/intstr"
-
 	"github.com/go-logr/logr"
 	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stolostron/search-operator/render"
 	appv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/uuid"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
-	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
@@ -40,13 +37,26 @@ type SearchOperatorReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+	// Prober checks the health of Redisgraph. Health checks are skipped if nil.
+	Prober RedisProber
+	// Registry resolves the digest of the redisgraph image when the image policy pins it.
+	Registry RegistryClient
+	// Verifier checks the signature of the redisgraph image when the image policy requires it.
+	Verifier ImageVerifier
+	// Upgrader saves and verifies the data of Redisgraph when its image changes. Images are updated in place if nil.
+	Upgrader RedisUpgradeClient
+	// ACL configures the Redis ACL users of the search components. ACL users aren't configured if nil.
+	ACL RedisACLClient
+	// APIReader lists the events of a failing redisgraph pod from the API server, which filters them by pod. The
+	// cache can't, and would keep every event of the cluster. The Client is used if nil.
+	APIReader client.Reader
 }
 
 const (
-	appName                   = "search"
-	component                 = "redisgraph"
-	statefulSetName           = "search-redisgraph"
+	appName                   = render.AppName
+	component                 = render.Component
 	redisNotRunning           = "Redisgraph Pod not running"
+	statusInvalidName         = "SearchOperator name reserved by the default instance"
 	statusUsingPVC            = "Redisgraph is using PersistenceVolumeClaim"
 	statusDegradedEmptyDir    = "Degraded mode using EmptyDir. Unable to use PersistenceVolumeClaim"
 	statusUsingNodeEmptyDir   = "Node level persistence using EmptyDir"
@@ -54,249 +64,344 @@ const (
 	statusFailedUsingPVC      = "Unable to create Redisgraph Deployment using PVC"
 	statusFailedNoPersistence = "Unable to create Redisgraph Deployment"
 	statusNoPersistence       = "Redisgraph pod running with persistence disabled"
-	redisUser                 = int64(10001)
-	defaultPvcName            = "search-redisgraph-pvc-0"
-	statusUpdateError         = "Error updating operator/customization status. "
-	errorLogStr               = "Error: "
+	statusUpdateError         = "Error updating operator/customization status"
+	// maxConcurrentReconciles is the number of SearchOperator instances reconciled at the same time
+	maxConcurrentReconciles = 4
 )
 
 var (
-	pvcName              = "search-redisgraph-pvc-0"
 	waitSecondsForPodChk = 180 //Wait for 3 minutes
 	log                  = logf.Log.WithName("searchoperator")
-	persistence          = true
-	allowdegrade         = true
-	storageClass         = ""
-	storageSize          = "10Gi"
-	namespace            = os.Getenv("WATCH_NAMESPACE")
 	releaseName          = os.Getenv("RELEASE_NAME")
 	//Keeping these here as the pod will restart everytime when ENV is updated and we will read the updated values
+	//DEPLOY_REDISGRAPH is only used when spec.database.enabled isn't set in the SearchOperator
 	deployRedisgraphPod, deployVarPresent = os.LookupEnv("DEPLOY_REDISGRAPH")
 	deploy, deployVarErr                  = strconv.ParseBool(deployRedisgraphPod)
 )
-var startingSpec searchv1alpha1.SearchCustomizationSpec
-var deployStatus bool
 
-func (r *SearchOperatorReconciler) Reconcile(con context.Context, req ctrl.Request) (ctrl.Result, error) {
+// The reads go through the cache of the manager, so every type read also needs list and watch.
+// +kubebuilder:rbac:groups=search.open-cluster-management.io,resources=searchoperators,verbs=get;list;watch
+// +kubebuilder:rbac:groups=search.open-cluster-management.io,resources=searchoperators/status,verbs=update
+// +kubebuilder:rbac:groups=search.open-cluster-management.io,resources=searchoperators/finalizers,verbs=update
+// +kubebuilder:rbac:groups=search.open-cluster-management.io,resources=searchcustomizations,verbs=get;list;watch
+// +kubebuilder:rbac:groups=search.open-cluster-management.io,resources=searchcustomizations/status,verbs=update
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;delete
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=list;watch;patch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;delete
+// +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch;create;update
+// +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;delete
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups="",resources=pods,verbs=list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=list
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;delete
+
+func (r *SearchOperatorReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// Every log line written while reconciling this request carries the reconcile ID
+	ctx = logf.IntoContext(ctx, r.Log.WithValues("reconcileID", uuid.NewUUID(), "namespace", req.Namespace))
+	return r.newRequest(req).reconcile(ctx, req)
+}
 
-	_ = context.Background()
-	_ = r.Log.WithValues("searchoperator", req.NamespacedName)
+// reconcile brings the redisgraph of the SearchOperator instance of the request to its spec.
+func (r *reconcileRequest) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
 	// Fetch the SearchOperator instance
 	instance := &searchv1alpha1.SearchOperator{}
-	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: "searchoperator", Namespace: req.Namespace}, instance)
+	err := r.Client.Get(ctx, req.NamespacedName, instance)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			// Request object not found, could have been deleted after reconcile request.
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
-			// Return and don't requeue
-			return ctrl.Result{}, nil
+			// Report customizations still referencing the instance, return and don't requeue
+			return ctrl.Result{}, r.markUnmatchedCustomizations(ctx, req.Namespace, req.Name)
 		}
 		// Error reading the object - requeue the request.
 		return ctrl.Result{}, err
 	}
+	// An instance named like the objects of the default instance would take them over, the name can't change
+	if err = render.ValidateInstanceName(instance.Name); err != nil {
+		log.Error(err, "Not reconciling the SearchOperator")
+		return ctrl.Result{}, r.rejectInstanceName(ctx, instance, err)
+	}
 
-	// Fetch the SearchCustomization instance
-	custom := &searchv1alpha1.SearchCustomization{}
+	// Fetch the SearchCustomization instance that applies to this SearchOperator
 	customValuesInuse := false
-	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: "searchcustomization", Namespace: req.Namespace}, custom)
+	custom, err := r.findCustomization(ctx, instance)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			// Request object not found, could have been deleted after reconcile request.
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Set the values to defult
-			persistence = true
-			allowdegrade = true
-			storageClass = ""
-			storageSize = "10Gi"
-			pvcName = defaultPvcName
-			startingSpec = searchv1alpha1.SearchCustomizationSpec{}
+			r.setCustomValues(nil)
 		} else {
 			return ctrl.Result{}, err
 		}
 
 	} else {
-		if custom.Spec.Persistence != nil && *custom.Spec.Persistence == false {
-			persistence = false
-		} else {
-			persistence = true
-		}
-		// Allowdegrade mode helps the user to set the controller from switching back to emptydir
-		// and debug users configuration
-		allowdegrade = false
-		storageClass = ""
-		storageSize = "10Gi"
-		pvcName = defaultPvcName
-		if custom.Spec.StorageClass != "" {
-			storageClass = custom.Spec.StorageClass
-			pvcName = storageClass + "-search-redisgraph-0"
-		}
-		if custom.Spec.StorageSize != "" {
-			storageSize = custom.Spec.StorageSize
-		}
 		//set the  user provided values
+		r.setCustomValues(custom)
 		customValuesInuse = true
-		startingSpec = custom.Spec
-		r.Log.Info(fmt.Sprintf("Storage %s", storageSize))
 	}
 
-	r.Log.Info("Checking if customization CR is created..", " Custom Values In use? ", customValuesInuse)
-	r.Log.Info("Values in use: ", "persistence? ", persistence, " storageClass? ", storageClass,
-		" storageSize? ", storageSize, " fallbackToEmptyDir? ", allowdegrade)
+	log.Info("Values in use", "customValuesInUse", customValuesInuse, "persistence", r.persistence,
+		"storageClass", r.storageClass, "storageSize", r.storageSize, "fallbackToEmptyDir", r.allowdegrade)
+
+	r.setDatabaseState(instance)
+	// In dry run only report the changes a reconcile would make
+	if isDryRun(instance) {
+		if err = r.reportPlan(ctx, instance); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+	// While paused only report what is observed, don't change any resource
+	if isPaused(instance) {
+		status := r.observedStatus(ctx, instance)
+		log.Info("SearchOperator is paused, not changing any resource", "phase", status)
+		if status != redisNotRunning && status != statusMaintenance {
+			r.checkRedisHealth(ctx, instance)
+		}
+		if err = r.updateOperatorCR(ctx, r.Client, instance, status); err != nil {
+			return ctrl.Result{}, err
+		}
+		return r.healthCheckResult(), nil
+	}
 
 	// Create secret if not found
-	err = r.setupSecret(r.Client, instance)
+	err = r.setupSecret(ctx, r.Client, instance)
 	if err != nil {
 		// Error setting up secret - requeue the request.
-		if err = updateCRs(r.Client, instance, redisNotRunning,
-			custom, persistence, storageClass, storageSize, customValuesInuse); err != nil {
-			r.Log.Info(statusUpdateError, errorLogStr, err)
+		if err = r.updateCRs(ctx, r.Client, instance, redisNotRunning,
+			custom, r.persistence, r.storageClass, r.storageSize, customValuesInuse); err != nil {
+			log.Error(err, statusUpdateError)
+		}
+		return ctrl.Result{}, err
+	}
+	// Run redisgraph as its own ServiceAccount, without API permissions
+	if err = r.setupServiceAccount(ctx, instance); err != nil {
+		log.Error(err, "Error setting up ServiceAccount", "serviceAccount", r.names.ServiceAccount)
+		return ctrl.Result{}, err
+	}
+	// Give each search component its own ACL user before redisgraph mounts their ACL file
+	if err = r.reconcileComponentUsers(ctx, instance); err != nil {
+		log.Error(err, "Error setting up the component users", "secret", r.names.RedisSecret)
+		return ctrl.Result{}, err
+	}
+	// Publish how to connect to the redisgraph deployed by the operator, external endpoints are published later
+	if !r.deployEnabled {
+		if err = r.deleteConnectionSecret(ctx, r.Client); err != nil {
+			log.Error(err, "Error deleting connection secret", "secret", r.names.ConnectionSecret)
 		}
+	} else if externalDatabase(instance) == nil {
+		if err = r.publishConnection(ctx, instance); err != nil {
+			log.Error(err, "Error publishing connection secret", "secret", r.names.ConnectionSecret)
+		}
+		r.syncDependents(ctx, instance, false)
+	}
+	// Create the Service the connection secret points to
+	if err = r.reconcileService(ctx, instance); err != nil {
+		log.Error(err, "Error reconciling Service", "service", r.names.StatefulSet)
+		return ctrl.Result{}, err
+	}
+	// Only admit the search components and the operator to redisgraph
+	if err = r.reconcileNetworkPolicy(ctx, instance); err != nil {
+		log.Error(err, "Error reconciling NetworkPolicy", "networkPolicy", r.names.StatefulSet)
 		return ctrl.Result{}, err
 	}
 
 	//Read the searchoperator status
 	persistenceStatus := instance.Status.PersistenceStatus
-	if instance.Status.DeployRedisgraph != nil {
-		deployStatus = *instance.Status.DeployRedisgraph
-	}
 	// Setup RedisGraph Deployment
-	r.Log.Info(fmt.Sprintf("Config in  Use Persistence/AllowDegrade %t/%t", persistence, allowdegrade))
-	//if deploy env variable is false, don't deploy Redisgraph pod
-	if deployVarPresent && deployVarErr == nil && !deploy {
-		err := deleteRedisStatefulSet(r.Client) //if redisgraph pod is already deployed, delete it.
+	//if the database is disabled, don't deploy Redisgraph pod
+	if !r.deployEnabled {
+		err := r.deleteRedisStatefulSet(ctx, r.Client) //if redisgraph pod is already deployed, delete it.
 		if err != nil {
-			if err = updateCRs(r.Client, instance, redisNotRunning,
-				custom, persistence, storageClass, storageSize, customValuesInuse); err != nil {
-				r.Log.Info(statusUpdateError, errorLogStr, err)
+			if err = r.updateCRs(ctx, r.Client, instance, redisNotRunning,
+				custom, r.persistence, r.storageClass, r.storageSize, customValuesInuse); err != nil {
+				log.Error(err, statusUpdateError)
 			}
 			return ctrl.Result{}, err
 		}
-		r.Log.Info(`Not deploying the database. This is not an error, it's a current limitation in this environment.
+		log.Info(`Not deploying the database. This is not an error, it's a current limitation in this environment.
 	The search feature is not operational.  More info: https://github.com/open-cluster-management-io/community/issues/34`)
 		//Write Status
-		err = updateCRs(r.Client, instance, redisNotRunning,
-			custom, persistence, storageClass, storageSize, customValuesInuse)
+		err = r.updateCRs(ctx, r.Client, instance, redisNotRunning,
+			custom, r.persistence, r.storageClass, r.storageSize, customValuesInuse)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
 		return ctrl.Result{}, nil
 	}
-	if persistence {
-		expectedSts := r.expectedStatefulSet(r.Client,
-			instance, true, persistence)
+	// Use the external endpoint instead of deploying redisgraph
+	if externalDatabase(instance) != nil {
+		return r.reconcileExternal(ctx, instance, custom, customValuesInuse)
+	}
+	// Maintenance mode stops redisgraph without deleting the StatefulSet or the PVC
+	if instance.Spec.Maintenance {
+		if err := r.scaleRedisToZero(ctx, r.Client); err != nil {
+			if err = r.updateCRs(ctx, r.Client, instance, redisNotRunning,
+				custom, r.persistence, r.storageClass, r.storageSize, customValuesInuse); err != nil {
+				log.Error(err, statusUpdateError)
+			}
+			return ctrl.Result{}, err
+		}
+		err = r.updateCRs(ctx, r.Client, instance, statusMaintenance,
+			custom, r.persistence, r.storageClass, r.storageSize, customValuesInuse)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+	// Resolve and verify the redisgraph image before rolling it out, a rejected image keeps the running one
+	if err = r.checkImage(ctx, instance); err != nil {
+		log.Info("Not rolling out the redisgraph image", "reason", err.Error())
+		r.imageRetryAfter = imageRetryResult(err)
+		held, holdErr := r.holdRunningImage(ctx)
+		if holdErr != nil || !held {
+			if err := r.updateCRs(ctx, r.Client, instance, r.observedStatus(ctx, instance),
+				custom, r.persistence, r.storageClass, r.storageSize, customValuesInuse); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: r.imageRetryAfter}, holdErr
+		}
+	} else if err = r.upgradeRedisgraph(ctx, instance); err != nil {
+		// Save the data before rolling redisgraph to a new image, the previous image is restored if it isn't loaded
+		log.Info("Not upgrading the redisgraph image", "reason", err.Error())
+		if err := r.updateCRs(ctx, r.Client, instance, r.observedStatus(ctx, instance),
+			custom, r.persistence, r.storageClass, r.storageSize, customValuesInuse); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: upgradeRetryInterval}, nil
+	} else if r.rollingUpgrade() {
+		// The pod rolling to a new or the previous image isn't waited for, it's checked again by the next reconcile
+		log.Info("Redisgraph upgrade in progress", "phase", r.upgradeRecord.Phase)
+		if err := r.updateCRs(ctx, r.Client, instance, r.observedStatus(ctx, instance),
+			custom, r.persistence, r.storageClass, r.storageSize, customValuesInuse); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.upgradeRequeueAfter}, nil
+	}
+	if r.persistence {
+		expectedSts := r.expectedStatefulSet(ctx, r.Client,
+			instance, true, r.persistence)
 		//If running PVC deployment nothing to do
 		// Do nothing if status (persistence and deploy) in searchoperator is up-to-date with statusUsingPVC
 		// and statefulset is available and up-to-date
 		// and pod is running with PVC volume
-		if persistenceStatus == statusUsingPVC && deployStatus == deploy && isStatefulSetAvailable(r.Client) &&
-			!statefulSetNeedsUpdate(r.Client, expectedSts) && r.isPodRunning(true, 1) {
-			r.Log.Info("Redisgraph Pod running successfully with PVC.")
-			return ctrl.Result{}, nil
-		}
-		expectedSts = r.expectedStatefulSet(r.Client,
-			instance, false, persistence)
+		if persistenceStatus == statusUsingPVC && r.deployStatus == r.deployEnabled &&
+			r.isStatefulSetAvailable(ctx, r.Client) && !r.statefulSetNeedsUpdate(ctx, r.Client, expectedSts) &&
+			r.isPodRunning(ctx, true, 1) {
+			log.Info("Redisgraph Pod running successfully with PVC", "phase", statusUsingPVC, "pvc", r.pvcName)
+			return r.refreshHealth(ctx, instance, statusUsingPVC,
+				custom, r.persistence, r.storageClass, r.storageSize, customValuesInuse)
+		}
+		expectedSts = r.expectedStatefulSet(ctx, r.Client,
+			instance, false, r.persistence)
 		//If running degraded deployment AND AllowDegradeMode is set
 		// Do nothing if status (persistence and deploy) in searchoperator is up-to-date with statusDegradedEmptyDir
 		// and statefulset is available and up-to-date
 		// and pod is running with emptyDir volume
-		if allowdegrade && persistenceStatus == statusDegradedEmptyDir &&
-			deployStatus == deploy && isStatefulSetAvailable(r.Client) &&
-			!statefulSetNeedsUpdate(r.Client, expectedSts) && r.isPodRunning(false, 1) {
-			r.Log.Info("Redisgraph Pod running successfully with EmptyDir.")
-			return ctrl.Result{}, nil
-		}
-		//Restart search-collector pod while setting up Redisgraph pod
-		if deployVarPresent && deployVarErr == nil && deploy {
-			srchOp, err := fetchSrchOperator(r.Client, instance)
-			//If Redisgraph was disabled, collector will be in a 10 minute timeout loop.
-			//Restart collector and api pods while deploying Redisgraph.
-			if err == nil && srchOp.Status.DeployRedisgraph != nil && *srchOp.Status.DeployRedisgraph == false {
-				r.Log.Info("Restarting search-collector and search-api pods")
-				//restart collector and api pods
-				r.restartSearchComponents()
-			}
+		if r.allowdegrade && persistenceStatus == statusDegradedEmptyDir &&
+			r.deployStatus == r.deployEnabled && r.isStatefulSetAvailable(ctx, r.Client) &&
+			!r.statefulSetNeedsUpdate(ctx, r.Client, expectedSts) && r.isPodRunning(ctx, false, 1) {
+			log.Info("Redisgraph Pod running successfully with EmptyDir", "phase", statusDegradedEmptyDir)
+			return r.refreshHealth(ctx, instance, statusDegradedEmptyDir, custom, false, "", "", customValuesInuse)
 		}
-		pvcError := setupVolume(r.Client)
+		pvcError := r.setupVolume(ctx, r.Client)
 		if pvcError != nil {
-			if err = updateCRs(r.Client, instance, redisNotRunning,
-				custom, persistence, storageClass, storageSize, customValuesInuse); err != nil {
-				r.Log.Info(statusUpdateError, errorLogStr, err)
+			if err = r.updateCRs(ctx, r.Client, instance, redisNotRunning,
+				custom, r.persistence, r.storageClass, r.storageSize, customValuesInuse); err != nil {
+				log.Error(err, statusUpdateError)
 			}
 			return ctrl.Result{}, pvcError
 		}
-		r.Log.Info("PVC volume set up successfully")
-		r.executeDeployment(r.Client, instance, true, persistence)
-		podReady := r.isPodRunning(true, waitSecondsForPodChk)
+		log.Info("PVC volume set up successfully", "pvc", r.pvcName, "storageClass", r.storageClass)
+		r.executeDeployment(ctx, r.Client, instance, true, r.persistence)
+		podReady := r.isPodRunning(ctx, true, waitSecondsForPodChk)
 		if podReady {
+			r.configureACL(ctx, instance)
+			r.checkRedisHealth(ctx, instance)
+			r.completeDatabaseTransition(ctx, instance)
 			//Write Status
-			err := updateCRs(r.Client, instance, statusUsingPVC,
-				custom, persistence, storageClass, storageSize, customValuesInuse)
+			err := r.updateCRs(ctx, r.Client, instance, statusUsingPVC,
+				custom, r.persistence, r.storageClass, r.storageSize, customValuesInuse)
 			if err != nil {
 				return ctrl.Result{}, err
 			}
+			return r.healthCheckResult(), nil
 		}
-		//If Pod cannot be scheduled rollback to EmptyDir if AllowDegradeMode is set
-		if !podReady && allowdegrade {
-			r.Log.Info("Degrading Redisgraph deployment to use empty dir.")
-			err := deleteRedisStatefulSet(r.Client)
+		//If Pod cannot use the PVC rollback to EmptyDir if AllowDegradeMode is set
+		storageFailure := r.redisPodFailure.storageRelated()
+		if !podReady && r.allowdegrade && storageFailure {
+			log.Info("Degrading Redisgraph deployment to use empty dir", "reason", r.redisPodFailure.String())
+			err := r.deleteRedisStatefulSet(ctx, r.Client)
 			if err != nil {
-				if err = updateCRs(r.Client, instance, redisNotRunning,
-					custom, persistence, storageClass, storageSize, customValuesInuse); err != nil {
-					r.Log.Info(statusUpdateError, errorLogStr, err)
+				if err = r.updateCRs(ctx, r.Client, instance, redisNotRunning,
+					custom, r.persistence, r.storageClass, r.storageSize, customValuesInuse); err != nil {
+					log.Error(err, statusUpdateError)
 				}
 				return ctrl.Result{}, err
 			}
-			r.Log.Info("Deleted statefulset to move to emptyDir")
-			err = deletePVC(r.Client)
+			log.Info("Deleted statefulset to move to emptyDir")
+			err = r.deletePVC(ctx, r.Client)
 			if err != nil {
-				if err = updateCRs(r.Client, instance, redisNotRunning,
-					custom, persistence, storageClass, storageSize, customValuesInuse); err != nil {
-					r.Log.Info(statusUpdateError, errorLogStr, err)
+				if err = r.updateCRs(ctx, r.Client, instance, redisNotRunning,
+					custom, r.persistence, r.storageClass, r.storageSize, customValuesInuse); err != nil {
+					log.Error(err, statusUpdateError)
 				}
 				return ctrl.Result{}, err
 			}
-			r.Log.Info("Deleted PVC to move to emptyDir")
-			r.executeDeployment(r.Client, instance, false, persistence)
-			if r.isPodRunning(false, waitSecondsForPodChk) {
-				r.Log.Info("Pod set up and running successfully with emptyDir. Updating status...")
+			log.Info("Deleted PVC to move to emptyDir", "pvc", r.pvcName)
+			r.executeDeployment(ctx, r.Client, instance, false, r.persistence)
+			if r.isPodRunning(ctx, false, waitSecondsForPodChk) {
+				log.Info("Pod set up and running successfully with emptyDir. Updating status...")
+				r.configureACL(ctx, instance)
+				r.checkRedisHealth(ctx, instance)
+				r.completeDatabaseTransition(ctx, instance)
 				//Write Status
-				err := updateCRs(r.Client, instance, statusDegradedEmptyDir,
+				err := r.updateCRs(ctx, r.Client, instance, statusDegradedEmptyDir,
 					custom, false, "", "", customValuesInuse)
 				if err != nil {
 					return ctrl.Result{}, err
 				} else {
-					return ctrl.Result{}, nil
+					return r.healthCheckResult(), nil
 				}
 			} else {
-				r.Log.Info("Unable to create Redisgraph Deployment in Degraded Mode")
+				log.Info("Unable to create Redisgraph Deployment in Degraded Mode", "reason", r.redisPodFailure.String())
 				//Write Status, delete statefulset and requeue
-				r.reconcileOnError(instance, statusFailedDegraded, custom, false, "", "", customValuesInuse)
+				r.reconcileOnError(ctx, instance, statusFailedDegraded, custom, false, "", "", customValuesInuse)
 				return ctrl.Result{RequeueAfter: 5 * time.Second}, fmt.Errorf(redisNotRunning)
 			}
 		}
-		if !podReady && !allowdegrade {
-			r.Log.Info("Unable to create Redisgraph Deployment using PVC ")
+		if !podReady && (!r.allowdegrade || !storageFailure) {
+			log.Info("Unable to create Redisgraph Deployment using PVC", "pvc", r.pvcName,
+				"storageClass", r.storageClass, "reason", r.redisPodFailure.String())
 			//Write Status, delete statefulset and requeue
-			r.reconcileOnError(instance, statusFailedUsingPVC, custom, false, "", "", customValuesInuse)
+			r.reconcileOnError(ctx, instance, statusFailedUsingPVC, custom, false, "", "", customValuesInuse)
 			return ctrl.Result{RequeueAfter: 5 * time.Second}, fmt.Errorf(redisNotRunning)
 		}
 	} else {
-		if isStatefulSetAvailable(r.Client) && r.isPodRunning(false, 1) &&
-			persistenceStatus == statusNoPersistence && deployStatus == deploy {
-			return ctrl.Result{}, nil
-		}
-		r.Log.Info("Using Deployment with persistence disabled")
-		r.executeDeployment(r.Client, instance, false, persistence)
-		if r.isPodRunning(false, waitSecondsForPodChk) {
+		if r.isStatefulSetAvailable(ctx, r.Client) && r.isPodRunning(ctx, false, 1) &&
+			persistenceStatus == statusNoPersistence && r.deployStatus == r.deployEnabled {
+			return r.refreshHealth(ctx, instance, statusNoPersistence, custom, false, "", "", customValuesInuse)
+		}
+		log.Info("Using Deployment with persistence disabled")
+		r.executeDeployment(ctx, r.Client, instance, false, r.persistence)
+		if r.isPodRunning(ctx, false, waitSecondsForPodChk) {
+			r.configureACL(ctx, instance)
+			r.checkRedisHealth(ctx, instance)
+			r.completeDatabaseTransition(ctx, instance)
 			//Write Status, if error - requeue
-			err := updateCRs(r.Client, instance, statusNoPersistence, custom, false, "", "", customValuesInuse)
+			err := r.updateCRs(ctx, r.Client, instance, statusNoPersistence, custom, false, "", "", customValuesInuse)
 			if err != nil {
 				return ctrl.Result{}, err
 			}
+			return r.healthCheckResult(), nil
 		} else {
-			r.Log.Info("Unable to create Redisgraph Deployment with persistence disabled")
+			log.Info("Unable to create Redisgraph Deployment with persistence disabled",
+				"reason", r.redisPodFailure.String())
 			//Write Status, delete statefulset and requeue
-			r.reconcileOnError(instance, statusFailedNoPersistence, custom, false, "", "", customValuesInuse)
+			r.reconcileOnError(ctx, instance, statusFailedNoPersistence, custom, false, "", "", customValuesInuse)
 			return ctrl.Result{RequeueAfter: 5 * time.Second}, fmt.Errorf(redisNotRunning)
 		}
 
@@ -304,105 +409,110 @@ func (r *SearchOperatorReconciler) Reconcile(con context.Context, req ctrl.Reque
 	return ctrl.Result{}, nil
 }
 
-func (r *SearchOperatorReconciler) reconcileOnError(instance *searchv1alpha1.SearchOperator, status string,
-	custom *searchv1alpha1.SearchCustomization, persistence bool, storageClass string,
+// setCustomValues sets the persistence values in use from the SearchCustomization, the defaults if it's nil.
+func (r *reconcileRequest) setCustomValues(custom *searchv1alpha1.SearchCustomization) {
+	storage := render.StorageFor(r.names, custom)
+	r.persistence = storage.Persistence
+	// Allowdegrade mode helps the user to set the controller from switching back to emptydir
+	// and debug users configuration
+	r.allowdegrade = storage.AllowDegrade
+	r.storageClass = storage.StorageClass
+	r.storageSize = storage.StorageSize
+	r.pvcName = storage.PVCName
+	r.startingSpec = searchv1alpha1.SearchCustomizationSpec{}
+	r.customizationName = ""
+	if custom != nil {
+		r.startingSpec = custom.Spec
+		r.customizationName = custom.Name
+	}
+}
+
+// refreshHealth checks the health of a Redisgraph that is already running as expected and updates the status.
+// Nothing needs to be done if health checks are disabled, the rollout status of the dependents is unchanged and the
+// status is current.
+func (r *reconcileRequest) refreshHealth(ctx context.Context, instance *searchv1alpha1.SearchOperator,
+	status string, custom *searchv1alpha1.SearchCustomization, persistence bool, storageClass string,
+	storageSize string, customValuesInuse bool) (ctrl.Result, error) {
+	// The ACL users are lost when redis restarts, they are configured again before the status is compared
+	r.configureACL(ctx, instance)
+	if r.Prober == nil && reflect.DeepEqual(instance.Status.Dependents, r.dependentsStatus) &&
+		r.observedStatusCurrent(ctx, r.Client, instance) {
+		return r.healthCheckResult(), nil
+	}
+	r.checkRedisHealth(ctx, instance)
+	if err := r.updateCRs(ctx, r.Client, instance, status,
+		custom, persistence, storageClass, storageSize, customValuesInuse); err != nil {
+		return ctrl.Result{}, err
+	}
+	return r.healthCheckResult(), nil
+}
+
+func (r *reconcileRequest) reconcileOnError(ctx context.Context, instance *searchv1alpha1.SearchOperator,
+	status string, custom *searchv1alpha1.SearchCustomization, persistence bool, storageClass string,
 	storageSize string, customValuesInuse bool) {
+	log := logf.FromContext(ctx)
 	var err error
-	if err = updateCRs(r.Client, instance, status, custom, false,
+	if err = r.updateCRs(ctx, r.Client, instance, status, custom, false,
 		storageClass, storageSize, customValuesInuse); err != nil {
-		r.Log.Info(statusUpdateError, errorLogStr, err)
+		log.Error(err, statusUpdateError, "phase", status)
 	}
-	if err = deleteRedisStatefulSet(r.Client); err != nil {
-		r.Log.Info("Error deleting statefulset. ", errorLogStr, err)
+	if err = r.deleteRedisStatefulSet(ctx, r.Client); err != nil {
+		log.Error(err, "Error deleting statefulset")
 	}
 }
 
-// Restart search collector and api pods
-func (r *SearchOperatorReconciler) restartSearchComponents() {
-	allComponents := map[string]map[string]string{}
-	allComponents["Search-collector"] = map[string]string{"app": "search-prod", "component": "search-collector"}
-	allComponents["Search-api"] = map[string]string{"app": "search", "component": "search-api"}
-
-	for compName, compLabels := range allComponents {
-		opts := getOptions(compLabels)
-		podList := &corev1.PodList{}
-		err := r.Client.List(context.TODO(), podList, opts...)
-		if err != nil || len(podList.Items) == 0 {
-			if len(podList.Items) == 0 {
-				r.Log.Info(fmt.Sprintf("Failed to find %s pods. %d pods found", compName, len(podList.Items)))
-			}
-			r.Log.Info(fmt.Sprintf("Error listing pods for component: %s", compName), "Err:", err)
-			continue
-		}
-
-		for _, item := range podList.Items {
-			compPod := &corev1.Pod{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      item.Name,
-					Namespace: item.Namespace,
-				},
-			}
-			err := r.Client.Delete(context.TODO(), compPod)
-			if err != nil && !errors.IsNotFound(err) {
-				r.Log.Info("Failed to delete pods for ", "component", compName)
-				r.Log.Info(err.Error())
-				// Not needed to act on the error as restarting is to offset the timeout
-				// search will continue to function
-				continue
-			}
-			r.Log.Info(fmt.Sprintf("%s pod deleted. Namespace/Name: %s/%s", compName, item.Namespace, item.Name))
-		}
-	}
+// Restart search collector and api, with a rolling restart of the Deployments allowing it
+func (r *reconcileRequest) restartSearchComponents(ctx context.Context, cr *searchv1alpha1.SearchOperator) {
+	r.syncDependents(ctx, cr, true)
 }
 
 func (r *SearchOperatorReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	watchNamespace := os.Getenv("WATCH_NAMESPACE")
+	watchNamespaces := WatchNamespaces(os.Getenv("WATCH_NAMESPACE"))
 	pred := predicate.Funcs{
 		CreateFunc: func(e event.CreateEvent) bool {
-			return e.Object.GetNamespace() == watchNamespace
+			return namespaceWatched(watchNamespaces, e.Object.GetNamespace())
 		},
 		UpdateFunc: func(e event.UpdateEvent) bool {
-			if e.ObjectNew.GetNamespace() == watchNamespace &&
-				e.ObjectNew.GetGeneration() != e.ObjectOld.GetGeneration() {
+			if namespaceWatched(watchNamespaces, e.ObjectNew.GetNamespace()) &&
+				(e.ObjectNew.GetGeneration() != e.ObjectOld.GetGeneration() ||
+					pausedChanged(e.ObjectOld, e.ObjectNew) || dryRunChanged(e.ObjectOld, e.ObjectNew) ||
+					secretDataChanged(e.ObjectOld, e.ObjectNew) ||
+					redisgraphBecameReady(e.ObjectOld, e.ObjectNew)) {
 				return true
 			}
 			return false
 		},
 		DeleteFunc: func(e event.DeleteEvent) bool {
-			if e.Object.GetNamespace() == watchNamespace {
+			if namespaceWatched(watchNamespaces, e.Object.GetNamespace()) {
 				return !e.DeleteStateUnknown
 			}
 			return false
 		},
 	}
 
-	searchCustomizationFn := handler.MapFunc(
-		func(a client.Object) []reconcile.Request {
-			return []reconcile.Request{
-				{NamespacedName: types.NamespacedName{
-					Name:      "searchcustomization",
-					Namespace: watchNamespace,
-				}},
-			}
-		})
+	searchCustomizationFn := handler.MapFunc(searchCustomizationRequests)
 
+	// Each reconcile keeps its state in its own reconcileRequest, so instances are reconciled concurrently.
 	return ctrl.NewControllerManagedBy(mgr).
+		WithOptions(controller.Options{MaxConcurrentReconciles: maxConcurrentReconciles}).
 		For(&searchv1alpha1.SearchOperator{}).
 		Owns(&appv1.StatefulSet{}).
 		Owns(&corev1.Secret{}).
+		Owns(&corev1.ServiceAccount{}).
+		Owns(&corev1.Service{}).
+		Owns(&networkingv1.NetworkPolicy{}).
 		Watches(&source.Kind{Type: &searchv1alpha1.SearchCustomization{}}, handler.EnqueueRequestsFromMapFunc(searchCustomizationFn)).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.connectionSecretRequests)).
 		WithEventFilter(pred).Complete(r)
 }
 
 func int32Ptr(i int32) *int32 { return &i }
 
-func int64Ptr(i int64) *int64 { return &i }
-
 // compareLabels compares two map[string]string structs
 // Returns false if all key-value pairs in first map is not in second map
 // Else returns true
 // Used to check if all the expected labels are present in the current running statefulset
-func compareLabels(metadataLabels, ssetLabels map[string]string) bool {
+func compareLabels(ctx context.Context, metadataLabels, ssetLabels map[string]string) bool {
 	allLabelsPresent := true
 	for label, value := range metadataLabels {
 		ssetVal, labelPresent := ssetLabels[label]
@@ -410,268 +520,175 @@ func compareLabels(metadataLabels, ssetLabels map[string]string) bool {
 			continue
 		} else {
 			allLabelsPresent = false
-			log.Info("Not all labels present in statefulset. Label: ", label, "not found in metadata")
+			logf.FromContext(ctx).Info("Not all labels present in statefulset", "label", label)
 			break
 		}
 	}
 	return allLabelsPresent
 }
-func (r *SearchOperatorReconciler) getStatefulSet(cr *searchv1alpha1.SearchOperator,
+
+// getStatefulSet returns the running StatefulSet updated with the rendered one. Only the fields the operator
+// owns are replaced, the ones set by the cluster are kept.
+func (r *reconcileRequest) getStatefulSet(ctx context.Context, cr *searchv1alpha1.SearchOperator,
 	rdbVolumeSource corev1.VolumeSource, saverdb string) *appv1.StatefulSet {
+	log := logf.FromContext(ctx)
 	sset := &appv1.StatefulSet{}
-	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: statefulSetName, Namespace: namespace}, sset)
-	if err != nil {
-		r.Log.Info("Error fetching Statefulset")
-	}
-	bool := false
-	metadataLabels := map[string]string{}
-	metadataLabels["release"] = releaseName
-	metadataLabels["component"] = component
-	metadataLabels["app"] = appName
-	if !compareLabels(metadataLabels, sset.Labels) {
-		sset.Labels = metadataLabels
-	}
-	sset.ObjectMeta.Name = statefulSetName
-	sset.ObjectMeta.Namespace = cr.Namespace
-	sset.Spec.Replicas = int32Ptr(1)
-	sset.Spec.Selector = &metav1.LabelSelector{
-		MatchLabels: map[string]string{
-			"component": component,
-			"app":       appName,
-		},
-	}
-	sset.Spec.Template.ObjectMeta.Labels = metadataLabels
-	sset.Spec.Template.Spec.ServiceAccountName = "search-operator"
-	tol := corev1.Toleration{
-		Key:      "node-role.kubernetes.io/infra",
-		Effect:   corev1.TaintEffectNoSchedule,
-		Operator: corev1.TolerationOpExists,
-	}
-	sset.Spec.Template.Spec.Tolerations = []corev1.Toleration{tol}
-	pullSecret := corev1.LocalObjectReference{
-		Name: cr.Spec.PullSecret,
-	}
-	sset.Spec.Template.Spec.ImagePullSecrets = []corev1.LocalObjectReference{pullSecret}
-	if sset.Spec.Template.Spec.SecurityContext != nil {
-		sset.Spec.Template.Spec.SecurityContext.FSGroup = int64Ptr(redisUser)
-		sset.Spec.Template.Spec.SecurityContext.RunAsUser = int64Ptr(redisUser)
+	err := r.Client.Get(ctx, types.NamespacedName{Name: r.names.StatefulSet, Namespace: r.namespace}, sset)
+	if err != nil && !errors.IsNotFound(err) {
+		log.Error(err, "Error fetching Statefulset", "statefulSet", r.names.StatefulSet)
+	}
+	expected := render.StatefulSet(cr, r.names, releaseName, rdbVolumeSource, saverdb)
+	if !compareLabels(ctx, expected.Labels, sset.Labels) {
+		sset.Labels = expected.Labels
+	}
+	sset.ObjectMeta.Name = expected.Name
+	sset.ObjectMeta.Namespace = expected.Namespace
+	sset.Spec.Replicas = expected.Spec.Replicas
+	sset.Spec.Selector = expected.Spec.Selector
+	sset.Spec.Template.ObjectMeta.Labels = expected.Spec.Template.ObjectMeta.Labels
+	podSpec, expectedPodSpec := &sset.Spec.Template.Spec, expected.Spec.Template.Spec
+	podSpec.ServiceAccountName = expectedPodSpec.ServiceAccountName
+	podSpec.AutomountServiceAccountToken = expectedPodSpec.AutomountServiceAccountToken
+	podSpec.Tolerations = expectedPodSpec.Tolerations
+	podSpec.ImagePullSecrets = expectedPodSpec.ImagePullSecrets
+	if podSpec.SecurityContext != nil {
+		podSpec.SecurityContext.FSGroup = expectedPodSpec.SecurityContext.FSGroup
+		podSpec.SecurityContext.RunAsUser = expectedPodSpec.SecurityContext.RunAsUser
+		podSpec.SecurityContext.RunAsNonRoot = expectedPodSpec.SecurityContext.RunAsNonRoot
+		podSpec.SecurityContext.SeccompProfile = expectedPodSpec.SecurityContext.SeccompProfile
 	} else {
-		sset.Spec.Template.Spec.SecurityContext = &corev1.PodSecurityContext{
-			FSGroup:   int64Ptr(redisUser),
-			RunAsUser: int64Ptr(redisUser),
-		}
-	}
-	sset.Spec.Template.Spec.Containers = []corev1.Container{
-		{
-			Name:  "redisgraph",
-			Image: cr.Spec.SearchImageOverrides.Redisgraph_TLS,
-			Env: []corev1.EnvVar{
-				{
-					Name: "REDIS_PASSWORD",
-					ValueFrom: &corev1.EnvVarSource{
-						SecretKeyRef: &corev1.SecretKeySelector{
-							LocalObjectReference: corev1.LocalObjectReference{
-								Name: "redisgraph-user-secret",
-							},
-							Key: "redispwd",
-						},
-					},
-				},
-				{
-					Name:  "REDIS_GRAPH_SSL",
-					Value: "true",
-				},
-				{
-					Name:  "SAVERDB",
-					Value: saverdb,
-				},
-			},
-			LivenessProbe: &corev1.Probe{
-				InitialDelaySeconds: 10,
-				TimeoutSeconds:      1,
-				PeriodSeconds:       15,
-				SuccessThreshold:    1,
-				FailureThreshold:    3,
-				ProbeHandler: corev1.ProbeHandler{
-					TCPSocket: &corev1.TCPSocketAction{
-						Port: intstr.FromInt(6380),
-					},
-				},
-			},
-			ReadinessProbe: &corev1.Probe{
-				InitialDelaySeconds: 5,
-				TimeoutSeconds:      1,
-				PeriodSeconds:       15,
-				SuccessThreshold:    1,
-				FailureThreshold:    3,
-				ProbeHandler: corev1.ProbeHandler{
-					TCPSocket: &corev1.TCPSocketAction{
-						Port: intstr.FromInt(6380),
-					},
-				},
-			},
-			Resources: corev1.ResourceRequirements{
-				Limits: corev1.ResourceList{
-					"memory": resource.MustParse(cr.Spec.Redisgraph_Resource.LimitMemory),
-				},
-				Requests: corev1.ResourceList{
-					"cpu":    resource.MustParse(cr.Spec.Redisgraph_Resource.RequestCPU),
-					"memory": resource.MustParse(cr.Spec.Redisgraph_Resource.RequestMemory),
-				},
-			},
-			TerminationMessagePolicy: "File",
-			TerminationMessagePath:   "/dev/termination-log",
-			ImagePullPolicy:          "Always",
-			SecurityContext: &corev1.SecurityContext{
-				Privileged:               &bool,
-				AllowPrivilegeEscalation: &bool,
-			},
-			VolumeMounts: []corev1.VolumeMount{
-				{
-					Name:      "redis-graph-certs",
-					MountPath: "/certs",
-				},
-				{
-					Name:      "stunnel-pid",
-					MountPath: "/rg",
-				},
-			},
-		},
-	}
-	sset.Spec.Template.Spec.Volumes = []corev1.Volume{
-		{
-			Name: "stunnel-pid",
-			VolumeSource: corev1.VolumeSource{
-				EmptyDir: &corev1.EmptyDirVolumeSource{},
-			},
-		},
-		{
-			Name: "redis-graph-certs",
-			VolumeSource: corev1.VolumeSource{
-				Secret: &corev1.SecretVolumeSource{
-					SecretName: "search-redisgraph-certs",
-					Items: []corev1.KeyToPath{
-						{
-							Key:  "tls.crt",
-							Path: "server.crt",
-						},
-						{
-							Key:  "tls.key",
-							Path: "server.key",
-						},
-					},
-					DefaultMode: int32Ptr(420),
-				},
-			},
-		},
+		podSpec.SecurityContext = expectedPodSpec.SecurityContext
 	}
-
-	if (corev1.VolumeSource{}) != rdbVolumeSource {
-		rdbVolume := corev1.Volume{
-			Name:         "persist",
-			VolumeSource: rdbVolumeSource,
-		}
-		sset.Spec.Template.Spec.Volumes = append(sset.Spec.Template.Spec.Volumes, rdbVolume)
-		rdbVolumeMount := corev1.VolumeMount{
-			Name:      "persist",
-			MountPath: "/redis-data",
-		}
-		for i, container := range sset.Spec.Template.Spec.Containers {
-			if container.Name == "redisgraph" {
-				sset.Spec.Template.Spec.Containers[i].VolumeMounts =
-					append(sset.Spec.Template.Spec.Containers[i].VolumeMounts, rdbVolumeMount)
-				log.Info("Added rdbVolumeMount in container: ", container.Name, rdbVolumeMount.MountPath)
-			}
-		}
-	}
-	if cr.Spec.NodeSelector != nil {
-		sset.Spec.Template.Spec.NodeSelector = cr.Spec.NodeSelector
-		log.Info("Added Node Selector")
+	podSpec.Containers = expectedPodSpec.Containers
+	r.pinContainerImage(podSpec)
+	r.holdContainerImage(podSpec)
+	podSpec.Volumes = expectedPodSpec.Volumes
+	if expectedPodSpec.NodeSelector != nil {
+		podSpec.NodeSelector = expectedPodSpec.NodeSelector
+		log.V(1).Info("Added Node Selector")
 	}
 	if err := ctrl.SetControllerReference(cr, sset, r.Scheme); err != nil {
-		log.Info("Cannot set statefulSet OwnerReference", err.Error())
+		log.Error(err, "Cannot set statefulSet OwnerReference")
 	}
 	return sset
 }
-func updateCRs(kclient client.Client, operatorCR *searchv1alpha1.SearchOperator, status string,
-	customizationCR *searchv1alpha1.SearchCustomization, persistence bool, storageClass string,
-	storageSize string, customValuesInuse bool) error {
+func (r *reconcileRequest) updateCRs(ctx context.Context, kclient client.Client,
+	operatorCR *searchv1alpha1.SearchOperator, status string, customizationCR *searchv1alpha1.SearchCustomization,
+	persistence bool, storageClass string, storageSize string, customValuesInuse bool) error {
 	var err error
-	err = updateOperatorCR(kclient, operatorCR, status)
+	err = r.updateOperatorCR(ctx, kclient, operatorCR, status)
 	if err != nil {
 		return err
 	}
 	if customValuesInuse {
-		err = updateCustomizationCR(kclient, customizationCR, persistence, storageClass, storageSize)
+		err = r.updateCustomizationCR(ctx, kclient, customizationCR, persistence, storageClass, storageSize)
 		if err != nil {
 			return err
 		}
 	}
-	log.Info("Updated status in CRs successfully.")
+	logf.FromContext(ctx).Info("Updated status in CRs successfully", "phase", status)
 	return nil
 }
 
-func fetchSrchOperator(kclient client.Client, cr *searchv1alpha1.SearchOperator) (
+func fetchSrchOperator(ctx context.Context, kclient client.Client, cr *searchv1alpha1.SearchOperator) (
 	*searchv1alpha1.SearchOperator, error) {
 	found := &searchv1alpha1.SearchOperator{}
-	err := kclient.Get(context.TODO(), types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}, found)
+	err := kclient.Get(ctx, types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}, found)
 	return found, err
 }
-func fetchSrchCustomization(kclient client.Client, cr *searchv1alpha1.SearchCustomization) (
+func fetchSrchCustomization(ctx context.Context, kclient client.Client, cr *searchv1alpha1.SearchCustomization) (
 	*searchv1alpha1.SearchCustomization, error) {
 	found := &searchv1alpha1.SearchCustomization{}
-	err := kclient.Get(context.TODO(), types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}, found)
+	err := kclient.Get(ctx, types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}, found)
 	return found, err
 }
-func updateOperatorCR(kclient client.Client, cr *searchv1alpha1.SearchOperator, status string) error {
-	cr, err := fetchSrchOperator(kclient, cr)
+func (r *reconcileRequest) updateOperatorCR(ctx context.Context, kclient client.Client,
+	cr *searchv1alpha1.SearchOperator, status string) error {
+	log := logf.FromContext(ctx)
+	// The generation the reconcile applied, the fetched SearchOperator may be newer
+	generation := cr.Generation
+	cr, err := fetchSrchOperator(ctx, kclient, cr)
 	if err != nil {
-		log.Error(err, fmt.Sprintf("Failed to get SearchOperator %s/%s ", cr.Namespace, cr.Name))
+		log.Error(err, "Failed to get SearchOperator", "name", cr.Name)
 		return err
 	}
 	cr.Status.PersistenceStatus = status
-	if deployVarPresent && deployVarErr == nil {
-		cr.Status.DeployRedisgraph = &deploy
-	}
-	err = kclient.Status().Update(context.TODO(), cr)
+	cr.Status.PodFailureReason = r.redisPodFailure.String()
+	if r.redisHealth != nil {
+		cr.Status.RedisHealth = r.redisHealth
+	}
+	setAvailableCondition(cr, status)
+	setPausedCondition(cr)
+	if r.bindingPublished {
+		cr.Status.Binding = &searchv1alpha1.BindingReference{Name: r.names.ConnectionSecret}
+	} else if !isPaused(cr) {
+		cr.Status.Binding = nil
+	}
+	if !isPaused(cr) {
+		cr.Status.Dependents = r.dependentsStatus
+	}
+	cr.Status.DeployRedisgraph = r.deployedStatus()
+	cr.Status.Plan = nil
+	// While paused the spec isn't applied, only what's observed is reported
+	if isPaused(cr) {
+		generation = cr.Status.ObservedGeneration
+	}
+	r.setObservedStatus(ctx, kclient, cr, generation)
+	if !isPaused(cr) {
+		r.setImageStatus(cr)
+		r.setUpgradeStatus(cr)
+		r.setACLStatus(cr)
+	}
+	err = kclient.Status().Update(ctx, cr)
 	if err != nil {
 		if errors.IsConflict(err) {
-			log.Info("Failed to update status Object has been modified")
+			log.Info("Failed to update status Object has been modified", "name", cr.Name)
 		}
-		log.Info(fmt.Sprintf("Failed to update %s/%s status. Error: %s", cr.Namespace, cr.Name, err.Error()))
+		log.Error(err, "Failed to update SearchOperator status", "name", cr.Name)
 		return err
 	} else {
-		log.Info(fmt.Sprintf("Updated CR status with persistence %s  ", cr.Status.PersistenceStatus))
+		log.Info("Updated SearchOperator status", "name", cr.Name, "phase", cr.Status.PersistenceStatus)
 	}
 	return nil
 }
 
-func updateCustomizationCR(kclient client.Client, cr *searchv1alpha1.SearchCustomization,
-	persistence bool, storageClass string, storageSize string) error {
-	cr, err := fetchSrchCustomization(kclient, cr)
+func (r *reconcileRequest) updateCustomizationCR(ctx context.Context, kclient client.Client,
+	cr *searchv1alpha1.SearchCustomization, persistence bool, storageClass string, storageSize string) error {
+	log := logf.FromContext(ctx)
+	generation := cr.Generation
+	cr, err := fetchSrchCustomization(ctx, kclient, cr)
 	if err != nil {
-		log.Error(err, fmt.Sprintf("Failed to get SearchCustomization %s/%s ", cr.Namespace, cr.Name))
+		log.Error(err, "Failed to get SearchCustomization", "name", cr.Name)
 		return err
 	}
 	cr.Status.Persistence = persistence
 	cr.Status.StorageClass = storageClass
 	cr.Status.StorageSize = storageSize
-	err = kclient.Status().Update(context.TODO(), cr)
+	cr.Status.PVCName = ""
+	if persistence {
+		cr.Status.PVCName = r.pvcName
+	}
+	cr.Status.ObservedGeneration = generation
+	r.setMatchedCondition(cr)
+	err = kclient.Status().Update(ctx, cr)
 	if err != nil {
 		if errors.IsConflict(err) {
-			log.Info("Failed to update status Object has been modified")
+			log.Info("Failed to update status Object has been modified", "name", cr.Name)
 		}
-		log.Info(fmt.Sprintf("Failed to update %s/%s status. Error:  %s", cr.Namespace, cr.Name, err.Error()))
+		log.Error(err, "Failed to update SearchCustomization status", "name", cr.Name)
 		return err
 	} else {
-		log.Info(fmt.Sprintf("Updated CR status with custom persistence %t ", cr.Status.Persistence))
+		log.Info("Updated SearchCustomization status", "name", cr.Name, "persistence", cr.Status.Persistence,
+			"storageClass", cr.Status.StorageClass)
 	}
 	return nil
 }
 
-func statefulSetNeedsUpdate(client client.Client, deployment *appv1.StatefulSet) bool {
+func (r *reconcileRequest) statefulSetNeedsUpdate(ctx context.Context, client client.Client,
+	deployment *appv1.StatefulSet) bool {
+	log := logf.FromContext(ctx)
 	found := &appv1.StatefulSet{}
-	err := client.Get(context.TODO(), types.NamespacedName{Name: statefulSetName, Namespace: namespace}, found)
+	err := client.Get(ctx, types.NamespacedName{Name: r.names.StatefulSet, Namespace: r.namespace}, found)
 	if err != nil {
 		return true
 	} else {
@@ -680,18 +697,20 @@ func statefulSetNeedsUpdate(client client.Client, deployment *appv1.StatefulSet)
 			log.Info("Volume source and/or metadata needs to be updated for redisgraph Statefulset")
 			return true
 		} else {
-			log.Info("No changes required for Statefulset")
+			log.V(1).Info("No changes required for Statefulset")
 			return false
 		}
 	}
 }
-func updateRedisStatefulSet(client client.Client, deployment *appv1.StatefulSet) {
+func (r *reconcileRequest) updateRedisStatefulSet(ctx context.Context, client client.Client,
+	deployment *appv1.StatefulSet) {
+	log := logf.FromContext(ctx, "statefulSet", deployment.Name)
 	found := &appv1.StatefulSet{}
-	err := client.Get(context.TODO(), types.NamespacedName{Name: statefulSetName, Namespace: namespace}, found)
+	err := client.Get(ctx, types.NamespacedName{Name: r.names.StatefulSet, Namespace: r.namespace}, found)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			log.Info("Statefulset not found. Creating Statefulset ...")
-			err = client.Create(context.TODO(), deployment)
+			err = client.Create(ctx, deployment)
 			if err != nil {
 				log.Error(err, "Failed to create Statefulset")
 				return
@@ -703,7 +722,7 @@ func updateRedisStatefulSet(client client.Client, deployment *appv1.StatefulSet)
 		return
 	} else {
 		deployment.ObjectMeta.ResourceVersion = found.ObjectMeta.ResourceVersion
-		err = client.Update(context.TODO(), deployment)
+		err = client.Update(ctx, deployment)
 		if err != nil {
 			log.Error(err, "Failed to update Statefulset")
 			return
@@ -713,93 +732,70 @@ func updateRedisStatefulSet(client client.Client, deployment *appv1.StatefulSet)
 	}
 
 }
-func deleteRedisStatefulSet(client client.Client) error {
+func (r *reconcileRequest) deleteRedisStatefulSet(ctx context.Context, client client.Client) error {
+	log := logf.FromContext(ctx)
 	statefulset := &appv1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      statefulSetName,
-			Namespace: namespace,
+			Name:      r.names.StatefulSet,
+			Namespace: r.namespace,
 		},
 	}
-	err := client.Delete(context.TODO(), statefulset)
+	err := client.Delete(ctx, statefulset)
 	if err != nil && !errors.IsNotFound(err) {
-		log.Error(err, "Failed to delete search redisgraph statefulset", "name", statefulSetName)
+		log.Error(err, "Failed to delete search redisgraph statefulset", "statefulSet", r.names.StatefulSet)
 		return err
 	}
 	time.Sleep(1 * time.Second) //Sleep for a minute to avoid quick update of statefulset
-	log.Info("StatefulSet deleted", "name", statefulSetName)
+	log.Info("StatefulSet deleted", "statefulSet", r.names.StatefulSet)
 	return nil
 }
 
-func deletePVC(client client.Client) error {
+func (r *reconcileRequest) deletePVC(ctx context.Context, client client.Client) error {
+	log := logf.FromContext(ctx)
 	pvc := &corev1.PersistentVolumeClaim{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      pvcName,
-			Namespace: namespace,
+			Name:      r.pvcName,
+			Namespace: r.namespace,
 		},
 	}
-	err := client.Delete(context.TODO(), pvc)
+	err := client.Delete(ctx, pvc)
 	if err != nil && !errors.IsNotFound(err) {
-		log.Error(err, "Failed to delete search redisgraph PVC", "name", pvcName)
+		log.Error(err, "Failed to delete search redisgraph PVC", "pvc", r.pvcName)
 		return err
 	}
 	time.Sleep(1 * time.Second) //Sleep for a minute to avoid quick update of statefulset
-	log.Info("PVC deleted", "name", pvcName)
+	log.Info("PVC deleted", "pvc", r.pvcName)
 	return nil
 }
 
-func getPVC() *corev1.PersistentVolumeClaim {
-	if storageClass != "" {
-		return &corev1.PersistentVolumeClaim{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      pvcName,
-				Namespace: namespace,
-			},
-			Spec: corev1.PersistentVolumeClaimSpec{
-				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
-				Resources: corev1.ResourceRequirements{
-					Requests: corev1.ResourceList{
-						corev1.ResourceName(corev1.ResourceStorage): resource.MustParse(storageSize),
-					},
-				},
-				StorageClassName: &storageClass,
-			},
-		}
-	}
-	return &corev1.PersistentVolumeClaim{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      pvcName,
-			Namespace: namespace,
-		},
-		Spec: corev1.PersistentVolumeClaimSpec{
-			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
-			Resources: corev1.ResourceRequirements{
-				Requests: corev1.ResourceList{
-					corev1.ResourceName(corev1.ResourceStorage): resource.MustParse(storageSize),
-				},
-			},
-		},
-	}
+func (r *reconcileRequest) getPVC() *corev1.PersistentVolumeClaim {
+	return render.PVC(r.namespace, render.Storage{
+		Persistence:  r.persistence,
+		AllowDegrade: r.allowdegrade,
+		StorageClass: r.storageClass,
+		StorageSize:  r.storageSize,
+		PVCName:      r.pvcName,
+	})
 }
 
-//Remove PVC if you have one
-func setupVolume(client client.Client) error {
+// Remove PVC if you have one
+func (r *reconcileRequest) setupVolume(ctx context.Context, client client.Client) error {
+	log := logf.FromContext(ctx, "pvc", r.pvcName, "storageClass", r.storageClass)
 	found := &corev1.PersistentVolumeClaim{}
-	pvc := getPVC()
-	err := client.Get(context.TODO(), types.NamespacedName{Name: pvcName, Namespace: namespace}, found)
-	logKeyPVCName := "PVC Name"
+	pvc := r.getPVC()
+	err := client.Get(ctx, types.NamespacedName{Name: r.pvcName, Namespace: r.namespace}, found)
 	if err != nil && errors.IsNotFound(err) {
-		err = client.Create(context.TODO(), pvc)
+		err = client.Create(ctx, pvc)
 		//Return True if sucessfully created pvc else return False
 		if err != nil {
-			log.Info("Error creating a new PVC ", logKeyPVCName, pvcName)
-			log.Info(err.Error())
+			log.Error(err, "Error creating a new PVC")
 			return err
 		} else {
-			log.Info("Created a new PVC ", logKeyPVCName, pvcName)
+			log.Info("Created a new PVC")
 			return nil
 		}
 	} else if err != nil {
-		log.Info("Error finding PVC ", logKeyPVCName, pvcName)
+		log.Error(err, "Error finding PVC")
 		//return False and error if there is Error
 		return err
 	}
@@ -807,86 +803,77 @@ func setupVolume(client client.Client) error {
 	return nil
 }
 
-func generatePass(length int) []byte {
-	chars := "ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
-		"abcdefghijklmnopqrstuvwxyz" +
-		"0123456789"
-
-	buf := make([]byte, length)
-	for i := 0; i < length; i++ {
-		nBig, _ := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
-		buf[i] = chars[nBig.Int64()]
+// newRedisSecret returns the redisgraph user secret of the cr in its namespace, with generated passwords
+func (r *reconcileRequest) newRedisSecret(cr *searchv1alpha1.SearchOperator) (*corev1.Secret, error) {
+	sec := render.Secret(cr, r.names)
+	sec.Namespace = r.namespace
+	password, err := generatePassword(cr)
+	if err != nil {
+		return nil, err
 	}
-	return buf
-}
-
-// newRedisSecret returns a redisgraph-user-secret with the same name/namespace as the cr
-func newRedisSecret(cr *searchv1alpha1.SearchOperator, scheme *runtime.Scheme) *corev1.Secret {
-	labels := map[string]string{
-		"app": "search",
+	sec.Data = map[string][]byte{
+		secretKeyPassword: password,
 	}
-
-	sec := &corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "redisgraph-user-secret",
-			Namespace: namespace,
-			Labels:    labels,
-		},
-		Data: map[string][]byte{
-			"redispwd": generatePass(16),
-		},
+	setGeneratedAt(sec)
+	if _, err = syncACLCredentials(cr, sec); err != nil {
+		return nil, err
 	}
-	if err := ctrl.SetControllerReference(cr, sec, scheme); err != nil {
-		log.Info("Cannot set secret OwnerReference", err.Error())
-	}
-	return sec
+	return sec, nil
 }
 
-func isStatefulSetAvailable(kclient client.Client) bool {
+func (r *reconcileRequest) isStatefulSetAvailable(ctx context.Context, kclient client.Client) bool {
 	//check if statefulset is present if not we can assume the pod is not running
 	found := &appv1.StatefulSet{}
-	err := kclient.Get(context.TODO(), types.NamespacedName{Name: statefulSetName, Namespace: namespace}, found)
+	err := kclient.Get(ctx, types.NamespacedName{Name: r.names.StatefulSet, Namespace: r.namespace}, found)
 	if err != nil && errors.IsNotFound(err) {
 		return false
 	}
 	return true
 }
 
-func (r *SearchOperatorReconciler) isPodRunning(withPVC bool, waitSeconds int) bool {
+func (r *reconcileRequest) isPodRunning(ctx context.Context, withPVC bool, waitSeconds int) bool {
+	log := logf.FromContext(ctx)
 	log.Info("Checking Redisgraph Pod Status...")
+	r.redisPodFailure = podFailure{}
 	//Keep checking status until waitSeconds
 	// We assume its not running
 	count := 0
+	var notReadyPod *corev1.Pod
 	for count < waitSeconds {
 		podList := &corev1.PodList{}
-		opts := []client.ListOption{client.MatchingLabels{"app": appName, "component": "redisgraph"}}
-		err := r.Client.List(context.TODO(), podList, opts...)
+		opts := []client.ListOption{client.InNamespace(r.namespace), r.redisgraphSelector()}
+		err := r.Client.List(ctx, podList, opts...)
 		if err != nil {
-			log.Info("Error listing redisgraph pods. ", err)
+			log.Error(err, "Error listing redisgraph pods")
 			return false
 		}
-		for _, item := range podList.Items {
-			if isReady(item, withPVC) {
+		for i, item := range podList.Items {
+			if r.isReady(ctx, item, withPVC) {
 				log.Info("Redisgraph Pod Running...")
 				return true
 			}
+			notReadyPod = &podList.Items[i]
 		}
 		count++
 		time.Sleep(1 * time.Second)
 		// Fetch the SearchCustomization instance
-		custom := &searchv1alpha1.SearchCustomization{}
-		err = r.Client.Get(context.TODO(), types.NamespacedName{Name: "searchcustomization", Namespace: namespace}, custom)
-		if err == nil && !reflect.DeepEqual(custom.Spec, startingSpec) {
+		custom, err := r.findCustomization(ctx, &searchv1alpha1.SearchOperator{
+			ObjectMeta: metav1.ObjectMeta{Name: r.names.Instance, Namespace: r.namespace}})
+		if err == nil && !reflect.DeepEqual(custom.Spec, r.startingSpec) {
 			log.Info("SearchCustomization Spec updated , Reconciling ..")
 			break
 		}
 
 	}
-	log.Info("Redisgraph Pod not Running...")
+	if notReadyPod != nil {
+		r.redisPodFailure = r.diagnosePod(ctx, *notReadyPod)
+	}
+	log.Info("Redisgraph Pod not Running...", "reason", r.redisPodFailure.String())
 	return false
 }
 
-func isReady(pod corev1.Pod, withPVC bool) bool {
+func (r *reconcileRequest) isReady(ctx context.Context, pod corev1.Pod, withPVC bool) bool {
+	log := logf.FromContext(ctx)
 	for _, status := range pod.Status.Conditions {
 		if status.Reason == "Unschedulable" {
 			log.Info("RedisGraph Pod UnScheduleable - likely PVC mount problem")
@@ -905,8 +892,8 @@ func isReady(pod corev1.Pod, withPVC bool) bool {
 				if name.Name != "persist" {
 					continue
 				}
-				if withPVC && name.PersistentVolumeClaim != nil && name.PersistentVolumeClaim.ClaimName == pvcName {
-					log.Info("RedisGraph Pod with PVC Running")
+				if withPVC && name.PersistentVolumeClaim != nil && name.PersistentVolumeClaim.ClaimName == r.pvcName {
+					log.Info("RedisGraph Pod with PVC Running", "pvc", r.pvcName)
 					return true
 				} else if !withPVC && name.PersistentVolumeClaim == nil {
 					log.Info("RedisGraph Pod with EmptyDir Running")
@@ -918,7 +905,7 @@ func isReady(pod corev1.Pod, withPVC bool) bool {
 	return false
 }
 
-func (r *SearchOperatorReconciler) expectedStatefulSet(client client.Client,
+func (r *reconcileRequest) expectedStatefulSet(ctx context.Context, client client.Client,
 	cr *searchv1alpha1.SearchOperator, usePVC bool, saverdb bool) *appv1.StatefulSet {
 	var statefulSet *appv1.StatefulSet
 	emptyDirVolume := corev1.VolumeSource{
@@ -926,40 +913,44 @@ func (r *SearchOperatorReconciler) expectedStatefulSet(client client.Client,
 	}
 	pvcVolume := corev1.VolumeSource{
 		PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-			ClaimName: pvcName,
+			ClaimName: r.pvcName,
 		},
 	}
 	if saverdb {
 		if !usePVC {
-			statefulSet = r.getStatefulSet(cr, emptyDirVolume, "true")
+			statefulSet = r.getStatefulSet(ctx, cr, emptyDirVolume, "true")
 		} else {
-			statefulSet = r.getStatefulSet(cr, pvcVolume, "true")
+			statefulSet = r.getStatefulSet(ctx, cr, pvcVolume, "true")
 		}
 	} else {
-		statefulSet = r.getStatefulSet(cr, corev1.VolumeSource{}, "false")
+		statefulSet = r.getStatefulSet(ctx, cr, corev1.VolumeSource{}, "false")
 	}
 	return statefulSet
 }
 
-func (r *SearchOperatorReconciler) executeDeployment(client client.Client,
+func (r *reconcileRequest) executeDeployment(ctx context.Context, client client.Client,
 	cr *searchv1alpha1.SearchOperator, usePVC bool, saverdb bool) *appv1.StatefulSet {
-	statefulSet := r.expectedStatefulSet(client, cr, usePVC, saverdb)
-	if statefulSetNeedsUpdate(client, statefulSet) {
-		updateRedisStatefulSet(client, statefulSet)
+	statefulSet := r.expectedStatefulSet(ctx, client, cr, usePVC, saverdb)
+	if r.statefulSetNeedsUpdate(ctx, client, statefulSet) {
+		r.updateRedisStatefulSet(ctx, client, statefulSet)
 	}
-	log.Info("No updates required for Statefulset")
 	return statefulSet
 }
 
-func (r *SearchOperatorReconciler) setupSecret(client client.Client, cr *searchv1alpha1.SearchOperator) error {
-	// Define a new Secret object
-	secret := newRedisSecret(cr, r.Scheme)
+func (r *reconcileRequest) setupSecret(ctx context.Context, client client.Client,
+	cr *searchv1alpha1.SearchOperator) error {
+	log := logf.FromContext(ctx)
 	// Check if this Secret already exists
 	found := &corev1.Secret{}
-	err := client.Get(context.TODO(), types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}, found)
+	err := client.Get(ctx, types.NamespacedName{Name: r.names.RedisSecret, Namespace: r.namespace}, found)
 	if err != nil && errors.IsNotFound(err) {
-		log.Info("Creating a new Secret", "Secret.Namespace", secret.Namespace, "Secret.Name", secret.Name)
-		err = client.Create(context.TODO(), secret)
+		// Define a new Secret object
+		secret, err := r.newRedisSecret(cr)
+		if err != nil {
+			return err
+		}
+		log.Info("Creating a new Secret", "secret", secret.Name)
+		err = client.Create(ctx, secret)
 		if err != nil {
 			return err
 		}
@@ -967,20 +958,16 @@ func (r *SearchOperatorReconciler) setupSecret(client client.Client, cr *searchv
 		return nil
 	} else if err != nil {
 		return err
-	} else {
-		// Secret already exists - don't requeue
-		log.Info("Skip reconcile: Secret already exists", "Secret.Namespace", found.Namespace, "Secret.Name", found.Name)
 	}
-	return nil
-}
-
-func getOptions(opts map[string]string) []client.ListOption {
-	listOptions := []client.ListOption{}
-	listOption := client.ListOptions{
-		LabelSelector: labels.SelectorFromSet(opts),
-		Namespace:     namespace,
-		Limit:         2, //setting this to 2 as a safety net while deleting pods
+	// The password of the default user is kept, the credentials of the ACL user follow the password policy
+	changed, err := syncACLCredentials(cr, found)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		log.V(1).Info("Skip reconcile: Secret already exists", "secret", found.Name)
+		return nil
 	}
-	listOptions = append(listOptions, &listOption)
-	return listOptions
+	log.Info("Updating the ACL user credentials of the Secret", "secret", found.Name, "aclUser", aclUserName(cr))
+	return client.Update(ctx, found)
 }
@@ -9,7 +9,10 @@ import (
 	"strconv"
 	"testing"
 
+	"github.com/go-logr/logr/funcr"
 	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	searchv1beta1 "github.com/stolostron/search-operator/api/v1beta1"
+	"github.com/stolostron/search-operator/render"
 	"github.com/stretchr/testify/assert"
 	appv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -23,6 +26,16 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
+// testNamespace is the namespace of the SearchOperator the tests reconcile
+const testNamespace = "test-cluster"
+
+// testNames are the names of the objects of the SearchOperator the tests reconcile
+var testNames = render.NamesFor(defaultInstanceName)
+
+// testRequest is the reconcile request of the SearchOperator the tests reconcile
+var testRequest = reconcile.Request{NamespacedName: types.NamespacedName{Name: defaultInstanceName,
+	Namespace: testNamespace}}
+
 type testSetup struct {
 	scheme                *runtime.Scheme
 	request               reconcile.Request
@@ -41,8 +54,8 @@ type testSetup struct {
 func commonSetup() testSetup {
 	testScheme := scheme.Scheme
 
-	namespace = "test-cluster"
 	searchv1alpha1.AddToScheme(testScheme)
+	searchv1beta1.AddToScheme(testScheme)
 	testScheme.AddKnownTypes(corev1.SchemeGroupVersion, &corev1.Secret{})
 	waitSecondsForPodChk = 2
 	redisPodResource := searchv1alpha1.PodResource{
@@ -58,30 +71,31 @@ func commonSetup() testSetup {
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "searchoperator",
-			Namespace: namespace,
+			Namespace: testNamespace,
 		},
 		Spec: searchv1alpha1.SearchOperatorSpec{
 			Redisgraph_Resource: redisPodResource,
 		},
 	}
-	testSecret := newRedisSecret(testSearchOperator, testScheme)
 	req := reconcile.Request{
 		NamespacedName: types.NamespacedName{
 			Name:      "searchoperator",
-			Namespace: namespace,
+			Namespace: testNamespace,
 		},
 	}
 	client := fake.NewFakeClientWithScheme(testScheme)
-	testSearchOperatorReconciler := SearchOperatorReconciler{client, log, testScheme}
+	testSearchOperatorReconciler := SearchOperatorReconciler{Client: client, Log: log, Scheme: testScheme}
+	request := testSearchOperatorReconciler.newRequest(req)
+	testSecret, _ := request.newRedisSecret(testSearchOperator)
 
-	testStatefulsetWithPVC := testSearchOperatorReconciler.executeDeployment(client, testSearchOperator, true, true)
-	testStatefulsetWithOutPVC := testSearchOperatorReconciler.executeDeployment(client, testSearchOperator, false, true)
+	testStatefulsetWithPVC := request.executeDeployment(context.TODO(), client, testSearchOperator, true, true)
+	testStatefulsetWithOutPVC := request.executeDeployment(context.TODO(), client, testSearchOperator, false, true)
 	// Set PVC Size to 10Gi
 	fakePVC := createFakeNamedPVC("10Gi", testSearchOperator.Namespace, nil)
-	fakePodWithPVC := createFakeRedisGraphPod(namespace, true, true)
-	fakePodWithOutPVC := createFakeRedisGraphPod(namespace, false, true)
-	fakeUnschedulablePod := createFakeRedisGraphPod(namespace, false, false)
-	fakeSearchCustCR := createFakeSearchCustomizationCR(namespace, false)
+	fakePodWithPVC := createFakeRedisGraphPod(testNamespace, true, true)
+	fakePodWithOutPVC := createFakeRedisGraphPod(testNamespace, false, true)
+	fakeUnschedulablePod := createFakeRedisGraphPod(testNamespace, false, false)
+	fakeSearchCustCR := createFakeSearchCustomizationCR(testNamespace, false)
 	testSetup := testSetup{scheme: testScheme,
 		request:               req,
 		srchOperator:          testSearchOperator,
@@ -101,7 +115,7 @@ func Test_searchOperatorNotFound(t *testing.T) {
 	testSetup := commonSetup()
 	req := testSetup.request
 	client := fake.NewFakeClientWithScheme(testSetup.scheme)
-	nilSearchOperator := SearchOperatorReconciler{client, log, testSetup.scheme}
+	nilSearchOperator := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
 
 	_, err := nilSearchOperator.Reconcile(testSetup.context, req)
 	assert.Nil(t, err, "Expected Reconcile Error to be Nil. Got error: %v", err)
@@ -115,7 +129,7 @@ func Test_secretCreatedWithOwnerRef(t *testing.T) {
 	testSetup := commonSetup()
 	testSecret := testSetup.secret
 	client := fake.NewFakeClientWithScheme(testSetup.scheme, testSetup.srchOperator)
-	nilSearchOperator := SearchOperatorReconciler{client, log, testSetup.scheme}
+	nilSearchOperator := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
 
 	_, err := nilSearchOperator.Reconcile(testSetup.context, testSetup.request)
 
@@ -141,7 +155,7 @@ func Test_secretAlreadyExists(t *testing.T) {
 	testSecret := testSetup.secret
 
 	client := fake.NewFakeClientWithScheme(testSetup.scheme, testSetup.srchOperator, testSecret)
-	nilSearchOperator := SearchOperatorReconciler{client, log, testSetup.scheme}
+	nilSearchOperator := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
 
 	_, err := nilSearchOperator.Reconcile(testSetup.context, testSetup.request)
 
@@ -158,7 +172,7 @@ func Test_EmptyDirStatefulsetCreatedWithOwnerRef(t *testing.T) {
 	testSetup := commonSetup()
 
 	client := fake.NewFakeClientWithScheme(testSetup.scheme, testSetup.srchOperator, testSetup.secret, testSetup.podWithOutPVC)
-	nilSearchOperator := SearchOperatorReconciler{client, log, testSetup.scheme}
+	nilSearchOperator := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
 	var err error
 
 	_, err = nilSearchOperator.Reconcile(testSetup.context, testSetup.request)
@@ -185,7 +199,7 @@ func Test_StatefulsetWithNoPersistenceStatus(t *testing.T) {
 	testSetup := commonSetup()
 
 	client := fake.NewFakeClientWithScheme(testSetup.scheme, testSetup.srchOperator, testSetup.secret, testSetup.podWithOutPVC, testSetup.customizationCR)
-	nilSearchOperator := SearchOperatorReconciler{client, log, testSetup.scheme}
+	nilSearchOperator := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
 	var err error
 	instance := &searchv1alpha1.SearchOperator{}
 	//Turn persistence to false in customizationCR
@@ -208,7 +222,7 @@ func Test_StatefulsetWithPVC(t *testing.T) {
 	testStatefulset := testSetup.statefulsetWithPVC
 
 	client := fake.NewFakeClientWithScheme(testSetup.scheme, testSetup.srchOperator, testSetup.secret, testSetup.pvc, testSetup.podWithPVC)
-	nilSearchOperator := SearchOperatorReconciler{client, log, testSetup.scheme}
+	nilSearchOperator := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
 	var err error
 
 	instance := &searchv1alpha1.SearchOperator{}
@@ -244,7 +258,7 @@ func Test_FailSettingupWithPVC(t *testing.T) {
 
 	//TODO: Passing already existing secret doesn't set ownerRef - testSecret
 	client := fake.NewFakeClientWithScheme(testSetup.scheme, testSetup.srchOperator, testSetup.secret, testSetup.unSchedulablePod)
-	nilSearchOperator := SearchOperatorReconciler{client, log, testSetup.scheme}
+	nilSearchOperator := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
 	var err error
 
 	instance := &searchv1alpha1.SearchOperator{}
@@ -276,7 +290,7 @@ func Test_UnschedulablePodWithPersistence(t *testing.T) {
 	testStatefulset := testSetup.statefulsetWithOutPVC
 
 	client := fake.NewFakeClientWithScheme(testSetup.scheme, testSetup.srchOperator, testSetup.secret, testStatefulset, testSetup.unSchedulablePod)
-	nilSearchOperator := SearchOperatorReconciler{client, log, testSetup.scheme}
+	nilSearchOperator := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
 	var err error
 
 	instance := &searchv1alpha1.SearchOperator{}
@@ -303,7 +317,7 @@ func Test_UnschedulablePodWithOutPersistence(t *testing.T) {
 	testStatefulset := testSetup.statefulsetWithOutPVC
 
 	client := fake.NewFakeClientWithScheme(testSetup.scheme, testSetup.srchOperator, testSetup.customizationCR, testSetup.secret, testStatefulset, testSetup.unSchedulablePod)
-	nilSearchOperator := SearchOperatorReconciler{client, log, testSetup.scheme}
+	nilSearchOperator := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
 	var err error
 
 	instance := &searchv1alpha1.SearchOperator{}
@@ -333,7 +347,7 @@ func Test_DoNotDeployRedisPod(t *testing.T) {
 
 	client := fake.NewFakeClientWithScheme(testSetup.scheme, testSetup.srchOperator, testSetup.secret, testSetup.pvc, testSetup.statefulsetWithPVC)
 
-	nilSearchOperator := SearchOperatorReconciler{client, log, testSetup.scheme}
+	nilSearchOperator := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
 	var err error
 	instance := &searchv1alpha1.SearchOperator{}
 	err = client.Get(context.TODO(), req.NamespacedName, instance)
@@ -362,7 +376,7 @@ func Test_UnschedulablePodWithDisAllowDegradedMode(t *testing.T) {
 	testStatefulset := testSetup.statefulsetWithOutPVC
 
 	client := fake.NewFakeClientWithScheme(testSetup.scheme, testSetup.srchOperator, testSetup.customizationCR, testSetup.secret, testStatefulset, testSetup.unSchedulablePod)
-	nilSearchOperator := SearchOperatorReconciler{client, log, testSetup.scheme}
+	nilSearchOperator := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
 	var err error
 
 	instance := &searchv1alpha1.SearchOperator{}
@@ -384,51 +398,86 @@ func Test_UnschedulablePodWithDisAllowDegradedMode(t *testing.T) {
 func TestUpdateCR(t *testing.T) {
 	testSetup := commonSetup()
 	client := fake.NewFakeClientWithScheme(testSetup.scheme)
+	request := (&SearchOperatorReconciler{}).newRequest(testSetup.request)
 	var err error
-	err = updateCRs(client, testSetup.srchOperator, "status", testSetup.customizationCR, false, "", "10G", true)
+	err = request.updateCRs(context.TODO(), client, testSetup.srchOperator, "status", testSetup.customizationCR, false, "", "10G", true)
 	assert.True(t, errors.IsNotFound(err), "Expected searchOperator Not Found error. Got %v", err.Error())
 
 	client = fake.NewFakeClientWithScheme(testSetup.scheme, testSetup.srchOperator)
-	err = updateCRs(client, testSetup.srchOperator, "status", testSetup.customizationCR, false, "", "10G", true)
+	err = request.updateCRs(context.TODO(), client, testSetup.srchOperator, "status", testSetup.customizationCR, false, "", "10G", true)
 	assert.True(t, errors.IsNotFound(err), "Expected customizationCR Not Found error. Got %v", err.Error())
 
 	client = fake.NewFakeClientWithScheme(testSetup.scheme, testSetup.srchOperator, testSetup.customizationCR)
-	err = updateCRs(client, testSetup.srchOperator, "status", testSetup.customizationCR, false, "", "10G", true)
+	err = request.updateCRs(context.TODO(), client, testSetup.srchOperator, "status", testSetup.customizationCR, false, "", "10G", true)
 	assert.Nil(t, err, "Expected CR statuses to be updated successfully. Got error: %v", err)
 }
 
 func TestGetPVC(t *testing.T) {
-	storageClass = "test"
-	pvc := getPVC()
+	testSetup := commonSetup()
+	request := (&SearchOperatorReconciler{}).newRequest(testSetup.request)
+	request.storageClass = "test"
+	pvc := request.getPVC()
 	assert.NotNil(t, pvc.Spec.StorageClassName, "Expected StorageClassName to be not nil.")
 	assert.Equal(t, "test", *pvc.Spec.StorageClassName, "Expected StorageClassName not found. Got %v", *pvc.Spec.StorageClassName)
 
-	storageClass = ""
-	pvc = getPVC()
+	request.storageClass = ""
+	pvc = request.getPVC()
 	assert.Nil(t, pvc.Spec.StorageClassName, "Expected empty StorageClassName. Got: %s", pvc.Spec.StorageClassName)
 }
 
 func TestRestartCollector(t *testing.T) {
-	//create fake Collector Pod
-	labels := map[string]string{}
-	labels["app"] = "search-prod"
-	labels["component"] = "search-collector"
 	testSetup := commonSetup()
-	collectorPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "search-collector-pod", Labels: labels}}
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, testSetup.srchOperator, collectorDeployment())
+	nilSearchOperator := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
+
+	nilSearchOperator.newRequest(testSetup.request).restartSearchComponents(context.TODO(), testSetup.srchOperator)
+	collector := &appv1.Deployment{}
+	err := client.Get(context.TODO(), types.NamespacedName{Name: "search-collector", Namespace: testNamespace}, collector)
+	assert.Nil(t, err, "Expected SearchCollector deployment to be kept. Got error: %v", err)
+	assert.Contains(t, collector.Spec.Template.Annotations, annotationRestartedAt,
+		"Expected SearchCollector pod template to be annotated for a rolling restart.")
+}
 
-	client := fake.NewFakeClientWithScheme(testSetup.scheme, testSetup.srchOperator, collectorPod)
-	nilSearchOperator := SearchOperatorReconciler{client, log, testSetup.scheme}
+func TestReconcileLogsCarryReconcileID(t *testing.T) {
+	testSetup := commonSetup()
+	lines := []string{}
+	testLog := funcr.New(func(prefix, args string) { lines = append(lines, args) }, funcr.Options{})
 
-	nilSearchOperator.restartSearchComponents()
-	req := reconcile.Request{
-		NamespacedName: types.NamespacedName{
-			Namespace: namespace,
-			Name:      "search-collector-pod",
-		},
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, testSetup.srchOperator, testSetup.secret, testSetup.pvc, testSetup.podWithPVC)
+	nilSearchOperator := SearchOperatorReconciler{Client: client, Log: testLog, Scheme: testSetup.scheme}
+	_, err := nilSearchOperator.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected search Operator reconcile to complete successfully. Got error: %v", err)
+
+	assert.NotEmpty(t, lines, "Expected reconcile to write logs.")
+	for _, line := range lines {
+		assert.Contains(t, line, `"reconcileID"=`, "Expected every log line to carry the reconcile ID.")
+		assert.Contains(t, line, `"namespace"="`+testNamespace+`"`, "Expected every log line to carry the namespace.")
 	}
-	collectorPod1 := &corev1.Pod{}
-	err := client.Get(context.TODO(), req.NamespacedName, collectorPod1)
-	assert.True(t, errors.IsNotFound(err), "Expected error: SearchCollector pod to be Not Found. Got %v", err.Error())
+}
+
+func Test_StatefulsetSecurityProfile(t *testing.T) {
+	testSetup := commonSetup()
+	instance := testSetup.srchOperator.DeepCopy()
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, instance)
+	reconciler := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
+	request := reconciler.newRequest(testSetup.request)
+	request.executeDeployment(context.TODO(), client, instance, true, true)
+
+	instance.Spec.PodSecurity = &searchv1alpha1.PodSecurity{Profile: render.SecurityProfileOpenShift}
+	expected := request.expectedStatefulSet(context.TODO(), client, instance, true, true)
+	assert.True(t, request.statefulSetNeedsUpdate(context.TODO(), client, expected),
+		"Expected the StatefulSet to be updated when the security profile changes.")
+	request.executeDeployment(context.TODO(), client, instance, true, true)
+
+	found := &appv1.StatefulSet{}
+	err := client.Get(context.TODO(), types.NamespacedName{Name: testNames.StatefulSet, Namespace: testNamespace}, found)
+	assert.Nil(t, err, "Expected statefulset to be found. Got error: %v", err)
+	podSecurity := found.Spec.Template.Spec.SecurityContext
+	assert.Nil(t, podSecurity.RunAsUser, "Expected no fixed user with the OpenShift profile.")
+	assert.Nil(t, podSecurity.FSGroup, "Expected no fixed group with the OpenShift profile.")
+	assert.True(t, *podSecurity.RunAsNonRoot)
+	assert.Equal(t, corev1.SeccompProfileTypeRuntimeDefault, podSecurity.SeccompProfile.Type)
+	assert.True(t, *found.Spec.Template.Spec.Containers[0].SecurityContext.ReadOnlyRootFilesystem)
 }
 
 func createFakeNamedPVC(requestBytes string, namespace string, userAnnotations map[string]string) *corev1.PersistentVolumeClaim {
@@ -440,7 +489,7 @@ func createFakeNamedPVC(requestBytes string, namespace string, userAnnotations m
 	return &corev1.PersistentVolumeClaim{
 		ObjectMeta: metav1.ObjectMeta{
 			UID:         "testid",
-			Name:        pvcName,
+			Name:        testNames.DefaultPVC,
 			Namespace:   namespace,
 			Annotations: annotations,
 		},
@@ -473,7 +522,7 @@ func createFakeRedisGraphPod(namespace string, persistence, schedulable bool) *c
 	}
 	// unschedulableStatus := corev1.PodStatus{ContainerStatuses: containerStatuses}
 
-	persistentVolSource := corev1.PersistentVolumeClaimVolumeSource{ClaimName: pvcName}
+	persistentVolSource := corev1.PersistentVolumeClaimVolumeSource{ClaimName: testNames.DefaultPVC}
 	emptyDirVolSource := corev1.EmptyDirVolumeSource{}
 	var volSource corev1.VolumeSource
 
@@ -0,0 +1,81 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stolostron/search-operator/render"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// serviceWanted is true if the operator deploys redisgraph for the instance.
+func (r *reconcileRequest) serviceWanted(cr *searchv1alpha1.SearchOperator) bool {
+	return r.deployEnabled && externalDatabase(cr) == nil
+}
+
+// expectedService returns the Service in front of the redisgraph pods of the instance.
+func (r *reconcileRequest) expectedService(cr *searchv1alpha1.SearchOperator) *corev1.Service {
+	service := render.Service(cr, r.names)
+	service.Namespace = r.namespace
+	return service
+}
+
+// serviceNeedsUpdate is true if the found Service differs from the expected one in what the operator owns.
+// The cluster IP and the other fields defaulted by the cluster are kept.
+func serviceNeedsUpdate(found, expected *corev1.Service) bool {
+	return !reflect.DeepEqual(found.Spec.Selector, expected.Spec.Selector) ||
+		!reflect.DeepEqual(found.Spec.Ports, expected.Spec.Ports) ||
+		!reflect.DeepEqual(found.Labels, expected.Labels) ||
+		!reflect.DeepEqual(found.OwnerReferences, expected.OwnerReferences)
+}
+
+// reconcileService creates or updates the Service the connection secret points to when the operator deploys
+// redisgraph. Otherwise the Service is deleted if the SearchOperator owns it.
+func (r *reconcileRequest) reconcileService(ctx context.Context, cr *searchv1alpha1.SearchOperator) error {
+	log := logf.FromContext(ctx, "service", r.names.StatefulSet)
+	found := &corev1.Service{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: r.names.StatefulSet, Namespace: r.namespace}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+	if !r.serviceWanted(cr) {
+		if !exists || !metav1.IsControlledBy(found, cr) {
+			return nil
+		}
+		if err = r.Client.Delete(ctx, found); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		log.Info("Service deleted")
+		return nil
+	}
+	expected := r.expectedService(cr)
+	if !exists {
+		if err = r.Client.Create(ctx, expected); err != nil {
+			return err
+		}
+		log.Info("Service created")
+		return nil
+	}
+	if !serviceNeedsUpdate(found, expected) {
+		log.V(1).Info("No changes required for Service")
+		return nil
+	}
+	found.Labels = expected.Labels
+	found.OwnerReferences = expected.OwnerReferences
+	found.Spec.Selector = expected.Spec.Selector
+	found.Spec.Ports = expected.Spec.Ports
+	if err = r.Client.Update(ctx, found); err != nil {
+		return err
+	}
+	log.Info("Service updated")
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stolostron/search-operator/render"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// expectedServiceAccount returns the ServiceAccount of the redisgraph pods of the instance.
+func (r *reconcileRequest) expectedServiceAccount(cr *searchv1alpha1.SearchOperator) *corev1.ServiceAccount {
+	account := render.ServiceAccount(cr, r.names)
+	account.Namespace = r.namespace
+	return account
+}
+
+// serviceAccountNeedsUpdate is true if the found ServiceAccount differs from the expected one in what the operator
+// owns. The secrets and image pull secrets added by the cluster are kept.
+func serviceAccountNeedsUpdate(found, expected *corev1.ServiceAccount) bool {
+	return !reflect.DeepEqual(found.AutomountServiceAccountToken, expected.AutomountServiceAccountToken) ||
+		!reflect.DeepEqual(found.Labels, expected.Labels) ||
+		!reflect.DeepEqual(found.OwnerReferences, expected.OwnerReferences)
+}
+
+// setupServiceAccount creates the ServiceAccount the redisgraph pods run as, and restores it if it was changed.
+// No role is bound to it, redisgraph doesn't call the API.
+func (r *reconcileRequest) setupServiceAccount(ctx context.Context, cr *searchv1alpha1.SearchOperator) error {
+	log := logf.FromContext(ctx, "serviceAccount", r.names.ServiceAccount)
+	expected := r.expectedServiceAccount(cr)
+	found := &corev1.ServiceAccount{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: r.names.ServiceAccount, Namespace: r.namespace}, found)
+	if errors.IsNotFound(err) {
+		if err = r.Client.Create(ctx, expected); err != nil {
+			return err
+		}
+		log.Info("ServiceAccount created")
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !serviceAccountNeedsUpdate(found, expected) {
+		log.V(1).Info("No changes required for ServiceAccount")
+		return nil
+	}
+	found.Labels = expected.Labels
+	found.OwnerReferences = expected.OwnerReferences
+	found.AutomountServiceAccountToken = expected.AutomountServiceAccountToken
+	if err = r.Client.Update(ctx, found); err != nil {
+		return err
+	}
+	log.Info("ServiceAccount updated")
+	return nil
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSetupServiceAccount(t *testing.T) {
+	testSetup := commonSetup()
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, testSetup.srchOperator, testSetup.secret, testSetup.pvc,
+		testSetup.podWithPVC)
+	reconciler := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
+	request := reconciler.newRequest(testSetup.request)
+
+	_, err := reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected reconcile to succeed. Got error: %v", err)
+	account := &corev1.ServiceAccount{}
+	key := types.NamespacedName{Name: testNames.ServiceAccount, Namespace: testNamespace}
+	err = client.Get(context.TODO(), key, account)
+	assert.Nil(t, err, "Expected the ServiceAccount to be created. Got error: %v", err)
+	assert.False(t, *account.AutomountServiceAccountToken, "Expected the token not to be mounted.")
+	assert.Equal(t, testSetup.srchOperator.Name, account.OwnerReferences[0].Name,
+		"Expected the SearchOperator to own the ServiceAccount.")
+	sset := &appv1.StatefulSet{}
+	err = client.Get(context.TODO(), types.NamespacedName{Name: testNames.StatefulSet, Namespace: testNamespace}, sset)
+	assert.Nil(t, err, "Expected the statefulset to be created. Got error: %v", err)
+	assert.Equal(t, testNames.ServiceAccount, sset.Spec.Template.Spec.ServiceAccountName,
+		"Expected redisgraph to run as its own ServiceAccount.")
+	assert.False(t, *sset.Spec.Template.Spec.AutomountServiceAccountToken)
+
+	// Mounting the token again is reverted, the secrets added by the cluster are kept
+	automount := true
+	account.AutomountServiceAccountToken = &automount
+	account.Secrets = []corev1.ObjectReference{{Name: testNames.ServiceAccount + "-token-x7k2p"}}
+	assert.Nil(t, client.Update(context.TODO(), account))
+	plan, err := request.planChanges(context.TODO(), testSetup.srchOperator)
+	assert.Nil(t, err)
+	assert.Equal(t, "ServiceAccount", plan.Changes[0].Kind, "Expected the update to be planned.")
+	assert.Nil(t, request.setupServiceAccount(context.TODO(), testSetup.srchOperator))
+	_ = client.Get(context.TODO(), key, account)
+	assert.False(t, *account.AutomountServiceAccountToken, "Expected the token not to be mounted.")
+	assert.Len(t, account.Secrets, 1, "Expected the token secret to be kept.")
+}
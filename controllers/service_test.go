@@ -0,0 +1,62 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileService(t *testing.T) {
+	testSetup := commonSetup()
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, testSetup.srchOperator, testSetup.secret, testSetup.pvc,
+		testSetup.podWithPVC)
+	reconciler := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
+	request := reconciler.newRequest(testSetup.request)
+
+	_, err := reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected reconcile to succeed. Got error: %v", err)
+	service := &corev1.Service{}
+	key := types.NamespacedName{Name: testNames.StatefulSet, Namespace: testNamespace}
+	err = client.Get(context.TODO(), key, service)
+	assert.Nil(t, err, "Expected the Service to be created. Got error: %v", err)
+	assert.Equal(t, request.expectedService(testSetup.srchOperator).Spec.Selector, service.Spec.Selector,
+		"Expected the Service to select the redisgraph pods.")
+	assert.Equal(t, testSetup.srchOperator.Name, service.OwnerReferences[0].Name,
+		"Expected the SearchOperator to own the Service.")
+
+	// A changed selector is reverted, the cluster IP is kept
+	service.Spec.Selector = map[string]string{"app": "other"}
+	service.Spec.ClusterIP = "172.30.0.10"
+	assert.Nil(t, client.Update(context.TODO(), service))
+	plan, err := request.planChanges(context.TODO(), testSetup.srchOperator)
+	assert.Nil(t, err)
+	assert.Equal(t, "Service", plan.Changes[0].Kind, "Expected the update to be planned.")
+	assert.Nil(t, request.reconcileService(context.TODO(), testSetup.srchOperator))
+	_ = client.Get(context.TODO(), key, service)
+	assert.Equal(t, request.expectedService(testSetup.srchOperator).Spec.Selector, service.Spec.Selector,
+		"Expected the selector to be restored.")
+	assert.Equal(t, "172.30.0.10", service.Spec.ClusterIP, "Expected the cluster IP to be kept.")
+
+	// The Service is deleted with the database, unless the SearchOperator doesn't own it
+	request.deployEnabled = false
+	defer func() { request.deployEnabled = true }()
+	service.OwnerReferences = []metav1.OwnerReference{}
+	assert.Nil(t, client.Update(context.TODO(), service))
+	assert.Nil(t, request.reconcileService(context.TODO(), testSetup.srchOperator))
+	assert.Nil(t, client.Get(context.TODO(), key, service), "Expected a Service it doesn't own to be kept.")
+	request.deployEnabled = true
+	assert.Nil(t, request.reconcileService(context.TODO(), testSetup.srchOperator))
+	request.deployEnabled = false
+	assert.Nil(t, request.reconcileService(context.TODO(), testSetup.srchOperator))
+	err = client.Get(context.TODO(), key, service)
+	assert.True(t, errors.IsNotFound(err), "Expected the Service to be deleted.")
+}
@@ -0,0 +1,24 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FindCustomization returns the SearchCustomization that applies to the SearchOperator instance, nil if none.
+// It's used by the support subcommands of the operator binary, which don't run the manager.
+func FindCustomization(ctx context.Context, kclient client.Client,
+	cr *searchv1alpha1.SearchOperator) (*searchv1alpha1.SearchCustomization, error) {
+	r := &SearchOperatorReconciler{Client: kclient}
+	custom, err := r.findCustomization(ctx, cr)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	return custom, err
+}
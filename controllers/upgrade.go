@@ -0,0 +1,412 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	upgradeSaving         = "Saving"
+	upgradeRolling        = "Rolling"
+	upgradeRollingBack    = "RollingBack"
+	upgradeSucceeded      = "Succeeded"
+	upgradeRolledBack     = "RolledBack"
+	upgradeRollbackFailed = "RollbackFailed"
+	upgradeFailed         = "Failed"
+	// maxUpgradeHistory is the number of upgrades kept in the status
+	maxUpgradeHistory = 10
+)
+
+var (
+	// upgradeRetryInterval is how soon an upgrade is tried again when the data couldn't be saved
+	upgradeRetryInterval = time.Minute
+	// upgradeCheckInterval is how often an upgrade in progress is advanced
+	upgradeCheckInterval = 5 * time.Second
+	// upgradeSaveTimeout is how long the background save may take
+	upgradeSaveTimeout = 5 * time.Minute
+	// upgradeRollTimeout is how long the pod may take to get ready with the new or the previous image
+	upgradeRollTimeout = time.Duration(waitSecondsForPodChk) * time.Second
+)
+
+// errRDBLoading is returned by the verification while the upgraded pod is still loading the RDB file
+var errRDBLoading = errors.New("the RDB file is still loading")
+
+// RedisUpgradeClient saves the data of Redisgraph before an upgrade and checks it's loaded after.
+// None of the calls waits for Redisgraph, the upgrade is advanced by the following reconciles.
+type RedisUpgradeClient interface {
+	// StartSave starts writing the data to the RDB file in the background and returns the number of nodes of the
+	// graph saved.
+	StartSave(ctx context.Context, target RedisTarget) (int64, error)
+	// Saved is true once the background save completed, an error is returned if it failed.
+	Saved(ctx context.Context, target RedisTarget) (bool, error)
+	// Verify checks the RDB file and the graph are loaded and returns the number of nodes of the graph.
+	// errRDBLoading is returned while the file is loading.
+	Verify(ctx context.Context, target RedisTarget) (int64, error)
+}
+
+// TLSRedisUpgradeClient saves and verifies the data of Redisgraph over TLS.
+type TLSRedisUpgradeClient struct {
+	Timeout time.Duration
+}
+
+// StartSave runs BGSAVE, a save already running is waited for instead of failing.
+func (c *TLSRedisUpgradeClient) StartSave(ctx context.Context, target RedisTarget) (int64, error) {
+	conn, err := dialRedis(ctx, target, c.Timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	nodes, err := conn.graphNodes()
+	if err != nil {
+		return 0, err
+	}
+	if _, err = conn.do("BGSAVE", "SCHEDULE"); err != nil {
+		return 0, fmt.Errorf("BGSAVE failed: %w", err)
+	}
+	return nodes, nil
+}
+
+// Saved checks INFO persistence reports no save running, or scheduled after an AOF rewrite, and that the last
+// one succeeded.
+func (c *TLSRedisUpgradeClient) Saved(ctx context.Context, target RedisTarget) (bool, error) {
+	conn, err := dialRedis(ctx, target, c.Timeout)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	info, err := conn.do("INFO", "persistence")
+	if err != nil {
+		return false, fmt.Errorf("INFO persistence failed: %w", err)
+	}
+	infoStr, _ := info.(string)
+	if infoField(infoStr, "rdb_bgsave_in_progress") != "0" || infoField(infoStr, "aof_rewrite_in_progress") == "1" {
+		return false, nil
+	}
+	if status := infoField(infoStr, "rdb_last_bgsave_status"); status != "ok" {
+		return false, fmt.Errorf("saving the RDB file failed with status %q", status)
+	}
+	return true, nil
+}
+
+// Verify checks Redisgraph isn't loading the RDB file anymore and counts the nodes of the graph.
+func (c *TLSRedisUpgradeClient) Verify(ctx context.Context, target RedisTarget) (int64, error) {
+	conn, err := dialRedis(ctx, target, c.Timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	info, err := conn.do("INFO", "persistence")
+	if err != nil {
+		return 0, fmt.Errorf("INFO persistence failed: %w", err)
+	}
+	infoStr, _ := info.(string)
+	if infoField(infoStr, "loading") != "0" {
+		return 0, errRDBLoading
+	}
+	return conn.graphNodes()
+}
+
+// graphNodes counts the nodes of the graph with a read-only query, 0 if the graph doesn't exist.
+func (c *redisConn) graphNodes() (int64, error) {
+	reply, err := c.do("GRAPH.RO_QUERY", redisGraphName, "MATCH (n) RETURN count(n)")
+	if err != nil && isEmptyGraph(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("GRAPH.RO_QUERY failed: %w", err)
+	}
+	// The reply is the header, the rows and the statistics of the query
+	if result, _ := reply.([]interface{}); len(result) == 3 {
+		if rows, _ := result[1].([]interface{}); len(rows) == 1 {
+			if row, _ := rows[0].([]interface{}); len(row) == 1 {
+				if nodes, ok := row[0].(int64); ok {
+					return nodes, nil
+				}
+			}
+		}
+	}
+	return 0, fmt.Errorf("unexpected reply to GRAPH.RO_QUERY: %v", reply)
+}
+
+// infoField returns the value of a field in the output of INFO, e.g. loading:0
+func infoField(info, name string) string {
+	for _, line := range strings.Split(info, "\n") {
+		if value := strings.TrimPrefix(strings.TrimSpace(line), name+":"); value != strings.TrimSpace(line) {
+			return value
+		}
+	}
+	return ""
+}
+
+// targetImage is the redisgraph image the SearchOperator asks for, with its digest if the image policy pins it.
+func (r *reconcileRequest) targetImage(cr *searchv1alpha1.SearchOperator) string {
+	if r.pinnedImage != "" {
+		return r.pinnedImage
+	}
+	return cr.Spec.SearchImageOverrides.Redisgraph_TLS
+}
+
+// upgradeInProgress is true while the upgrade is saving the data or rolling the pod.
+func upgradeInProgress(record *searchv1alpha1.UpgradeRecord) bool {
+	return record.Phase == upgradeSaving || record.Phase == upgradeRolling || record.Phase == upgradeRollingBack
+}
+
+// lastUpgrade returns the most recent upgrade of the status, nil if there's none.
+func lastUpgrade(cr *searchv1alpha1.SearchOperator) *searchv1alpha1.UpgradeRecord {
+	if len(cr.Status.Upgrades) == 0 {
+		return nil
+	}
+	return cr.Status.Upgrades[len(cr.Status.Upgrades)-1].DeepCopy()
+}
+
+// setUpgradeState holds the image the StatefulSet runs during an upgrade in progress, and the running image
+// when the upgrade to the image in the spec was rolled back. The upgrade is tried again once the SearchOperator
+// changes.
+func (r *reconcileRequest) setUpgradeState(cr *searchv1alpha1.SearchOperator) {
+	r.heldImage = ""
+	last := lastUpgrade(cr)
+	switch {
+	case last == nil:
+	case last.Phase == upgradeRolling:
+		r.heldImage = last.ToImage
+	case last.Phase == upgradeSaving || last.Phase == upgradeRollingBack:
+		r.heldImage = last.FromImage
+	case last.ToImage == r.targetImage(cr) && last.ObservedGeneration == cr.Generation &&
+		(last.Phase == upgradeRolledBack || last.Phase == upgradeRollbackFailed):
+		r.heldImage = last.FromImage
+	}
+}
+
+// rollingUpgrade is true while the upgrade rolls the redisgraph pod to the new or the previous image.
+func (r *reconcileRequest) rollingUpgrade() bool {
+	return r.upgradeRecord != nil &&
+		(r.upgradeRecord.Phase == upgradeRolling || r.upgradeRecord.Phase == upgradeRollingBack)
+}
+
+// upgradeRedisgraph rolls redisgraph running with its PVC to a new image in phases recorded in the status, each
+// reconcile advances the upgrade without waiting. The data is saved before rolling the pod and the upgraded pod
+// must load as many nodes of the graph, otherwise the previous image is rolled out again. An error is returned if
+// the data couldn't be saved, the previous image is kept and the upgrade is tried again later.
+func (r *reconcileRequest) upgradeRedisgraph(ctx context.Context, cr *searchv1alpha1.SearchOperator) error {
+	r.setUpgradeState(cr)
+	if r.Upgrader == nil {
+		return nil
+	}
+	if last := lastUpgrade(cr); last != nil && upgradeInProgress(last) {
+		r.upgradeRecord = last
+		r.upgradeRequeueAfter = upgradeCheckInterval
+		return r.advanceUpgrade(ctx, cr, last)
+	}
+	if r.heldImage != "" {
+		return nil
+	}
+	sset := &appv1.StatefulSet{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: r.names.StatefulSet, Namespace: r.namespace}, sset)
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	from, to := containerImage(&sset.Spec.Template.Spec), r.targetImage(cr)
+	// Without data to keep the image is updated in place
+	if from == "" || from == to || !persistentVolume(sset) || !r.isPodRunning(ctx, true, 1) {
+		return nil
+	}
+	logf.FromContext(ctx).Info("Upgrading redisgraph, saving the data", "from", from, "to", to)
+	now := metav1.Now()
+	record := &searchv1alpha1.UpgradeRecord{FromImage: from, ToImage: to, Phase: upgradeSaving, StartTime: now,
+		LastTransitionTime: &now, ObservedGeneration: cr.Generation}
+	r.upgradeRecord = record
+	r.heldImage = from
+	target, err := r.redisTarget(ctx, cr)
+	if err == nil {
+		record.NodesBefore, err = r.Upgrader.StartSave(ctx, target)
+	}
+	if err != nil {
+		return r.failUpgrade(record, err)
+	}
+	r.upgradeRequeueAfter = upgradeCheckInterval
+	return nil
+}
+
+// advanceUpgrade moves the upgrade in progress to its next phase once the current one completed or timed out.
+func (r *reconcileRequest) advanceUpgrade(ctx context.Context, cr *searchv1alpha1.SearchOperator,
+	record *searchv1alpha1.UpgradeRecord) error {
+	log := logf.FromContext(ctx)
+	timedOut := func(timeout time.Duration) bool {
+		return record.LastTransitionTime == nil || time.Since(record.LastTransitionTime.Time) > timeout
+	}
+	switch record.Phase {
+	case upgradeSaving:
+		target, err := r.redisTarget(ctx, cr)
+		saved := false
+		if err == nil {
+			saved, err = r.Upgrader.Saved(ctx, target)
+		}
+		if err == nil && !saved && timedOut(upgradeSaveTimeout) {
+			err = fmt.Errorf("the RDB file wasn't saved after %s", upgradeSaveTimeout)
+		}
+		if err != nil {
+			return r.failUpgrade(record, err)
+		}
+		if !saved {
+			return nil
+		}
+		if err = r.setStatefulSetImage(ctx, record.ToImage); err != nil {
+			return err
+		}
+		log.Info("Upgrading redisgraph, rolling the pod", "image", record.ToImage, "nodes", record.NodesBefore)
+		transitionUpgrade(record, upgradeRolling)
+		r.heldImage = record.ToImage
+	case upgradeRolling:
+		ready, err := r.imageReady(ctx, record.ToImage, timedOut(upgradeRollTimeout))
+		if err != nil {
+			return err
+		}
+		if !ready {
+			if timedOut(upgradeRollTimeout) {
+				return r.rollBack(ctx, record, fmt.Errorf("the redisgraph pod isn't ready with %s: %s",
+					record.ToImage, r.redisPodFailure.String()))
+			}
+			return nil
+		}
+		target, err := r.redisTarget(ctx, cr)
+		if err == nil {
+			record.NodesAfter, err = r.Upgrader.Verify(ctx, target)
+		}
+		if errors.Is(err, errRDBLoading) && !timedOut(upgradeRollTimeout) {
+			return nil
+		}
+		if err == nil && record.NodesAfter != record.NodesBefore {
+			err = fmt.Errorf("%d nodes loaded, %d were saved", record.NodesAfter, record.NodesBefore)
+		}
+		if err != nil {
+			return r.rollBack(ctx, record, err)
+		}
+		log.Info("Upgraded redisgraph", "image", record.ToImage, "nodes", record.NodesAfter)
+		transitionUpgrade(record, upgradeSucceeded)
+		r.heldImage = ""
+		r.upgradeRequeueAfter = 0
+	case upgradeRollingBack:
+		ready, err := r.imageReady(ctx, record.FromImage, timedOut(upgradeRollTimeout))
+		if err != nil {
+			return err
+		}
+		switch {
+		case ready:
+			transitionUpgrade(record, upgradeRolledBack)
+			r.upgradeRequeueAfter = 0
+		case timedOut(upgradeRollTimeout):
+			log.Info("Redisgraph rollback failed", "image", record.FromImage, "reason", r.redisPodFailure.String())
+			record.Message += fmt.Sprintf("; rollback: the redisgraph pod isn't ready with %s: %s",
+				record.FromImage, r.redisPodFailure.String())
+			transitionUpgrade(record, upgradeRollbackFailed)
+			r.upgradeRequeueAfter = 0
+		}
+	}
+	return nil
+}
+
+// transitionUpgrade moves the upgrade to the phase, the upgrade is complete once it's no longer in progress.
+func transitionUpgrade(record *searchv1alpha1.UpgradeRecord, phase string) {
+	now := metav1.Now()
+	record.Phase = phase
+	record.LastTransitionTime = &now
+	if !upgradeInProgress(record) {
+		record.CompletionTime = &now
+	}
+}
+
+// failUpgrade records that the data couldn't be saved, the previous image is kept.
+func (r *reconcileRequest) failUpgrade(record *searchv1alpha1.UpgradeRecord, err error) error {
+	record.Message = "saving the data: " + err.Error()
+	transitionUpgrade(record, upgradeFailed)
+	r.heldImage = record.FromImage
+	r.upgradeRequeueAfter = 0
+	return fmt.Errorf("saving the redisgraph data before the upgrade to %s: %w", record.ToImage, err)
+}
+
+// rollBack rolls the previous image out again after the upgraded pod failed.
+func (r *reconcileRequest) rollBack(ctx context.Context, record *searchv1alpha1.UpgradeRecord,
+	reason error) error {
+	logf.FromContext(ctx).Info("Redisgraph upgrade failed, rolling back", "image", record.FromImage,
+		"reason", reason.Error())
+	record.Message = reason.Error()
+	r.heldImage = record.FromImage
+	if err := r.setStatefulSetImage(ctx, record.FromImage); err != nil {
+		record.Message += "; rollback: " + err.Error()
+		transitionUpgrade(record, upgradeRollbackFailed)
+		r.upgradeRequeueAfter = 0
+		return nil
+	}
+	transitionUpgrade(record, upgradeRollingBack)
+	return nil
+}
+
+// setStatefulSetImage updates the image of the redisgraph StatefulSet.
+func (r *reconcileRequest) setStatefulSetImage(ctx context.Context, image string) error {
+	sset := &appv1.StatefulSet{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: r.names.StatefulSet, Namespace: r.namespace}, sset)
+	if err != nil {
+		return err
+	}
+	setContainerImage(&sset.Spec.Template.Spec, image)
+	return r.Client.Update(ctx, sset)
+}
+
+// imageReady is true if a redisgraph pod with the PVC runs the image and is ready. With diagnose, the failure of
+// a pod running the image that isn't ready is recorded.
+func (r *reconcileRequest) imageReady(ctx context.Context, image string, diagnose bool) (bool, error) {
+	r.redisPodFailure = podFailure{}
+	pods := &corev1.PodList{}
+	if err := r.Client.List(ctx, pods, client.InNamespace(r.namespace), r.redisgraphSelector()); err != nil {
+		return false, err
+	}
+	var notReadyPod *corev1.Pod
+	for i, pod := range pods.Items {
+		if containerImage(&pod.Spec) != image || !pod.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if r.isReady(ctx, pod, true) {
+			return true, nil
+		}
+		notReadyPod = &pods.Items[i]
+	}
+	if diagnose && notReadyPod != nil {
+		r.redisPodFailure = r.diagnosePod(ctx, *notReadyPod)
+	}
+	return false, nil
+}
+
+// setUpgradeStatus records the upgrade in the history. An upgrade in progress and a failed save tried again
+// replace their previous record.
+func (r *reconcileRequest) setUpgradeStatus(cr *searchv1alpha1.SearchOperator) {
+	if r.upgradeRecord == nil {
+		return
+	}
+	upgrades := cr.Status.Upgrades
+	if n := len(upgrades); n > 0 && upgrades[n-1].FromImage == r.upgradeRecord.FromImage &&
+		upgrades[n-1].ToImage == r.upgradeRecord.ToImage && (upgrades[n-1].StartTime.Equal(&r.upgradeRecord.StartTime) ||
+		upgrades[n-1].Phase == upgradeFailed && r.upgradeRecord.Phase == upgradeFailed) {
+		upgrades = upgrades[:n-1]
+	}
+	upgrades = append(upgrades, *r.upgradeRecord)
+	if len(upgrades) > maxUpgradeHistory {
+		upgrades = upgrades[len(upgrades)-maxUpgradeHistory:]
+	}
+	cr.Status.Upgrades = upgrades
+}
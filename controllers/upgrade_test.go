@@ -0,0 +1,267 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testUpgradedImage = "quay.io/stolostron/redisgraph-tls:2.6.0"
+
+func TestTLSRedisUpgradeClient(t *testing.T) {
+	server := newFakeRedis(t, "secret")
+	var loading, saving, rewriting int64
+	saveStatus := atomic.Value{}
+	saveStatus.Store("ok")
+	server.handlers["BGSAVE"] = func(args []string) string {
+		assert.Equal(t, []string{"BGSAVE", "SCHEDULE"}, args)
+		return "+Background saving started\r\n"
+	}
+	server.handlers["INFO"] = func(args []string) string {
+		return bulkString(fmt.Sprintf("# Persistence\r\nloading:%d\r\nrdb_bgsave_in_progress:%d\r\n"+
+			"rdb_last_bgsave_status:%s\r\naof_rewrite_in_progress:%d\r\n", atomic.LoadInt64(&loading),
+			atomic.LoadInt64(&saving), saveStatus.Load(), atomic.LoadInt64(&rewriting)))
+	}
+	server.handlers["GRAPH.RO_QUERY"] = func(args []string) string {
+		assert.Equal(t, []string{"GRAPH.RO_QUERY", redisGraphName, "MATCH (n) RETURN count(n)"}, args)
+		return "*3\r\n*1\r\n" + bulkString("count(n)") + "*1\r\n*1\r\n:42\r\n*1\r\n" +
+			bulkString("Query internal execution time: 0.1 milliseconds")
+	}
+	upgrader := &TLSRedisUpgradeClient{Timeout: 2 * time.Second}
+
+	nodes, err := upgrader.StartSave(context.TODO(), server.target("secret"))
+	assert.Nil(t, err, "Expected the save to be started. Got error: %v", err)
+	assert.Equal(t, int64(42), nodes)
+	atomic.StoreInt64(&saving, 1)
+	saved, _ := upgrader.Saved(context.TODO(), server.target("secret"))
+	assert.False(t, saved, "Expected the save not to be complete while it's running.")
+	atomic.StoreInt64(&saving, 0)
+	atomic.StoreInt64(&rewriting, 1)
+	saved, _ = upgrader.Saved(context.TODO(), server.target("secret"))
+	assert.False(t, saved, "Expected the save not to be complete while it's scheduled after an AOF rewrite.")
+	atomic.StoreInt64(&rewriting, 0)
+	saved, err = upgrader.Saved(context.TODO(), server.target("secret"))
+	assert.Nil(t, err, "Expected the save to be checked. Got error: %v", err)
+	assert.True(t, saved, "Expected the save to be complete.")
+	nodes, err = upgrader.Verify(context.TODO(), server.target("secret"))
+	assert.Nil(t, err, "Expected the data to be verified. Got error: %v", err)
+	assert.Equal(t, int64(42), nodes)
+
+	saveStatus.Store("err")
+	_, err = upgrader.Saved(context.TODO(), server.target("secret"))
+	assert.NotNil(t, err, "Expected a failed background save to be reported.")
+	atomic.StoreInt64(&loading, 1)
+	_, err = upgrader.Verify(context.TODO(), server.target("secret"))
+	assert.True(t, errors.Is(err, errRDBLoading), "Expected the RDB file to be reported loading, got %v", err)
+}
+
+func TestTLSRedisUpgradeClientEmptyGraph(t *testing.T) {
+	server := newFakeRedis(t, "secret")
+	server.handlers["INFO"] = func(args []string) string { return bulkString("# Persistence\r\nloading:0\r\n") }
+	server.handlers["GRAPH.RO_QUERY"] = func([]string) string {
+		return "-ERR Invalid graph operation on empty key\r\n"
+	}
+	upgrader := &TLSRedisUpgradeClient{Timeout: 2 * time.Second}
+
+	nodes, err := upgrader.Verify(context.TODO(), server.target("secret"))
+	assert.Nil(t, err, "Expected a missing graph to be verified. Got error: %v", err)
+	assert.Equal(t, int64(0), nodes)
+
+	server.handlers["GRAPH.RO_QUERY"] = func([]string) string { return "*1\r\n:42\r\n" }
+	_, err = upgrader.Verify(context.TODO(), server.target("secret"))
+	assert.NotNil(t, err, "Expected an unexpected reply to be reported.")
+}
+
+func TestInfoField(t *testing.T) {
+	info := "# Persistence\r\nloading:0\r\nrdb_bgsave_in_progress:0\r\nrdb_last_bgsave_status:ok\r\n"
+	assert.Equal(t, "0", infoField(info, "loading"))
+	assert.Equal(t, "ok", infoField(info, "rdb_last_bgsave_status"))
+	assert.Equal(t, "", infoField(info, "aof_enabled"))
+}
+
+// fakeUpgrader is the local stand-in for Redisgraph saving and loading its data.
+type fakeUpgrader struct {
+	nodesBefore, nodesAfter int64
+	saveErr                 error
+	saves                   int
+}
+
+func (u *fakeUpgrader) StartSave(ctx context.Context, target RedisTarget) (int64, error) {
+	u.saves++
+	return u.nodesBefore, u.saveErr
+}
+
+func (u *fakeUpgrader) Saved(ctx context.Context, target RedisTarget) (bool, error) {
+	return true, nil
+}
+
+func (u *fakeUpgrader) Verify(ctx context.Context, target RedisTarget) (int64, error) {
+	return u.nodesAfter, nil
+}
+
+// rollPods stands in for the StatefulSet controller, the redisgraph pod is recreated with the image of the
+// template. Pods running the broken image never get ready.
+func rollPods(t *testing.T, kclient client.Client, pod *corev1.Pod, broken string) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	t.Cleanup(cancel)
+	go func() {
+		for ctx.Err() == nil {
+			sset := &appv1.StatefulSet{}
+			found := &corev1.Pod{}
+			if kclient.Get(ctx, types.NamespacedName{Name: testNames.StatefulSet, Namespace: testNamespace}, sset) == nil &&
+				kclient.Get(ctx, client.ObjectKeyFromObject(pod), found) == nil {
+				image := containerImage(&sset.Spec.Template.Spec)
+				if containerImage(&found.Spec) != image {
+					setContainerImage(&found.Spec, image)
+					found.Status.ContainerStatuses[0].Ready = image != broken
+					_ = kclient.Update(ctx, found)
+				}
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}()
+}
+
+// upgradeSetup runs redisgraph with its PVC on the test image and changes the SearchOperator to the upgraded image.
+func upgradeSetup(t *testing.T, upgrader RedisUpgradeClient, broken string) (testSetup, client.Client,
+	SearchOperatorReconciler) {
+	testSetup := commonSetup()
+	operator := imagePolicyOperator(nil)
+	pod := testSetup.podWithPVC.DeepCopy()
+	pod.Name = testNames.StatefulSet + "-0"
+	pod.Spec.Containers[0].Name = redisgraphContainer
+	pod.Spec.Containers[0].Image = testRedisgraphImage
+	kclient := fake.NewFakeClientWithScheme(testSetup.scheme, operator, testSetup.secret, testSetup.pvc, pod,
+		serviceCA())
+	reconciler := SearchOperatorReconciler{Client: kclient, Log: log, Scheme: testSetup.scheme, Upgrader: upgrader}
+	request := reconciler.newRequest(testSetup.request)
+	rollTimeout := upgradeRollTimeout
+	upgradeRollTimeout = 2 * time.Second
+	t.Cleanup(func() { upgradeRollTimeout = rollTimeout })
+	request.executeDeployment(context.TODO(), kclient, operator, true, true)
+
+	_ = kclient.Get(context.TODO(), testSetup.request.NamespacedName, operator)
+	operator.Spec.SearchImageOverrides.Redisgraph_TLS = testUpgradedImage
+	assert.Nil(t, kclient.Update(context.TODO(), operator))
+	rollPods(t, kclient, pod, broken)
+	return testSetup, kclient, reconciler
+}
+
+// reconcileUpgrade reconciles until the upgrade completed, each reconcile advances it without waiting.
+func reconcileUpgrade(t *testing.T, testSetup testSetup, kclient client.Client,
+	reconciler SearchOperatorReconciler) *searchv1alpha1.SearchOperator {
+	operator := &searchv1alpha1.SearchOperator{}
+	for i := 0; i < 100; i++ {
+		result, err := reconciler.Reconcile(testSetup.context, testSetup.request)
+		assert.Nil(t, err, "Expected reconcile to succeed. Got error: %v", err)
+		_ = kclient.Get(context.TODO(), testSetup.request.NamespacedName, operator)
+		last := lastUpgrade(operator)
+		if last == nil || !upgradeInProgress(last) {
+			return operator
+		}
+		assert.Equal(t, upgradeCheckInterval, result.RequeueAfter, "Expected the upgrade to be advanced by a requeue.")
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("Expected the upgrade to complete, got %+v", operator.Status.Upgrades)
+	return operator
+}
+
+func runningImage(t *testing.T, kclient client.Client) string {
+	sset := &appv1.StatefulSet{}
+	err := kclient.Get(context.TODO(), types.NamespacedName{Name: testNames.StatefulSet, Namespace: testNamespace}, sset)
+	assert.Nil(t, err, "Expected the StatefulSet to be found. Got error: %v", err)
+	return containerImage(&sset.Spec.Template.Spec)
+}
+
+func TestUpgradeRedisgraph(t *testing.T) {
+	upgrader := &fakeUpgrader{nodesBefore: 42, nodesAfter: 42}
+	testSetup, kclient, reconciler := upgradeSetup(t, upgrader, "")
+
+	// The first reconcile starts saving the data and completes with the previous image
+	result, err := reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected reconcile to succeed. Got error: %v", err)
+	assert.Equal(t, upgradeCheckInterval, result.RequeueAfter, "Expected the upgrade to be advanced by a requeue.")
+	assert.Equal(t, testRedisgraphImage, runningImage(t, kclient), "Expected the image to be kept while saving.")
+	operator := &searchv1alpha1.SearchOperator{}
+	_ = kclient.Get(context.TODO(), testSetup.request.NamespacedName, operator)
+	assert.Equal(t, upgradeSaving, operator.Status.Upgrades[0].Phase, "Expected the upgrade to be saving the data.")
+	assert.Equal(t, statusUsingPVC, operator.Status.PersistenceStatus,
+		"Expected the rest of the reconcile to proceed while saving.")
+
+	operator = reconcileUpgrade(t, testSetup, kclient, reconciler)
+	assert.Equal(t, testUpgradedImage, runningImage(t, kclient), "Expected redisgraph to be upgraded.")
+	assert.Len(t, operator.Status.Upgrades, 1, "Expected the upgrade in the history.")
+	upgrade := operator.Status.Upgrades[0]
+	assert.Equal(t, upgradeSucceeded, upgrade.Phase)
+	assert.Equal(t, testRedisgraphImage, upgrade.FromImage)
+	assert.Equal(t, testUpgradedImage, upgrade.ToImage)
+	assert.Equal(t, int64(42), upgrade.NodesAfter)
+	assert.NotNil(t, upgrade.CompletionTime)
+	assert.Equal(t, statusUsingPVC, operator.Status.PersistenceStatus)
+
+	_, err = reconciler.Reconcile(testSetup.context, testSetup.request)
+	assert.Nil(t, err, "Expected reconcile to succeed. Got error: %v", err)
+	assert.Equal(t, 1, upgrader.saves, "Expected the upgraded image not to be upgraded again.")
+}
+
+func TestUpgradeRedisgraphRollback(t *testing.T) {
+	tests := []struct {
+		name     string
+		upgrader *fakeUpgrader
+		broken   string
+		message  string
+	}{
+		{name: "nodes lost", upgrader: &fakeUpgrader{nodesBefore: 42, nodesAfter: 40},
+			message: "40 nodes loaded, 42 were saved"},
+		{name: "pod not ready", upgrader: &fakeUpgrader{nodesBefore: 42, nodesAfter: 42}, broken: testUpgradedImage,
+			message: "the redisgraph pod isn't ready with " + testUpgradedImage},
+	}
+	for _, test := range tests {
+		testSetup, kclient, reconciler := upgradeSetup(t, test.upgrader, test.broken)
+
+		operator := reconcileUpgrade(t, testSetup, kclient, reconciler)
+		assert.Equal(t, testRedisgraphImage, runningImage(t, kclient), "Expected a rollback when %s.", test.name)
+		assert.Len(t, operator.Status.Upgrades, 1, "Expected the upgrade in the history when %s.", test.name)
+		assert.Equal(t, upgradeRolledBack, operator.Status.Upgrades[0].Phase)
+		assert.True(t, strings.HasPrefix(operator.Status.Upgrades[0].Message, test.message),
+			"Expected the reason of the rollback, got %q", operator.Status.Upgrades[0].Message)
+
+		// The upgrade isn't tried again until the SearchOperator changes
+		_, err := reconciler.Reconcile(testSetup.context, testSetup.request)
+		assert.Nil(t, err, "Expected reconcile to succeed. Got error: %v", err)
+		assert.Equal(t, 1, test.upgrader.saves, "Expected the upgrade not to be tried again when %s.", test.name)
+		assert.Equal(t, testRedisgraphImage, runningImage(t, kclient), "Expected the previous image to be kept.")
+	}
+}
+
+func TestUpgradeRedisgraphSaveFailed(t *testing.T) {
+	upgrader := &fakeUpgrader{saveErr: errors.New("MISCONF Errors writing to the AOF file")}
+	testSetup, kclient, reconciler := upgradeSetup(t, upgrader, "")
+
+	for i := 0; i < 2; i++ {
+		result, err := reconciler.Reconcile(testSetup.context, testSetup.request)
+		assert.Nil(t, err, "Expected reconcile to succeed. Got error: %v", err)
+		assert.Equal(t, upgradeRetryInterval, result.RequeueAfter, "Expected the upgrade to be tried again.")
+	}
+	assert.Equal(t, 2, upgrader.saves)
+	assert.Equal(t, testRedisgraphImage, runningImage(t, kclient), "Expected the image not to change.")
+	operator := &searchv1alpha1.SearchOperator{}
+	_ = kclient.Get(context.TODO(), testSetup.request.NamespacedName, operator)
+	assert.Len(t, operator.Status.Upgrades, 1, "Expected a failed save tried again to be recorded once.")
+	assert.Equal(t, upgradeFailed, operator.Status.Upgrades[0].Phase)
+}
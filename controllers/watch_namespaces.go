@@ -0,0 +1,52 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// WatchNamespaces parses the WATCH_NAMESPACE value, a comma separated list of namespaces.
+// An empty value means all namespaces and returns nil.
+func WatchNamespaces(value string) []string {
+	var namespaces []string
+	seen := map[string]bool{}
+	for _, ns := range strings.Split(value, ",") {
+		ns = strings.TrimSpace(ns)
+		if ns == "" || seen[ns] {
+			continue
+		}
+		seen[ns] = true
+		namespaces = append(namespaces, ns)
+	}
+	return namespaces
+}
+
+// namespaceWatched returns true if events from the namespace should be reconciled.
+func namespaceWatched(namespaces []string, ns string) bool {
+	if len(namespaces) == 0 {
+		return true
+	}
+	for _, watched := range namespaces {
+		if watched == ns {
+			return true
+		}
+	}
+	return false
+}
+
+// searchCustomizationRequests maps a SearchCustomization to the SearchOperator instance it references
+// in its own namespace.
+func searchCustomizationRequests(a client.Object) []reconcile.Request {
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{
+			Name:      customizationTarget(a),
+			Namespace: a.GetNamespace(),
+		}},
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package controllers
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestWatchNamespaces(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected []string
+	}{
+		{value: "", expected: nil},
+		{value: "open-cluster-management", expected: []string{"open-cluster-management"}},
+		{value: "team-a, team-b,,team-a", expected: []string{"team-a", "team-b"}},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, WatchNamespaces(test.value), "Unexpected namespaces for %q", test.value)
+	}
+
+	assert.True(t, namespaceWatched(nil, "team-c"), "Expected all namespaces to be watched.")
+	assert.True(t, namespaceWatched([]string{"team-a", "team-b"}, "team-b"))
+	assert.False(t, namespaceWatched([]string{"team-a", "team-b"}, "team-c"))
+}
+
+func TestSearchCustomizationRequests(t *testing.T) {
+	custom := createFakeSearchCustomizationCR("team-b", true)
+	requests := searchCustomizationRequests(custom)
+
+	assert.Equal(t, []reconcile.Request{{NamespacedName: types.NamespacedName{
+		Name: "searchoperator", Namespace: "team-b"}}}, requests,
+		"Expected customization to map to the instance in its own namespace.")
+
+	custom.Spec.SearchOperatorRef = "my-search"
+	assert.Equal(t, "my-search", searchCustomizationRequests(custom)[0].Name,
+		"Expected customization to map to the referenced instance.")
+}
+
+func Test_ReconcileIsolatesNamespaces(t *testing.T) {
+	testSetup := commonSetup()
+	operatorA := testSetup.srchOperator.DeepCopy()
+	operatorA.Namespace = "team-a"
+	operatorB := testSetup.srchOperator.DeepCopy()
+	operatorB.Namespace = "team-b"
+
+	client := fake.NewFakeClientWithScheme(testSetup.scheme, operatorA, operatorB,
+		createFakeNamedPVC("10Gi", "team-a", nil), createFakeRedisGraphPod("team-a", true, true),
+		createFakeSearchCustomizationCR("team-b", false), createFakeRedisGraphPod("team-b", false, true))
+	reconciler := SearchOperatorReconciler{Client: client, Log: log, Scheme: testSetup.scheme}
+
+	// The instances are reconciled at the same time, each reconcile keeps its own state
+	var wg sync.WaitGroup
+	for _, operator := range []*searchv1alpha1.SearchOperator{operatorA, operatorB} {
+		req := reconcile.Request{NamespacedName: types.NamespacedName{Name: operator.Name, Namespace: operator.Namespace}}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := reconciler.Reconcile(testSetup.context, req)
+			assert.Nil(t, err, "Expected reconcile in %s to complete successfully. Got error: %v", req.Namespace, err)
+		}()
+	}
+	wg.Wait()
+
+	expectedStatus := map[string]string{"team-a": statusUsingPVC, "team-b": statusNoPersistence}
+	for ns, status := range expectedStatus {
+		instance := &searchv1alpha1.SearchOperator{}
+		err := client.Get(context.TODO(), types.NamespacedName{Name: "searchoperator", Namespace: ns}, instance)
+		assert.Nil(t, err, "Expected search Operator to be found. Got error: %v", err)
+		assert.Equal(t, status, instance.Status.PersistenceStatus, "Unexpected status in %s", ns)
+
+		sts := &appv1.StatefulSet{}
+		err = client.Get(context.TODO(), types.NamespacedName{Name: testNames.StatefulSet, Namespace: ns}, sts)
+		assert.Nil(t, err, "Expected redisgraph statefulset in %s. Got error: %v", ns, err)
+		secret := &corev1.Secret{}
+		err = client.Get(context.TODO(), types.NamespacedName{Name: "redisgraph-user-secret", Namespace: ns}, secret)
+		assert.Nil(t, err, "Expected redisgraph secret in %s. Got error: %v", ns, err)
+	}
+
+	// Reconciling team-b with persistence disabled must not change the team-a statefulset.
+	stsA := &appv1.StatefulSet{}
+	err := client.Get(context.TODO(), types.NamespacedName{Name: testNames.StatefulSet, Namespace: "team-a"}, stsA)
+	assert.Nil(t, err, "Expected redisgraph statefulset in team-a. Got error: %v", err)
+	claimName := ""
+	for _, volume := range stsA.Spec.Template.Spec.Volumes {
+		if volume.Name == "persist" && volume.PersistentVolumeClaim != nil {
+			claimName = volume.PersistentVolumeClaim.ClaimName
+		}
+	}
+	assert.Equal(t, testNames.DefaultPVC, claimName, "Expected team-a to keep its PVC.")
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package health contains the checks served on the operator's healthz and readyz endpoints.
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+// cacheSyncTimeout bounds how long a readiness request waits for the informer caches
+const cacheSyncTimeout = time.Second
+
+// CacheSyncer is implemented by the manager's cache.
+type CacheSyncer interface {
+	WaitForCacheSync(ctx context.Context) bool
+}
+
+// CacheSynced returns a check that passes once the informer caches are synced.
+func CacheSynced(cache CacheSyncer) healthz.Checker {
+	return func(req *http.Request) error {
+		ctx, cancel := context.WithTimeout(req.Context(), cacheSyncTimeout)
+		defer cancel()
+		if !cache.WaitForCacheSync(ctx) {
+			return fmt.Errorf("informer caches are not synced")
+		}
+		return nil
+	}
+}
+
+// CRDsServed returns a check that passes when the API server serves all the given resources
+// of the group version, i.e. the CRDs the operator watches are installed and established.
+func CRDsServed(client discovery.DiscoveryInterface, gv schema.GroupVersion, resources ...string) healthz.Checker {
+	return func(_ *http.Request) error {
+		return Served(client, gv, resources...)
+	}
+}
+
+// Served returns an error unless the API server serves all the given resources of the group version.
+func Served(client discovery.DiscoveryInterface, gv schema.GroupVersion, resources ...string) error {
+	list, err := client.ServerResourcesForGroupVersion(gv.String())
+	if err != nil {
+		return fmt.Errorf("unable to discover %s: %w", gv.String(), err)
+	}
+	served := map[string]bool{}
+	for _, resource := range list.APIResources {
+		served[resource.Name] = true
+	}
+	for _, resource := range resources {
+		if !served[resource] {
+			return fmt.Errorf("%s is not served by %s", resource, gv.String())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package health
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+type fakeCache struct {
+	synced bool
+}
+
+func (c fakeCache) WaitForCacheSync(ctx context.Context) bool {
+	return c.synced
+}
+
+func TestCacheSynced(t *testing.T) {
+	req := &http.Request{}
+	assert.NotNil(t, CacheSynced(fakeCache{synced: false})(req), "Expected check to fail before caches sync.")
+	assert.Nil(t, CacheSynced(fakeCache{synced: true})(req), "Expected check to pass once caches are synced.")
+}
+
+func TestCRDsServed(t *testing.T) {
+	discovery := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+	check := CRDsServed(discovery, searchv1alpha1.GroupVersion, "searchoperators", "searchcustomizations")
+
+	err := check(&http.Request{})
+	assert.NotNil(t, err, "Expected check to fail when the group version isn't served.")
+
+	discovery.Resources = []*metav1.APIResourceList{{
+		GroupVersion: searchv1alpha1.GroupVersion.String(),
+		APIResources: []metav1.APIResource{{Name: "searchoperators"}},
+	}}
+	err = check(&http.Request{})
+	assert.NotNil(t, err, "Expected check to fail when a CRD is missing.")
+	assert.Contains(t, err.Error(), "searchcustomizations")
+
+	discovery.Resources[0].APIResources = append(discovery.Resources[0].APIResources,
+		metav1.APIResource{Name: "searchcustomizations"})
+	assert.Nil(t, check(&http.Request{}), "Expected check to pass when all CRDs are served.")
+}
+
+func TestServed(t *testing.T) {
+	discovery := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+	discovery.Resources = []*metav1.APIResourceList{{
+		GroupVersion: searchv1alpha1.GroupVersion.String(),
+		APIResources: []metav1.APIResource{{Name: "searchoperators"}},
+	}}
+	assert.Nil(t, Served(discovery, searchv1alpha1.GroupVersion, "searchoperators"))
+	assert.NotNil(t, Served(discovery, searchv1alpha1.GroupVersion, "searches"),
+		"Expected an error when the CRD isn't installed.")
+}
@@ -19,18 +19,27 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
+	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/discovery"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	searchopenclustermanagementiov1 "github.com/stolostron/search-operator/api/v1alpha1"
+	searchv1beta1 "github.com/stolostron/search-operator/api/v1beta1"
+	"github.com/stolostron/search-operator/cli"
 	"github.com/stolostron/search-operator/controllers"
+	"github.com/stolostron/search-operator/health"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -43,47 +52,146 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(searchopenclustermanagementiov1.AddToScheme(scheme))
+	utilruntime.Must(searchv1beta1.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
 func main() {
+	// The support subcommands run instead of the manager
+	if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+		os.Exit(cli.Run(context.Background(), scheme, os.Args[1], os.Args[2:], os.Stdout, os.Stderr))
+	}
 	var metricsAddr string
+	var probeAddr string
 	var enableLeaderElection bool
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
+	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	// Development mode stays the default, use --zap-devel=false --zap-encoder=json for production logging.
+	opts := zap.Options{
+		Development: true,
+	}
+	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
-	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:             scheme,
-		MetricsBindAddress: metricsAddr,
-		Port:               9443,
-		LeaderElection:     enableLeaderElection,
-		LeaderElectionID:   "c2dd32d7",
-		Namespace:          os.Getenv("WATCH_NAMESPACE"),
-	})
+	options := ctrl.Options{
+		Scheme:                 scheme,
+		MetricsBindAddress:     metricsAddr,
+		HealthProbeBindAddress: probeAddr,
+		Port:                   9443,
+		LeaderElection:         enableLeaderElection,
+		LeaderElectionID:       "c2dd32d7",
+	}
+	// WATCH_NAMESPACE is a comma separated list of namespaces, all namespaces are watched if it's empty.
+	watchNamespaces := controllers.WatchNamespaces(os.Getenv("WATCH_NAMESPACE"))
+	switch len(watchNamespaces) {
+	case 0:
+		setupLog.Info("Watching all namespaces")
+	case 1:
+		options.Namespace = watchNamespaces[0]
+	default:
+		setupLog.Info("Watching multiple namespaces", "namespaces", watchNamespaces)
+		options.NewCache = cache.MultiNamespacedCacheBuilder(watchNamespaces)
+	}
+
+	config := ctrl.GetConfigOrDie()
+	mgr, err := ctrl.NewManager(config, options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
 	if err = (&controllers.SearchOperatorReconciler{
+		Client:    mgr.GetClient(),
+		Log:       ctrl.Log.WithName("controllers").WithName("SearchOperator"),
+		Scheme:    mgr.GetScheme(),
+		Prober:    &controllers.TLSRedisProber{Timeout: 5 * time.Second},
+		Registry:  &controllers.HTTPRegistryClient{Timeout: 10 * time.Second, TokenHosts: registryTokenHosts()},
+		Verifier:  imageVerifier(),
+		Upgrader:  &controllers.TLSRedisUpgradeClient{Timeout: 5 * time.Second},
+		ACL:       &controllers.TLSRedisACLClient{Timeout: 5 * time.Second},
+		APIReader: mgr.GetAPIReader(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "SearchOperator")
+		os.Exit(1)
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		setupLog.Error(err, "unable to create discovery client")
+		os.Exit(1)
+	}
+	// The Search controller only runs if the searches CRD is installed, its informer would never sync otherwise
+	searchServed := health.Served(discoveryClient, searchv1beta1.GroupVersion, "searches") == nil
+	if !searchServed {
+		setupLog.Info("The searches CRD isn't installed, the Search controller is disabled")
+	} else if err = (&controllers.SearchReconciler{
 		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("SearchOperator"),
 		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "SearchOperator")
+		setupLog.Error(err, "unable to create controller", "controller", "Search")
 		os.Exit(1)
 	}
 
+	// The conversion webhook serves v1beta1, it needs the serving certificate mounted by config/default, which
+	// sets ENABLE_WEBHOOKS=true. Without it, for example locally or with the manifests of deploy/, it's disabled.
+	if os.Getenv("ENABLE_WEBHOOKS") == "true" {
+		if err = (&searchv1beta1.SearchOperator{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "SearchOperator")
+			os.Exit(1)
+		}
+		if err = (&searchv1beta1.SearchCustomization{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "SearchCustomization")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
+	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+		setupLog.Error(err, "unable to set up health check")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("cache-sync", health.CacheSynced(mgr.GetCache())); err != nil {
+		setupLog.Error(err, "unable to set up ready check", "check", "cache-sync")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("crds", health.CRDsServed(discoveryClient,
+		searchopenclustermanagementiov1.GroupVersion, "searchoperators", "searchcustomizations")); err != nil {
+		setupLog.Error(err, "unable to set up ready check", "check", "crds")
+		os.Exit(1)
+	}
+	if searchServed {
+		if err := mgr.AddReadyzCheck("search-crd", health.CRDsServed(discoveryClient, searchv1beta1.GroupVersion,
+			"searches")); err != nil {
+			setupLog.Error(err, "unable to set up ready check", "check", "search-crd")
+			os.Exit(1)
+		}
+	}
+
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
 }
+
+// imageVerifier returns the hook verifying the signature of the redisgraph image, the command in
+// IMAGE_VERIFY_COMMAND run with the image as its last argument. Images can't be verified if it isn't set.
+func imageVerifier() controllers.ImageVerifier {
+	command := strings.Fields(os.Getenv("IMAGE_VERIFY_COMMAND"))
+	if len(command) == 0 {
+		return nil
+	}
+	return &controllers.CommandImageVerifier{Command: command, Timeout: time.Minute}
+}
+
+// registryTokenHosts returns the hosts of the registry token services trusted with the credentials of the pull
+// secret besides the registry itself, the comma separated list in REGISTRY_TOKEN_HOSTS.
+func registryTokenHosts() []string {
+	return strings.FieldsFunc(os.Getenv("REGISTRY_TOKEN_HOSTS"), func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
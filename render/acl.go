@@ -0,0 +1,65 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package render
+
+import (
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ACLFileKey is the key of the ACL file in the redisgraph user secret
+	ACLFileKey = "users.acl"
+	// ACLFilePath is where the ACL file is mounted in the redisgraph container
+	ACLFilePath = aclMountPath + "/" + ACLFileKey
+	// ProbeUser is the ACL user of the health probe of the operator
+	ProbeUser    = "search-operator"
+	aclMountPath = "/acl"
+	aclVolume    = "redis-acl"
+)
+
+// ComponentUsers are the search components given their own Redis ACL user, named like the component
+var ComponentUsers = []string{"search-api", "search-collector", "search-aggregator", ProbeUser}
+
+// ComponentUsersEnabled is true if the SearchOperator creates an ACL user for each search component.
+func ComponentUsersEnabled(cr *searchv1alpha1.SearchOperator) bool {
+	return cr.Spec.PasswordPolicy != nil && cr.Spec.PasswordPolicy.ComponentUsers
+}
+
+// ComponentSecrets returns the secrets of the ACL users of the search components without their credentials, which
+// are generated when the secrets are created.
+func ComponentSecrets(cr *searchv1alpha1.SearchOperator, names Names) []*corev1.Secret {
+	secrets := make([]*corev1.Secret, 0, len(ComponentUsers))
+	for _, component := range ComponentUsers {
+		secrets = append(secrets, &corev1.Secret{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            names.ComponentSecret(component),
+				Namespace:       cr.Namespace,
+				Labels:          map[string]string{"app": AppName, "component": Component},
+				OwnerReferences: ownerReferences(cr),
+			},
+		})
+	}
+	return secrets
+}
+
+// aclFileVolume returns the volume and the mount that give the ACL file of the redisgraph user secret to the
+// redisgraph container, and the arguments that make redis load it on start.
+func aclFileVolume(names Names) (corev1.Volume, corev1.VolumeMount, []string) {
+	defaultMode := int32(0440)
+	volume := corev1.Volume{
+		Name: aclVolume,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName:  names.RedisSecret,
+				Items:       []corev1.KeyToPath{{Key: ACLFileKey, Path: ACLFileKey}},
+				DefaultMode: &defaultMode,
+			},
+		},
+	}
+	mount := corev1.VolumeMount{Name: aclVolume, MountPath: aclMountPath, ReadOnly: true}
+	return volume, mount, []string{"--aclfile", ACLFilePath}
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package render
+
+import (
+	"testing"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestRenderComponentUsers(t *testing.T) {
+	cr := testSearchOperator(DefaultInstanceName)
+	cr.Spec.PasswordPolicy = &searchv1alpha1.PasswordPolicy{ComponentUsers: true}
+	objects := Render(Options{SearchOperator: cr, ReleaseName: "search-prod"})
+
+	assert.Len(t, objects.ComponentSecrets, len(ComponentUsers), "Expected a secret for each component user.")
+	assert.Equal(t, "search-api-redisgraph-user", objects.ComponentSecrets[0].Name)
+	for _, secret := range objects.ComponentSecrets {
+		assert.Empty(t, secret.Data, "Expected the credentials not to be rendered.")
+	}
+	spec := objects.StatefulSet.Spec.Template.Spec
+	assert.Equal(t, []string{"--aclfile", ACLFilePath}, spec.Containers[0].Args, "Expected redis to load the ACL file.")
+	volume := spec.Volumes[len(spec.Volumes)-1]
+	assert.Equal(t, "redisgraph-user-secret", volume.Secret.SecretName, "Expected the ACL file of the user secret.")
+	assert.Equal(t, []corev1.KeyToPath{{Key: ACLFileKey, Path: ACLFileKey}}, volume.Secret.Items)
+	assertGolden(t, "component-users", objects)
+}
+
+func TestComponentSecretNames(t *testing.T) {
+	assert.Equal(t, "search-collector-redisgraph-user", NamesFor(DefaultInstanceName).ComponentSecret("search-collector"))
+	assert.Equal(t, "search-dev-search-collector-redisgraph-user",
+		NamesFor("search-dev").ComponentSecret("search-collector"))
+
+	objects := Render(Options{SearchOperator: testSearchOperator("search-dev"), ReleaseName: "search-prod"})
+	assert.Empty(t, objects.ComponentSecrets, "Expected no component users by default.")
+	assert.Empty(t, objects.StatefulSet.Spec.Template.Spec.Containers[0].Args, "Expected no ACL file by default.")
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package render
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+const (
+	// DefaultInstanceName is the SearchOperator created during install, its objects keep their original names
+	DefaultInstanceName = "searchoperator"
+	// InstanceLabel links a SearchCustomization, or the redisgraph pods, to a SearchOperator instance
+	InstanceLabel   = "search.open-cluster-management.io/searchoperator"
+	AppName         = "search"
+	Component       = "redisgraph"
+	RedisPort       = 6380
+	RedisCertSecret = "search-redisgraph-certs"
+)
+
+// Names are the names of the objects owned by a SearchOperator instance.
+type Names struct {
+	Instance    string
+	StatefulSet string
+	RedisSecret string
+	// ConnectionSecret is the secret search components use to connect to Redisgraph
+	ConnectionSecret string
+	// DefaultPVC is the PVC used when no storage class is set
+	DefaultPVC string
+	// ServiceAccount is the account the redisgraph pods run as, it has no API permissions
+	ServiceAccount string
+}
+
+// NamesFor derives the names of the redisgraph objects from the SearchOperator instance name.
+// The default instance keeps the names used before instances could be named.
+func NamesFor(instance string) Names {
+	names := Names{
+		Instance:    instance,
+		StatefulSet: "search-redisgraph",
+		RedisSecret: "redisgraph-user-secret",
+	}
+	if instance != DefaultInstanceName {
+		names.StatefulSet = instance + "-redisgraph"
+		names.RedisSecret = instance + "-redisgraph-user-secret"
+	}
+	names.DefaultPVC = names.StatefulSet + "-pvc-0"
+	names.ConnectionSecret = names.StatefulSet + "-connection"
+	names.ServiceAccount = names.StatefulSet
+	return names
+}
+
+// ValidateInstanceName returns an error if the objects of the instance would have the names of the objects of
+// the default instance, like search-redisgraph for an instance named search.
+func ValidateInstanceName(instance string) error {
+	if instance == DefaultInstanceName {
+		return nil
+	}
+	defaults := map[string]bool{}
+	for _, name := range NamesFor(DefaultInstanceName).objectNames() {
+		defaults[name] = true
+	}
+	for _, name := range NamesFor(instance).objectNames() {
+		if defaults[name] {
+			return fmt.Errorf("the name of SearchOperator %s is reserved, its %s would be the one of the %s instance",
+				instance, name, DefaultInstanceName)
+		}
+	}
+	return nil
+}
+
+// objectNames are the names of the objects of the instance.
+func (n Names) objectNames() []string {
+	names := []string{n.StatefulSet, n.RedisSecret, n.ConnectionSecret, n.DefaultPVC}
+	for _, component := range ComponentUsers {
+		names = append(names, n.ComponentSecret(component))
+	}
+	return names
+}
+
+// ComponentSecret is the name of the secret with the credentials of the ACL user of a search component.
+func (n Names) ComponentSecret(component string) string {
+	if n.Instance == DefaultInstanceName {
+		return component + "-redisgraph-user"
+	}
+	return n.Instance + "-" + component + "-redisgraph-user"
+}
+
+// StorageClassPVC is the name of the PVC created on a user provided storageClass.
+func (n Names) StorageClassPVC(storageClass string) string {
+	return storageClass + "-" + n.StatefulSet + "-0"
+}
+
+// PodSelector selects the redisgraph pods of the instance.
+// The default instance keeps its original selector because the StatefulSet selector can't be changed.
+func (n Names) PodSelector() map[string]string {
+	selector := map[string]string{
+		"component": Component,
+		"app":       AppName,
+	}
+	if n.Instance != DefaultInstanceName {
+		selector[InstanceLabel] = n.Instance
+	}
+	return selector
+}
+
+// PodListSelector selects the redisgraph pods of the instance when they are listed. The pods of the named instances
+// also carry the labels of the default selector, so the default instance requires the instance label to be absent.
+func (n Names) PodListSelector() labels.Selector {
+	selector := labels.SelectorFromSet(n.PodSelector())
+	if n.Instance == DefaultInstanceName {
+		requirement, err := labels.NewRequirement(InstanceLabel, selection.DoesNotExist, nil)
+		if err == nil {
+			selector = selector.Add(*requirement)
+		}
+	}
+	return selector
+}
+
+// PodLabelSelector is the PodListSelector of the objects selecting the redisgraph pods with a label selector.
+func (n Names) PodLabelSelector() metav1.LabelSelector {
+	selector := metav1.LabelSelector{MatchLabels: n.PodSelector()}
+	if n.Instance == DefaultInstanceName {
+		selector.MatchExpressions = []metav1.LabelSelectorRequirement{
+			{Key: InstanceLabel, Operator: metav1.LabelSelectorOpDoesNotExist},
+		}
+	}
+	return selector
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package render
+
+import (
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// namespaceNameLabel is set by Kubernetes on every namespace to its name
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+// DefaultNetworkPolicyClients are the search components connecting to redisgraph
+var DefaultNetworkPolicyClients = []searchv1alpha1.NetworkPolicyClient{
+	{Name: "search-api", MatchLabels: map[string]string{"app": "search", "component": "search-api"}},
+	{Name: "search-collector", MatchLabels: map[string]string{"app": "search-prod", "component": "search-collector"}},
+	{Name: "search-aggregator", MatchLabels: map[string]string{"app": "search-prod", "component": "search-aggregator"}},
+}
+
+// OperatorPodLabels select the pods of the operator, admitted to redisgraph for its health checks.
+// The operator pods of deploy/operator.yaml and config/manager carry them.
+var OperatorPodLabels = map[string]string{"name": "search-operator"}
+
+// NetworkPolicyEnabled is true if the SearchOperator asks for a NetworkPolicy in front of redisgraph.
+func NetworkPolicyEnabled(cr *searchv1alpha1.SearchOperator) bool {
+	return cr.Spec.NetworkPolicy != nil && cr.Spec.NetworkPolicy.Enabled
+}
+
+// NetworkPolicy returns the NetworkPolicy only admitting the clients of the instance and the operator to the
+// redisgraph port. The operator is selected in operatorNamespace, or in the namespace of the instance if it's empty.
+func NetworkPolicy(cr *searchv1alpha1.SearchOperator, names Names, operatorNamespace string) *networkingv1.NetworkPolicy {
+	clients := DefaultNetworkPolicyClients
+	if cr.Spec.NetworkPolicy != nil && len(cr.Spec.NetworkPolicy.Clients) > 0 {
+		clients = cr.Spec.NetworkPolicy.Clients
+	}
+	peers := make([]networkingv1.NetworkPolicyPeer, 0, len(clients)+1)
+	for _, client := range clients {
+		peers = append(peers, networkingv1.NetworkPolicyPeer{
+			PodSelector: &metav1.LabelSelector{MatchLabels: client.MatchLabels},
+		})
+	}
+	operator := networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: OperatorPodLabels}}
+	if operatorNamespace != "" && operatorNamespace != cr.Namespace {
+		operator.NamespaceSelector = &metav1.LabelSelector{
+			MatchLabels: map[string]string{namespaceNameLabel: operatorNamespace},
+		}
+	}
+	peers = append(peers, operator)
+	protocol := corev1.ProtocolTCP
+	port := intstr.FromInt(RedisPort)
+	return &networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            names.StatefulSet,
+			Namespace:       cr.Namespace,
+			Labels:          map[string]string{"app": AppName, "component": Component},
+			OwnerReferences: ownerReferences(cr),
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: names.PodLabelSelector(),
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					Ports: []networkingv1.NetworkPolicyPort{{Protocol: &protocol, Port: &port}},
+					From:  peers,
+				},
+			},
+		},
+	}
+}
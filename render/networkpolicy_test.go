@@ -0,0 +1,39 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package render
+
+import (
+	"testing"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderNetworkPolicy(t *testing.T) {
+	cr := testSearchOperator(DefaultInstanceName)
+	cr.Spec.NetworkPolicy = &searchv1alpha1.NetworkPolicySpec{Enabled: true}
+	objects := Render(Options{SearchOperator: cr, ReleaseName: "search-prod", OperatorNamespace: "search-operator"})
+
+	assert.NotNil(t, objects.NetworkPolicy, "Expected a NetworkPolicy when it's enabled.")
+	assertGolden(t, "network-policy", objects)
+}
+
+func TestNetworkPolicyClients(t *testing.T) {
+	cr := testSearchOperator("search-dev")
+	cr.Spec.NetworkPolicy = &searchv1alpha1.NetworkPolicySpec{Enabled: true, Clients: []searchv1alpha1.NetworkPolicyClient{
+		{Name: "search-v2-api", MatchLabels: map[string]string{"app": "search-v2-api"}},
+	}}
+	policy := NetworkPolicy(cr, NamesFor(cr.Name), cr.Namespace)
+
+	assert.Equal(t, NamesFor(cr.Name).PodSelector(), policy.Spec.PodSelector.MatchLabels,
+		"Expected the NetworkPolicy to select the redisgraph pods of the instance.")
+	peers := policy.Spec.Ingress[0].From
+	assert.Len(t, peers, 2, "Expected the clients of the spec and the operator.")
+	assert.Equal(t, map[string]string{"app": "search-v2-api"}, peers[0].PodSelector.MatchLabels)
+	assert.Equal(t, OperatorPodLabels, peers[1].PodSelector.MatchLabels)
+	assert.Nil(t, peers[1].NamespaceSelector, "Expected the operator in the namespace of the instance.")
+
+	cr.Spec.NetworkPolicy.Enabled = false
+	assert.Nil(t, Render(Options{SearchOperator: cr}).NetworkPolicy, "Expected no NetworkPolicy when disabled.")
+}
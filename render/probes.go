@@ -0,0 +1,91 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package render
+
+import (
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Default probe timings for the redisgraph container. The startup probe allows 10 minutes
+// for Redisgraph to load the RDB file before the liveness probe takes over.
+var (
+	defaultStartupProbe = searchv1alpha1.ProbeTimings{
+		TimeoutSeconds:   5,
+		PeriodSeconds:    10,
+		FailureThreshold: 60,
+	}
+	defaultReadinessProbe = searchv1alpha1.ProbeTimings{
+		TimeoutSeconds:   5,
+		PeriodSeconds:    15,
+		FailureThreshold: 3,
+	}
+	defaultLivenessProbe = searchv1alpha1.ProbeTimings{
+		TimeoutSeconds:   5,
+		PeriodSeconds:    15,
+		FailureThreshold: 3,
+	}
+)
+
+// redisPingCommand runs PING through the TLS port, so it fails while stunnel is up but
+// redis-server is down or still loading the RDB file. The password is passed in REDISCLI_AUTH
+// rather than on the command line, where it would show in the process list of the node.
+var redisPingCommand = []string{
+	"sh", "-c",
+	`REDISCLI_AUTH="$REDIS_PASSWORD" redis-cli -p 6380 --tls --insecure ping | grep -q PONG`,
+}
+
+// redisAliveCommand runs PING like redisPingCommand but also accepts the LOADING error, so the
+// container is restarted when redis-server stops answering behind stunnel, not while it loads
+// the RDB file.
+var redisAliveCommand = []string{
+	"sh", "-c",
+	`REDISCLI_AUTH="$REDIS_PASSWORD" redis-cli -p 6380 --tls --insecure ping | grep -q -e PONG -e LOADING`,
+}
+
+// Probes returns the startup, readiness and liveness probes for the redisgraph container.
+func Probes(cr *searchv1alpha1.SearchOperator) (startup, readiness, liveness *corev1.Probe) {
+	overrides := searchv1alpha1.RedisgraphProbes{}
+	if cr.Spec.Probes != nil {
+		overrides = *cr.Spec.Probes
+	}
+	pingHandler := corev1.ProbeHandler{
+		Exec: &corev1.ExecAction{Command: redisPingCommand},
+	}
+	aliveHandler := corev1.ProbeHandler{
+		Exec: &corev1.ExecAction{Command: redisAliveCommand},
+	}
+	startup = newProbe(pingHandler, defaultStartupProbe, overrides.Startup)
+	readiness = newProbe(pingHandler, defaultReadinessProbe, overrides.Readiness)
+	liveness = newProbe(aliveHandler, defaultLivenessProbe, overrides.Liveness)
+	return startup, readiness, liveness
+}
+
+// newProbe builds a probe using the timings set in the CR, falling back to the defaults for unset values.
+func newProbe(handler corev1.ProbeHandler, defaults searchv1alpha1.ProbeTimings,
+	override *searchv1alpha1.ProbeTimings) *corev1.Probe {
+	timings := defaults
+	if override != nil {
+		if override.InitialDelaySeconds != 0 {
+			timings.InitialDelaySeconds = override.InitialDelaySeconds
+		}
+		if override.TimeoutSeconds != 0 {
+			timings.TimeoutSeconds = override.TimeoutSeconds
+		}
+		if override.PeriodSeconds != 0 {
+			timings.PeriodSeconds = override.PeriodSeconds
+		}
+		if override.FailureThreshold != 0 {
+			timings.FailureThreshold = override.FailureThreshold
+		}
+	}
+	return &corev1.Probe{
+		ProbeHandler:        handler,
+		InitialDelaySeconds: timings.InitialDelaySeconds,
+		TimeoutSeconds:      timings.TimeoutSeconds,
+		PeriodSeconds:       timings.PeriodSeconds,
+		SuccessThreshold:    1,
+		FailureThreshold:    timings.FailureThreshold,
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package render
+
+import (
+	"testing"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisgraphProbeDefaults(t *testing.T) {
+	cr := &searchv1alpha1.SearchOperator{}
+	startup, readiness, liveness := Probes(cr)
+
+	assert.NotNil(t, startup.Exec, "Expected startup probe to run PING.")
+	assert.Equal(t, redisPingCommand, startup.Exec.Command)
+	assert.Equal(t, defaultStartupProbe.FailureThreshold, startup.FailureThreshold)
+	assert.Equal(t, defaultStartupProbe.PeriodSeconds, startup.PeriodSeconds)
+	assert.NotNil(t, readiness.Exec, "Expected readiness probe to run PING.")
+	assert.Equal(t, defaultReadinessProbe.TimeoutSeconds, readiness.TimeoutSeconds)
+	assert.NotNil(t, liveness.Exec, "Expected liveness probe to run PING.")
+	assert.Equal(t, redisAliveCommand, liveness.Exec.Command)
+	for _, command := range [][]string{startup.Exec.Command, liveness.Exec.Command} {
+		assert.NotContains(t, command[2], "-a ", "Expected the password not to be on the command line.")
+	}
+}
+
+func TestRedisgraphProbeOverrides(t *testing.T) {
+	cr := &searchv1alpha1.SearchOperator{Spec: searchv1alpha1.SearchOperatorSpec{
+		Probes: &searchv1alpha1.RedisgraphProbes{
+			Startup:  &searchv1alpha1.ProbeTimings{FailureThreshold: 360},
+			Liveness: &searchv1alpha1.ProbeTimings{TimeoutSeconds: 10, InitialDelaySeconds: 30},
+		},
+	}}
+	startup, readiness, liveness := Probes(cr)
+
+	assert.Equal(t, int32(360), startup.FailureThreshold, "Expected startup failureThreshold from the CR.")
+	assert.Equal(t, defaultStartupProbe.PeriodSeconds, startup.PeriodSeconds, "Expected default for unset timing.")
+	assert.Equal(t, defaultReadinessProbe.TimeoutSeconds, readiness.TimeoutSeconds)
+	assert.Equal(t, int32(10), liveness.TimeoutSeconds, "Expected liveness timeout from the CR.")
+	assert.Equal(t, int32(30), liveness.InitialDelaySeconds, "Expected liveness initial delay from the CR.")
+	assert.Equal(t, defaultLivenessProbe.PeriodSeconds, liveness.PeriodSeconds)
+}
@@ -0,0 +1,336 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package render builds the redisgraph objects of a SearchOperator instance from the SearchOperator and
+// SearchCustomization specs, without reading the cluster.
+package render
+
+import (
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const redisUser = int64(10001)
+
+// Options are what the objects of an instance are rendered from.
+type Options struct {
+	SearchOperator *searchv1alpha1.SearchOperator
+	// SearchCustomization that applies to the SearchOperator, the defaults are used if nil
+	SearchCustomization *searchv1alpha1.SearchCustomization
+	// ReleaseName is the release label of the redisgraph pods
+	ReleaseName string
+	// Degraded renders redisgraph saving to an emptyDir, the fallback when it can't use the PVC
+	Degraded bool
+	// OperatorNamespace is the namespace of the operator pods admitted by the NetworkPolicy
+	OperatorNamespace string
+}
+
+// Objects are the objects of an instance.
+type Objects struct {
+	// Secret is the template of the redisgraph user secret, the password is generated when it's created
+	Secret *corev1.Secret
+	// ServiceAccount is the account of the redisgraph pods
+	ServiceAccount *corev1.ServiceAccount
+	// PVC is nil when redisgraph doesn't save to a PVC
+	PVC         *corev1.PersistentVolumeClaim
+	StatefulSet *appv1.StatefulSet
+	// Service is the Service search components connect to
+	Service *corev1.Service
+	// NetworkPolicy is nil unless the SearchOperator enables it
+	NetworkPolicy *networkingv1.NetworkPolicy
+	// ComponentSecrets are the credentials of the ACL users of the search components, empty unless the
+	// SearchOperator enables the component users
+	ComponentSecrets []*corev1.Secret
+}
+
+// Render returns the objects of the instance.
+func Render(opts Options) Objects {
+	cr := opts.SearchOperator
+	names := NamesFor(cr.Name)
+	storage := StorageFor(names, opts.SearchCustomization)
+	objects := Objects{
+		Secret:         Secret(cr, names),
+		ServiceAccount: ServiceAccount(cr, names),
+		Service:        Service(cr, names),
+	}
+	if NetworkPolicyEnabled(cr) {
+		objects.NetworkPolicy = NetworkPolicy(cr, names, opts.OperatorNamespace)
+	}
+	if ComponentUsersEnabled(cr) {
+		objects.ComponentSecrets = ComponentSecrets(cr, names)
+	}
+	switch {
+	case !storage.Persistence:
+		objects.StatefulSet = StatefulSet(cr, names, opts.ReleaseName, corev1.VolumeSource{}, "false")
+	case opts.Degraded:
+		objects.StatefulSet = StatefulSet(cr, names, opts.ReleaseName,
+			corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}, "true")
+	default:
+		objects.PVC = PVC(cr.Namespace, storage)
+		objects.StatefulSet = StatefulSet(cr, names, opts.ReleaseName, corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: storage.PVCName},
+		}, "true")
+	}
+	return objects
+}
+
+// List returns the objects in the order they are applied.
+func (o Objects) List() []client.Object {
+	list := []client.Object{o.Secret}
+	for _, secret := range o.ComponentSecrets {
+		list = append(list, secret)
+	}
+	list = append(list, o.ServiceAccount)
+	if o.PVC != nil {
+		list = append(list, o.PVC)
+	}
+	list = append(list, o.StatefulSet, o.Service)
+	if o.NetworkPolicy != nil {
+		list = append(list, o.NetworkPolicy)
+	}
+	return list
+}
+
+func ownerReferences(cr *searchv1alpha1.SearchOperator) []metav1.OwnerReference {
+	return []metav1.OwnerReference{
+		*metav1.NewControllerRef(cr, searchv1alpha1.GroupVersion.WithKind("SearchOperator")),
+	}
+}
+
+// Secret returns the redisgraph user secret of the instance without its password.
+func Secret(cr *searchv1alpha1.SearchOperator, names Names) *corev1.Secret {
+	return &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            names.RedisSecret,
+			Namespace:       cr.Namespace,
+			Labels:          map[string]string{"app": AppName},
+			OwnerReferences: ownerReferences(cr),
+		},
+	}
+}
+
+// ServiceAccount returns the account the redisgraph pods run as. Redisgraph doesn't call the API, so no role is
+// bound to it and its token isn't mounted.
+func ServiceAccount(cr *searchv1alpha1.SearchOperator, names Names) *corev1.ServiceAccount {
+	automount := false
+	return &corev1.ServiceAccount{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            names.ServiceAccount,
+			Namespace:       cr.Namespace,
+			Labels:          map[string]string{"app": AppName, "component": Component},
+			OwnerReferences: ownerReferences(cr),
+		},
+		AutomountServiceAccountToken: &automount,
+	}
+}
+
+// PVC returns the claim redisgraph saves its data to. It has no owner so the data outlives the instance.
+func PVC(namespace string, storage Storage) *corev1.PersistentVolumeClaim {
+	pvc := &corev1.PersistentVolumeClaim{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "PersistentVolumeClaim"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      storage.PVCName,
+			Namespace: namespace,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: resource.MustParse(storage.StorageSize),
+				},
+			},
+		},
+	}
+	if storage.StorageClass != "" {
+		storageClass := storage.StorageClass
+		pvc.Spec.StorageClassName = &storageClass
+	}
+	return pvc
+}
+
+// Service returns the Service in front of the redisgraph pods, its name is the host in the connection secret.
+// The pods of the named instances also carry the labels of the default selector, and a Service can't require a
+// label to be absent, so it selects the single pod of the StatefulSet by the pod name label set by Kubernetes.
+func Service(cr *searchv1alpha1.SearchOperator, names Names) *corev1.Service {
+	selector := names.PodSelector()
+	selector[appv1.StatefulSetPodNameLabel] = names.StatefulSet + "-0"
+	return &corev1.Service{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            names.StatefulSet,
+			Namespace:       cr.Namespace,
+			Labels:          map[string]string{"app": AppName, "component": Component},
+			OwnerReferences: ownerReferences(cr),
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: selector,
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "redisgraph",
+					Protocol:   corev1.ProtocolTCP,
+					Port:       RedisPort,
+					TargetPort: intstr.FromInt(RedisPort),
+				},
+			},
+		},
+	}
+}
+
+// StatefulSet returns the redisgraph StatefulSet saving its data to rdbVolumeSource, or not saving it
+// if the volume source is empty. saverdb is the value of the SAVERDB variable of the container.
+func StatefulSet(cr *searchv1alpha1.SearchOperator, names Names, releaseName string,
+	rdbVolumeSource corev1.VolumeSource, saverdb string) *appv1.StatefulSet {
+	metadataLabels := map[string]string{}
+	metadataLabels["release"] = releaseName
+	metadataLabels["component"] = Component
+	metadataLabels["app"] = AppName
+	for key, value := range names.PodSelector() {
+		metadataLabels[key] = value
+	}
+	sset := &appv1.StatefulSet{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "StatefulSet"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            names.StatefulSet,
+			Namespace:       cr.Namespace,
+			Labels:          metadataLabels,
+			OwnerReferences: ownerReferences(cr),
+		},
+	}
+	replicas := int32(1)
+	sset.Spec.Replicas = &replicas
+	sset.Spec.Selector = &metav1.LabelSelector{
+		MatchLabels: names.PodSelector(),
+	}
+	sset.Spec.Template.ObjectMeta.Labels = metadataLabels
+	automount := false
+	sset.Spec.Template.Spec.ServiceAccountName = names.ServiceAccount
+	sset.Spec.Template.Spec.AutomountServiceAccountToken = &automount
+	tol := corev1.Toleration{
+		Key:      "node-role.kubernetes.io/infra",
+		Effect:   corev1.TaintEffectNoSchedule,
+		Operator: corev1.TolerationOpExists,
+	}
+	sset.Spec.Template.Spec.Tolerations = []corev1.Toleration{tol}
+	pullSecret := corev1.LocalObjectReference{
+		Name: cr.Spec.PullSecret,
+	}
+	sset.Spec.Template.Spec.ImagePullSecrets = []corev1.LocalObjectReference{pullSecret}
+	podSecurityContext, containerSecurityContext := SecurityContexts(cr)
+	sset.Spec.Template.Spec.SecurityContext = podSecurityContext
+	startupProbe, readinessProbe, livenessProbe := Probes(cr)
+	defaultMode := int32(420)
+	sset.Spec.Template.Spec.Containers = []corev1.Container{
+		{
+			Name:  "redisgraph",
+			Image: cr.Spec.SearchImageOverrides.Redisgraph_TLS,
+			Env: []corev1.EnvVar{
+				{
+					Name: "REDIS_PASSWORD",
+					ValueFrom: &corev1.EnvVarSource{
+						SecretKeyRef: &corev1.SecretKeySelector{
+							LocalObjectReference: corev1.LocalObjectReference{
+								Name: names.RedisSecret,
+							},
+							Key: "redispwd",
+						},
+					},
+				},
+				{
+					Name:  "REDIS_GRAPH_SSL",
+					Value: "true",
+				},
+				{
+					Name:  "SAVERDB",
+					Value: saverdb,
+				},
+			},
+			StartupProbe:   startupProbe,
+			LivenessProbe:  livenessProbe,
+			ReadinessProbe: readinessProbe,
+			Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{
+					"memory": resource.MustParse(cr.Spec.Redisgraph_Resource.LimitMemory),
+				},
+				Requests: corev1.ResourceList{
+					"cpu":    resource.MustParse(cr.Spec.Redisgraph_Resource.RequestCPU),
+					"memory": resource.MustParse(cr.Spec.Redisgraph_Resource.RequestMemory),
+				},
+			},
+			TerminationMessagePolicy: "File",
+			TerminationMessagePath:   "/dev/termination-log",
+			ImagePullPolicy:          "Always",
+			SecurityContext:          containerSecurityContext,
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      "redis-graph-certs",
+					MountPath: "/certs",
+				},
+				{
+					Name:      "stunnel-pid",
+					MountPath: "/rg",
+				},
+			},
+		},
+	}
+	sset.Spec.Template.Spec.Volumes = []corev1.Volume{
+		{
+			Name: "stunnel-pid",
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			},
+		},
+		{
+			Name: "redis-graph-certs",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: RedisCertSecret,
+					Items: []corev1.KeyToPath{
+						{
+							Key:  "tls.crt",
+							Path: "server.crt",
+						},
+						{
+							Key:  "tls.key",
+							Path: "server.key",
+						},
+					},
+					DefaultMode: &defaultMode,
+				},
+			},
+		},
+	}
+
+	if (corev1.VolumeSource{}) != rdbVolumeSource {
+		sset.Spec.Template.Spec.Volumes = append(sset.Spec.Template.Spec.Volumes, corev1.Volume{
+			Name:         "persist",
+			VolumeSource: rdbVolumeSource,
+		})
+		container := &sset.Spec.Template.Spec.Containers[0]
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      "persist",
+			MountPath: "/redis-data",
+		})
+	}
+	volumes, mounts := writableVolumes(cr, (corev1.VolumeSource{}) != rdbVolumeSource)
+	sset.Spec.Template.Spec.Volumes = append(sset.Spec.Template.Spec.Volumes, volumes...)
+	container := &sset.Spec.Template.Spec.Containers[0]
+	container.VolumeMounts = append(container.VolumeMounts, mounts...)
+	if ComponentUsersEnabled(cr) {
+		volume, mount, args := aclFileVolume(names)
+		sset.Spec.Template.Spec.Volumes = append(sset.Spec.Template.Spec.Volumes, volume)
+		container.VolumeMounts = append(container.VolumeMounts, mount)
+		container.Args = append(container.Args, args...)
+	}
+	if cr.Spec.NodeSelector != nil {
+		sset.Spec.Template.Spec.NodeSelector = cr.Spec.NodeSelector
+	}
+	return sset
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package render
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	appv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+// update rewrites the golden files with the rendered objects: go test ./render -update
+var update = flag.Bool("update", false, "update the golden files")
+
+func testSearchOperator(name string) *searchv1alpha1.SearchOperator {
+	return &searchv1alpha1.SearchOperator{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "open-cluster-management",
+			UID:       "0b7a1c2e-6d0f-4f7e-9c1a-3f2b5d8e9a10",
+		},
+		Spec: searchv1alpha1.SearchOperatorSpec{
+			PullSecret: "multiclusterhub-operator-pull-secret",
+			SearchImageOverrides: searchv1alpha1.ImageOverrides{
+				Redisgraph_TLS: "quay.io/stolostron/redisgraph-tls:2.5.0",
+			},
+			Redisgraph_Resource: searchv1alpha1.PodResource{
+				RequestMemory: "64Mi",
+				RequestCPU:    "25m",
+				LimitMemory:   "1Gi",
+				LimitCPU:      "250m",
+			},
+		},
+	}
+}
+
+func testCustomization(spec searchv1alpha1.SearchCustomizationSpec) *searchv1alpha1.SearchCustomization {
+	return &searchv1alpha1.SearchCustomization{
+		ObjectMeta: metav1.ObjectMeta{Name: "searchcustomization", Namespace: "open-cluster-management"},
+		Spec:       spec,
+	}
+}
+
+// assertGolden compares the rendered objects with testdata/<name>.yaml.
+func assertGolden(t *testing.T, name string, objects Objects) {
+	var rendered bytes.Buffer
+	for _, obj := range objects.List() {
+		data, err := yaml.Marshal(obj)
+		assert.Nil(t, err, "Expected object to marshal. Got error: %v", err)
+		rendered.WriteString("---\n")
+		rendered.Write(data)
+	}
+	golden := filepath.Join("testdata", name+".yaml")
+	if *update {
+		assert.Nil(t, os.WriteFile(golden, rendered.Bytes(), 0600))
+	}
+	expected, err := os.ReadFile(golden) // #nosec G304
+	assert.Nil(t, err, "Expected golden file %s. Run go test ./render -update to create it.", golden)
+	assert.Equal(t, string(expected), rendered.String(), "Expected the objects of %s to match.", golden)
+}
+
+func TestRenderDefaults(t *testing.T) {
+	objects := Render(Options{SearchOperator: testSearchOperator(DefaultInstanceName), ReleaseName: "search-prod"})
+
+	assert.NotNil(t, objects.PVC, "Expected redisgraph to save to a PVC by default.")
+	assert.Empty(t, objects.Secret.Data, "Expected the password not to be rendered.")
+	assertGolden(t, "defaults", objects)
+}
+
+func TestRenderServiceAccount(t *testing.T) {
+	objects := Render(Options{SearchOperator: testSearchOperator("search-dev"), ReleaseName: "search-prod"})
+
+	assert.Equal(t, "search-dev-redisgraph", objects.ServiceAccount.Name)
+	assert.False(t, *objects.ServiceAccount.AutomountServiceAccountToken, "Expected no token for redisgraph.")
+	podSpec := objects.StatefulSet.Spec.Template.Spec
+	assert.Equal(t, objects.ServiceAccount.Name, podSpec.ServiceAccountName,
+		"Expected redisgraph to run as its own ServiceAccount.")
+	assert.False(t, *podSpec.AutomountServiceAccountToken, "Expected the token not to be mounted in the pod.")
+}
+
+func TestRenderStorageClass(t *testing.T) {
+	objects := Render(Options{
+		SearchOperator: testSearchOperator(DefaultInstanceName),
+		SearchCustomization: testCustomization(searchv1alpha1.SearchCustomizationSpec{
+			StorageClass: "gp2",
+			StorageSize:  "20Gi",
+		}),
+		ReleaseName: "search-prod",
+	})
+
+	assert.Equal(t, "gp2-search-redisgraph-0", objects.PVC.Name)
+	assertGolden(t, "storage-class", objects)
+}
+
+func TestRenderNoPersistence(t *testing.T) {
+	persistence := false
+	objects := Render(Options{
+		SearchOperator:      testSearchOperator(DefaultInstanceName),
+		SearchCustomization: testCustomization(searchv1alpha1.SearchCustomizationSpec{Persistence: &persistence}),
+		ReleaseName:         "search-prod",
+	})
+
+	assert.Nil(t, objects.PVC, "Expected no PVC with persistence disabled.")
+	assert.Len(t, objects.List(), 4)
+	assertGolden(t, "no-persistence", objects)
+}
+
+func TestRenderDegraded(t *testing.T) {
+	objects := Render(Options{
+		SearchOperator: testSearchOperator(DefaultInstanceName),
+		ReleaseName:    "search-prod",
+		Degraded:       true,
+	})
+
+	assert.Nil(t, objects.PVC, "Expected no PVC in degraded mode.")
+	volumes := objects.StatefulSet.Spec.Template.Spec.Volumes
+	assert.NotNil(t, volumes[len(volumes)-1].EmptyDir, "Expected redisgraph to save to an emptyDir.")
+}
+
+func TestRenderNamedInstance(t *testing.T) {
+	cr := testSearchOperator("search-dev")
+	cr.Spec.NodeSelector = map[string]string{"node-role.kubernetes.io/infra": ""}
+	objects := Render(Options{SearchOperator: cr, ReleaseName: "search-prod"})
+
+	assert.Equal(t, "search-dev-redisgraph", objects.StatefulSet.Name)
+	assert.Equal(t, "search-dev", objects.Service.Spec.Selector[InstanceLabel],
+		"Expected the Service to select the pods of the instance.")
+	assertGolden(t, "named-instance", objects)
+}
+
+func TestValidateInstanceName(t *testing.T) {
+	assert.Equal(t, NamesFor(DefaultInstanceName).StatefulSet, NamesFor("search").StatefulSet)
+	assert.NotNil(t, ValidateInstanceName("search"), "Expected search to be reserved by the default instance.")
+	assert.Nil(t, ValidateInstanceName(DefaultInstanceName))
+	assert.Nil(t, ValidateInstanceName("search-dev"))
+}
+
+func TestStorageFor(t *testing.T) {
+	names := NamesFor(DefaultInstanceName)
+
+	storage := StorageFor(names, nil)
+	assert.Equal(t, Storage{Persistence: true, AllowDegrade: true, StorageSize: "10Gi",
+		PVCName: "search-redisgraph-pvc-0"}, storage)
+
+	storage = StorageFor(names, testCustomization(searchv1alpha1.SearchCustomizationSpec{StorageSize: "5Gi"}))
+	assert.False(t, storage.AllowDegrade, "Expected no degraded mode with a SearchCustomization.")
+	assert.Equal(t, "5Gi", storage.StorageSize)
+	assert.Equal(t, "search-redisgraph-pvc-0", storage.PVCName)
+}
+
+func TestPodListSelector(t *testing.T) {
+	defaultPod := labels.Set{"app": AppName, "component": Component}
+	namedPod := labels.Set{"app": AppName, "component": Component, InstanceLabel: "search-dev"}
+
+	selector := NamesFor(DefaultInstanceName).PodListSelector()
+	assert.True(t, selector.Matches(defaultPod))
+	assert.False(t, selector.Matches(namedPod), "Expected the pods of a named instance to be left out.")
+	selector = NamesFor("search-dev").PodListSelector()
+	assert.True(t, selector.Matches(namedPod))
+	assert.False(t, selector.Matches(defaultPod))
+}
+
+func TestServiceSelector(t *testing.T) {
+	cr := &searchv1alpha1.SearchOperator{ObjectMeta: metav1.ObjectMeta{Name: DefaultInstanceName, Namespace: "test"}}
+	defaultPod := labels.Set{"app": AppName, "component": Component,
+		appv1.StatefulSetPodNameLabel: "search-redisgraph-0"}
+	namedPod := labels.Set{"app": AppName, "component": Component, InstanceLabel: "search-dev",
+		appv1.StatefulSetPodNameLabel: "search-dev-redisgraph-0"}
+
+	selector := labels.SelectorFromSet(Service(cr, NamesFor(DefaultInstanceName)).Spec.Selector)
+	assert.True(t, selector.Matches(defaultPod))
+	assert.False(t, selector.Matches(namedPod), "Expected the Service to leave out the pods of a named instance.")
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package render
+
+import (
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Security profiles of the redisgraph pod
+const (
+	SecurityProfileDefault    = "Default"
+	SecurityProfileRestricted = "Restricted"
+	SecurityProfileOpenShift  = "OpenShift"
+)
+
+// writablePaths are the emptyDir volumes mounted where redisgraph writes when the root filesystem is read-only.
+// The redis working directory is only added when the data isn't saved to the persist volume.
+var writablePaths = []corev1.VolumeMount{
+	{Name: "tmp", MountPath: "/tmp"},
+}
+
+// SecurityProfile returns the security profile of the SearchOperator, Default if it isn't set.
+func SecurityProfile(cr *searchv1alpha1.SearchOperator) string {
+	if cr.Spec.PodSecurity == nil || cr.Spec.PodSecurity.Profile == "" {
+		return SecurityProfileDefault
+	}
+	return cr.Spec.PodSecurity.Profile
+}
+
+// readOnlyRootFilesystem is true if the root filesystem of the redisgraph container is mounted read-only.
+func readOnlyRootFilesystem(cr *searchv1alpha1.SearchOperator) bool {
+	if cr.Spec.PodSecurity != nil && cr.Spec.PodSecurity.ReadOnlyRootFilesystem != nil {
+		return *cr.Spec.PodSecurity.ReadOnlyRootFilesystem
+	}
+	return SecurityProfile(cr) != SecurityProfileDefault
+}
+
+// SecurityContexts returns the security contexts of the redisgraph pod and container for the security profile.
+// The OpenShift profile sets no user or group, the restricted SCC assigns them from the range of the namespace.
+func SecurityContexts(cr *searchv1alpha1.SearchOperator) (*corev1.PodSecurityContext, *corev1.SecurityContext) {
+	user := redisUser
+	disabled := false
+	pod := &corev1.PodSecurityContext{FSGroup: &user, RunAsUser: &user}
+	container := &corev1.SecurityContext{Privileged: &disabled, AllowPrivilegeEscalation: &disabled}
+	profile := SecurityProfile(cr)
+	if profile == SecurityProfileRestricted || profile == SecurityProfileOpenShift {
+		nonRoot := true
+		pod.RunAsNonRoot = &nonRoot
+		pod.SeccompProfile = &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+		container.Capabilities = &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}}
+	}
+	if profile == SecurityProfileOpenShift {
+		pod.FSGroup, pod.RunAsUser = nil, nil
+	}
+	if readOnlyRootFilesystem(cr) {
+		readOnly := true
+		container.ReadOnlyRootFilesystem = &readOnly
+	}
+	return pod, container
+}
+
+// writableVolumes returns the emptyDir volumes and mounts redisgraph needs when its root filesystem is read-only.
+// dataMounted is true if /redis-data is already mounted from the persist volume.
+func writableVolumes(cr *searchv1alpha1.SearchOperator, dataMounted bool) ([]corev1.Volume, []corev1.VolumeMount) {
+	if !readOnlyRootFilesystem(cr) {
+		return nil, nil
+	}
+	mounts := append([]corev1.VolumeMount{}, writablePaths...)
+	if !dataMounted {
+		mounts = append(mounts, corev1.VolumeMount{Name: "data", MountPath: "/redis-data"})
+	}
+	volumes := make([]corev1.Volume, 0, len(mounts))
+	for _, mount := range mounts {
+		volumes = append(volumes, corev1.Volume{
+			Name:         mount.Name,
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		})
+	}
+	return volumes, mounts
+}
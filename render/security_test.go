@@ -0,0 +1,65 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package render
+
+import (
+	"testing"
+
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestRenderRestricted(t *testing.T) {
+	cr := testSearchOperator(DefaultInstanceName)
+	cr.Spec.PodSecurity = &searchv1alpha1.PodSecurity{Profile: SecurityProfileRestricted}
+	objects := Render(Options{SearchOperator: cr, ReleaseName: "search-prod"})
+
+	assertGolden(t, "restricted", objects)
+}
+
+func TestSecurityContexts(t *testing.T) {
+	cr := testSearchOperator(DefaultInstanceName)
+	pod, container := SecurityContexts(cr)
+	assert.Equal(t, redisUser, *pod.RunAsUser, "Expected the default profile to run as the redis user.")
+	assert.Nil(t, pod.SeccompProfile)
+	assert.Nil(t, container.ReadOnlyRootFilesystem, "Expected a writable root filesystem by default.")
+
+	cr.Spec.PodSecurity = &searchv1alpha1.PodSecurity{Profile: SecurityProfileOpenShift}
+	pod, container = SecurityContexts(cr)
+	assert.Nil(t, pod.RunAsUser, "Expected the SCC to assign the user on OpenShift.")
+	assert.Nil(t, pod.FSGroup, "Expected the SCC to assign the group on OpenShift.")
+	assert.True(t, *pod.RunAsNonRoot)
+	assert.Equal(t, corev1.SeccompProfileTypeRuntimeDefault, pod.SeccompProfile.Type)
+	assert.Equal(t, []corev1.Capability{"ALL"}, container.Capabilities.Drop)
+	assert.False(t, *container.AllowPrivilegeEscalation)
+	assert.True(t, *container.ReadOnlyRootFilesystem)
+
+	writable := false
+	cr.Spec.PodSecurity.ReadOnlyRootFilesystem = &writable
+	_, container = SecurityContexts(cr)
+	assert.Nil(t, container.ReadOnlyRootFilesystem, "Expected the root filesystem to be writable when set.")
+}
+
+func TestWritableVolumes(t *testing.T) {
+	persistence := false
+	cr := testSearchOperator(DefaultInstanceName)
+	cr.Spec.PodSecurity = &searchv1alpha1.PodSecurity{Profile: SecurityProfileRestricted}
+	objects := Render(Options{
+		SearchOperator:      cr,
+		SearchCustomization: testCustomization(searchv1alpha1.SearchCustomizationSpec{Persistence: &persistence}),
+	})
+
+	mounts := map[string]string{}
+	for _, mount := range objects.StatefulSet.Spec.Template.Spec.Containers[0].VolumeMounts {
+		mounts[mount.MountPath] = mount.Name
+	}
+	assert.Equal(t, "tmp", mounts["/tmp"])
+	assert.Equal(t, "data", mounts["/redis-data"], "Expected an emptyDir for the data without persistence.")
+	for _, volume := range objects.StatefulSet.Spec.Template.Spec.Volumes {
+		if volume.Name == "tmp" || volume.Name == "data" {
+			assert.NotNil(t, volume.EmptyDir, "Expected %s to be an emptyDir.", volume.Name)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2022 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package render
+
+import (
+	searchv1alpha1 "github.com/stolostron/search-operator/api/v1alpha1"
+)
+
+const defaultStorageSize = "10Gi"
+
+// Storage is where redisgraph saves its data.
+type Storage struct {
+	Persistence bool
+	// AllowDegrade falls back to an emptyDir when redisgraph can't use the PVC. It's only allowed with
+	// the defaults, values set by the user aren't replaced so their configuration can be debugged.
+	AllowDegrade bool
+	StorageClass string
+	StorageSize  string
+	PVCName      string
+}
+
+// StorageFor returns the storage set in the SearchCustomization, the defaults if it's nil.
+func StorageFor(names Names, custom *searchv1alpha1.SearchCustomization) Storage {
+	storage := Storage{
+		Persistence:  true,
+		AllowDegrade: custom == nil,
+		StorageSize:  defaultStorageSize,
+		PVCName:      names.DefaultPVC,
+	}
+	if custom == nil {
+		return storage
+	}
+	if custom.Spec.Persistence != nil && !*custom.Spec.Persistence {
+		storage.Persistence = false
+	}
+	if custom.Spec.StorageClass != "" {
+		storage.StorageClass = custom.Spec.StorageClass
+		storage.PVCName = names.StorageClassPVC(storage.StorageClass)
+	}
+	if custom.Spec.StorageSize != "" {
+		storage.StorageSize = custom.Spec.StorageSize
+	}
+	return storage
+}